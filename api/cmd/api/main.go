@@ -23,6 +23,8 @@ import (
 	"github.com/mofe64/vulkan/api/internal/middleware"
 	"github.com/mofe64/vulkan/api/internal/routes"
 	"github.com/mofe64/vulkan/api/internal/service"
+	"github.com/mofe64/vulkan/operator/pkg/secretbox"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"go.uber.org/zap"
@@ -32,9 +34,11 @@ type DependencyContainer struct {
 	Database  *pgxpool.Pool
 	Auth      *auth.VulkanAuth
 	K8sClient client.Client
-	EventBus  *events.EventBus
-	log       *zap.Logger
-	cfg       *config.VulkanConfig
+	// K8sClientset streams the pod logs of the control-plane cluster
+	K8sClientset kubernetes.Interface
+	EventBus     *events.EventBus
+	log          *zap.Logger
+	cfg          *config.VulkanConfig
 }
 
 func initializeDependencies() *DependencyContainer {
@@ -107,6 +111,10 @@ func initializeDependencies() *DependencyContainer {
 	if err != nil {
 		log.Fatal("Failed to create Kubernetes client", zap.Error(err))
 	}
+	k8sClientset, err := k8s.NewClientset(cfg.InCluster)
+	if err != nil {
+		log.Fatal("Failed to create Kubernetes clientset", zap.Error(err))
+	}
 
 	// create event bus
 	bus, err := events.NewEventBus(cfg.NATS_URL)
@@ -115,12 +123,13 @@ func initializeDependencies() *DependencyContainer {
 	}
 
 	return &DependencyContainer{
-		Database:  database,
-		Auth:      vulkanAuth, // Use the successfully initialized auth object
-		K8sClient: k8sClient,
-		EventBus:  bus,
-		log:       log,
-		cfg:       cfg,
+		Database:     database,
+		Auth:         vulkanAuth, // Use the successfully initialized auth object
+		K8sClient:    k8sClient,
+		K8sClientset: k8sClientset,
+		EventBus:     bus,
+		log:          log,
+		cfg:          cfg,
 	}
 }
 
@@ -134,6 +143,7 @@ func main() {
 	database := applicationDependencies.Database
 	auth := applicationDependencies.Auth
 	k8sClient := applicationDependencies.K8sClient
+	k8sClientset := applicationDependencies.K8sClientset
 	bus := applicationDependencies.EventBus
 	log := applicationDependencies.log
 	cfg := applicationDependencies.cfg
@@ -147,18 +157,55 @@ func main() {
 	authService := service.NewAuthService(auth, tokenRepository, userRepository)
 	authHandler := handlers.NewAuthHandler(auth, authService)
 
+	webhookService := service.NewWebhookService(k8sClient, log)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+
+	buildService := service.NewBuildService(k8sClient, log)
+	buildHandler := handlers.NewBuildHandler(buildService)
+
+	deploymentService := service.NewDeploymentService(k8sClient, log)
+	deploymentHandler := handlers.NewDeploymentHandler(deploymentService)
+
+	previewService := service.NewPreviewService(k8sClient, log)
+	previewHandler := handlers.NewPreviewHandler(previewService)
+
+	// managed secrets are disabled until a key shared with the operator is configured
+	var secretsKey []byte
+	if cfg.SecretsKey != "" {
+		key, err := secretbox.ParseKey(cfg.SecretsKey)
+		if err != nil {
+			log.Fatal("Invalid VULKAN_SECRETS_KEY", zap.Error(err))
+		}
+		secretsKey = key
+	} else {
+		log.Warn("VULKAN_SECRETS_KEY is not set, managed secrets are disabled")
+	}
+	appSecretRepository := repository.NewAppSecretRepo(database)
+	secretService := service.NewSecretService(appSecretRepository, k8sClient, secretsKey, log)
+	secretHandler := handlers.NewSecretHandler(secretService)
+
+	logService := service.NewLogService(k8sClient, k8sClientset, log)
+	logHandler := handlers.NewLogHandler(logService)
+
 	// Set up ping route
 	r.GET("/ping", func(c *gin.Context) {
 		c.String(200, "pong")
 	})
 
 	routes.RegisterAuthRoutes(r, authHandler)
+	routes.RegisterWebhookRoutes(r, webhookHandler)
 
 	// jwt middleware
 	r.Use(middleware.RequireAuth(auth))
 	// opa middleware
 	r.Use(middleware.NewOPAAuth(*cfg))
 
+	routes.RegisterBuildRoutes(r, buildHandler)
+	routes.RegisterDeploymentRoutes(r, deploymentHandler)
+	routes.RegisterPreviewRoutes(r, previewHandler)
+	routes.RegisterSecretRoutes(r, secretHandler)
+	routes.RegisterLogRoutes(r, logHandler)
+
 	vulkanServerPort := cfg.VulkanServerPort
 	s := &http.Server{
 		Handler: r,
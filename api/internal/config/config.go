@@ -10,6 +10,7 @@ import (
 // this env var is loaded into container by the helm deployment.yaml file for the api server
 type VulkanConfig struct {
 	DBURL                                  string        `env:"VULKAN_DATABASE_URL,required"`
+	SecretsKey                             string        `env:"VULKAN_SECRETS_KEY"`
 	OIDCJWKSURL                            string        `env:"OIDC_JWKS_URL,required"`
 	VulkanServerPort                       string        `env:"VULKAN_PORT"           default:"8080"`
 	InCluster                              bool          `env:"K8S_IN_CLUSTER" default:"false"`
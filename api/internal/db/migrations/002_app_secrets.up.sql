@@ -0,0 +1,12 @@
+-- platform-managed secrets of Applications, sealed with AES-256-GCM by the api
+-- (VULKAN_SECRETS_KEY) and synced into the Application's namespace by the operator.
+-- Rows are keyed by the Application's namespace and name, as the operator sees it.
+CREATE TABLE app_secrets (
+    namespace  TEXT  NOT NULL,
+    app        TEXT  NOT NULL,
+    name       TEXT  NOT NULL,
+    value      BYTEA NOT NULL,            -- nonce || ciphertext
+    updated_by TEXT,
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (namespace, app, name)
+);
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AppSecret is a managed secret of an Application, without its value.
+type AppSecret struct {
+	Name      string
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// AppSecretRepository stores the sealed values of the managed secrets of Applications,
+// keyed by the Application's namespace and name. Values are never read back by the api.
+type AppSecretRepository interface {
+	ListAppSecrets(ctx context.Context, namespace, app string) ([]AppSecret, error)
+	UpsertAppSecret(ctx context.Context, namespace, app, name string, sealed []byte, updatedBy string) error
+	DeleteAppSecret(ctx context.Context, namespace, app, name string) (bool, error)
+}
+
+type appSecretRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAppSecretRepo(db *pgxpool.Pool) AppSecretRepository {
+	return &appSecretRepository{db: db}
+}
+
+func (r *appSecretRepository) ListAppSecrets(ctx context.Context, namespace, app string) ([]AppSecret, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT name, COALESCE(updated_by, ''), updated_at
+		FROM app_secrets
+		WHERE namespace = $1 AND app = $2
+		ORDER BY name
+	`, namespace, app)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := []AppSecret{}
+	for rows.Next() {
+		var secret AppSecret
+		if err := rows.Scan(&secret.Name, &secret.UpdatedBy, &secret.UpdatedAt); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
+
+func (r *appSecretRepository) UpsertAppSecret(ctx context.Context, namespace, app, name string, sealed []byte, updatedBy string) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO app_secrets (namespace, app, name, value, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (namespace, app, name)
+		DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = now()
+	`, namespace, app, name, sealed, updatedBy)
+	return err
+}
+
+func (r *appSecretRepository) DeleteAppSecret(ctx context.Context, namespace, app, name string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM app_secrets WHERE namespace = $1 AND app = $2 AND name = $3
+	`, namespace, app, name)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
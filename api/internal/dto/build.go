@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"time"
+
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// BuildOperation is returned once a rebuild, retry or cancel request has been recorded
+// on an Application; the operator carries it out on its next reconcile.
+type BuildOperation struct {
+	Application string    `json:"application"`
+	Operation   string    `json:"operation"`
+	Build       string    `json:"build,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	RequestedBy string    `json:"requested_by"`
+}
+
+// RollbackRequest names the earlier deployment to roll an Application back to: an image
+// reference, a commit SHA (or a prefix of at least 7 characters) or a build name.
+type RollbackRequest struct {
+	Target string `json:"target" binding:"required"`
+}
+
+// Deployments is the deployment history of an Application, together with the deployment
+// it is pinned to by a rollback and its progressive rollout, if any.
+type Deployments struct {
+	Deployments []platformv1.DeploymentRecord `json:"deployments"`
+	Pinned      *platformv1.DeploymentRecord  `json:"pinned,omitempty"`
+	Rollout     *platformv1.RolloutStatus     `json:"rollout,omitempty"`
+}
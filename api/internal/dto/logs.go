@@ -0,0 +1,34 @@
+package dto
+
+// LogOptions are the query parameters of the build and runtime log endpoints.
+type LogOptions struct {
+	// Follow keeps the stream open and sends new lines as they are written
+	Follow bool `form:"follow"`
+	// TailLines limits each container to its last lines
+	TailLines *int64 `form:"tail"`
+	// Task and Step select a pipeline task and one of its steps (build logs only)
+	Task string `form:"task"`
+	Step string `form:"step"`
+	// Pod and Container select a single replica or container (runtime logs only)
+	Pod       string `form:"pod"`
+	Container string `form:"container"`
+	// Cluster picks one of the clusters the Application runs in (runtime logs only)
+	Cluster string `form:"cluster"`
+}
+
+// LogLine is one line of container output, sent as the data of a "log" event.
+type LogLine struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Task      string `json:"task,omitempty"`
+	Step      string `json:"step,omitempty"`
+	Line      string `json:"line"`
+}
+
+// LogError reports a container whose logs could not be read, sent as the data of an
+// "error" event while the other containers keep streaming.
+type LogError struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Error     string `json:"error"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+// PreviewRequest asks for a preview environment of an Application built from a branch.
+type PreviewRequest struct {
+	Ref         string `json:"ref" binding:"required"`
+	PullRequest int32  `json:"pull_request,omitempty"`
+	// TTL is a Go duration (e.g. 24h) overriding the Application's preview TTL
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Preview is a preview environment of an Application.
+type Preview struct {
+	Name        string     `json:"name"`
+	Ref         string     `json:"ref"`
+	PullRequest int32      `json:"pull_request,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Health      string     `json:"health,omitempty"`
+	Image       string     `json:"image,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+// SecretRequest sets the value of a managed secret of an Application.
+type SecretRequest struct {
+	Value string `json:"value" binding:"required"`
+}
+
+// Secret is a managed secret of an Application. Values are write-only and never returned.
+type Secret struct {
+	Name      string    `json:"name"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
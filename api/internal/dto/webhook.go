@@ -0,0 +1,122 @@
+package dto
+
+// PushEvent is the provider-independent part of a git push webhook payload.
+type PushEvent struct {
+	// RepoURLs are the URLs the pushed repository is known by (https and ssh)
+	RepoURLs []string
+	// Ref is the full ref that was pushed to, e.g. refs/heads/main
+	Ref string
+	// Revision is the commit SHA the ref now points to
+	Revision string
+	// Deleted is true when the push deleted the ref
+	Deleted bool
+	// Pusher is the provider username of whoever pushed
+	Pusher string
+}
+
+// githubRepository is shared by GitHub and Gitea push payloads.
+type githubRepository struct {
+	CloneURL string `json:"clone_url"`
+	HTMLURL  string `json:"html_url"`
+	SSHURL   string `json:"ssh_url"`
+}
+
+// GitHubPushPayload is the body of a GitHub (and Gitea) push event.
+type GitHubPushPayload struct {
+	Ref        string           `json:"ref"`
+	After      string           `json:"after"`
+	Deleted    bool             `json:"deleted"`
+	Repository githubRepository `json:"repository"`
+	Pusher     struct {
+		Name     string `json:"name"`
+		Login    string `json:"login"`
+		Username string `json:"username"`
+	} `json:"pusher"`
+}
+
+// GitLabPushPayload is the body of a GitLab Push Hook or Tag Push Hook event.
+type GitLabPushPayload struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"`
+	UserName    string `json:"user_username"`
+	Project     struct {
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+		WebURL     string `json:"web_url"`
+	} `json:"project"`
+}
+
+// WebhookResult lists the Applications a push was recorded on.
+type WebhookResult struct {
+	Ref          string   `json:"ref"`
+	Revision     string   `json:"revision"`
+	Applications []string `json:"applications"`
+}
+
+// PullRequestEvent is the provider-independent part of a pull or merge request webhook.
+type PullRequestEvent struct {
+	// RepoURLs are the URLs the base repository is known by (https and ssh)
+	RepoURLs []string
+	// Number is the pull request number (the merge request iid on GitLab)
+	Number int32
+	// Action is one of opened, updated or closed, empty for actions that do not
+	// affect previews
+	Action string
+	// HeadRef is the branch the changes are on, BaseRef the one they target
+	HeadRef string
+	BaseRef string
+	// Revision is the head commit SHA
+	Revision string
+	// Fork is true when the head branch lives in another repository
+	Fork bool
+	// Author is the provider username of whoever triggered the event
+	Author string
+}
+
+// githubBranch is the head or base of a GitHub (and Gitea) pull request.
+type githubBranch struct {
+	Ref  string `json:"ref"`
+	SHA  string `json:"sha"`
+	Repo struct {
+		ID int64 `json:"id"`
+	} `json:"repo"`
+}
+
+// GitHubPullRequestPayload is the body of a GitHub (and Gitea) pull_request event.
+type GitHubPullRequestPayload struct {
+	Action      string `json:"action"`
+	Number      int32  `json:"number"`
+	PullRequest struct {
+		Head githubBranch `json:"head"`
+		Base githubBranch `json:"base"`
+	} `json:"pull_request"`
+	Repository githubRepository `json:"repository"`
+	Sender     struct {
+		Login    string `json:"login"`
+		Username string `json:"username"`
+	} `json:"sender"`
+}
+
+// GitLabMergeRequestPayload is the body of a GitLab Merge Request Hook event.
+type GitLabMergeRequestPayload struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+		WebURL     string `json:"web_url"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID             int32  `json:"iid"`
+		Action          string `json:"action"`
+		SourceBranch    string `json:"source_branch"`
+		TargetBranch    string `json:"target_branch"`
+		SourceProjectID int64  `json:"source_project_id"`
+		TargetProjectID int64  `json:"target_project_id"`
+		LastCommit      struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/dto"
+	"github.com/mofe64/vulkan/api/internal/service"
+)
+
+type BuildHandler interface {
+	ListBuilds() gin.HandlerFunc
+	Rebuild() gin.HandlerFunc
+	Retry() gin.HandlerFunc
+	Cancel() gin.HandlerFunc
+}
+
+type buildHandler struct {
+	buildService service.BuildService
+}
+
+func NewBuildHandler(buildService service.BuildService) BuildHandler {
+	return &buildHandler{
+		buildService: buildService,
+	}
+}
+
+func (h *buildHandler) ListBuilds() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		builds, err := h.buildService.ListBuilds(ctx, c.Param("org"), c.Param("proj"), c.Param("app"))
+		if err != nil {
+			writeBuildError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"builds": builds})
+	}
+}
+
+func (h *buildHandler) Rebuild() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		op, err := h.buildService.Rebuild(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), requester(c))
+		respondBuildOperation(c, op, err)
+	}
+}
+
+func (h *buildHandler) Retry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		op, err := h.buildService.Retry(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), c.Param("build"), requester(c))
+		respondBuildOperation(c, op, err)
+	}
+}
+
+func (h *buildHandler) Cancel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		op, err := h.buildService.Cancel(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), c.Param("build"), requester(c))
+		respondBuildOperation(c, op, err)
+	}
+}
+
+// requester names the authenticated user for the build history, by email when the
+// token carries one.
+func requester(c *gin.Context) string {
+	if email := c.GetString("user_email"); email != "" {
+		return email
+	}
+	return c.GetString("user_id")
+}
+
+func respondBuildOperation(c *gin.Context, op dto.BuildOperation, err error) {
+	if err != nil {
+		writeBuildError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, op)
+}
+
+func writeBuildError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrBuildNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrBuildNotLatest), errors.Is(err, service.ErrBuildNotRunning),
+		errors.Is(err, service.ErrBuildNotRetryable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "build operation failed", "details": err.Error()})
+	}
+}
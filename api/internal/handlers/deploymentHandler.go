@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/dto"
+	"github.com/mofe64/vulkan/api/internal/service"
+)
+
+type DeploymentHandler interface {
+	ListDeployments() gin.HandlerFunc
+	Rollback() gin.HandlerFunc
+	ClearRollback() gin.HandlerFunc
+	Promote() gin.HandlerFunc
+}
+
+type deploymentHandler struct {
+	deploymentService service.DeploymentService
+}
+
+func NewDeploymentHandler(deploymentService service.DeploymentService) DeploymentHandler {
+	return &deploymentHandler{
+		deploymentService: deploymentService,
+	}
+}
+
+func (h *deploymentHandler) ListDeployments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		deployments, err := h.deploymentService.ListDeployments(ctx, c.Param("org"), c.Param("proj"), c.Param("app"))
+		if err != nil {
+			writeDeploymentError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, deployments)
+	}
+}
+
+func (h *deploymentHandler) Rollback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.RollbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		ctx := c.Request.Context()
+		deployments, err := h.deploymentService.Rollback(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), req.Target, requester(c))
+		if err != nil {
+			writeDeploymentError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, deployments)
+	}
+}
+
+func (h *deploymentHandler) ClearRollback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if err := h.deploymentService.ClearRollback(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), requester(c)); err != nil {
+			writeDeploymentError(c, err)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func (h *deploymentHandler) Promote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		deployments, err := h.deploymentService.Promote(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), requester(c))
+		if err != nil {
+			writeDeploymentError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, deployments)
+	}
+}
+
+func writeDeploymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrRollbackTargetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotPinned), errors.Is(err, service.ErrNoRolloutInProgress),
+		errors.Is(err, service.ErrRollbackUnsupported):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "deployment operation failed", "details": err.Error()})
+	}
+}
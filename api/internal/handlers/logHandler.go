@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/dto"
+	"github.com/mofe64/vulkan/api/internal/service"
+)
+
+type LogHandler interface {
+	BuildLogs() gin.HandlerFunc
+	AppLogs() gin.HandlerFunc
+}
+
+type logHandler struct {
+	logService service.LogService
+}
+
+func NewLogHandler(logService service.LogService) LogHandler {
+	return &logHandler{
+		logService: logService,
+	}
+}
+
+func (h *logHandler) BuildLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var opts dto.LogOptions
+		if err := c.ShouldBindQuery(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+			return
+		}
+		stream := newLogStream(c)
+		err := h.logService.StreamBuildLogs(c.Request.Context(),
+			c.Param("org"), c.Param("proj"), c.Param("app"), c.Param("build"), opts, stream)
+		stream.finish(err)
+	}
+}
+
+func (h *logHandler) AppLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var opts dto.LogOptions
+		if err := c.ShouldBindQuery(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+			return
+		}
+		stream := newLogStream(c)
+		err := h.logService.StreamAppLogs(c.Request.Context(),
+			c.Param("org"), c.Param("proj"), c.Param("app"), opts, stream)
+		stream.finish(err)
+	}
+}
+
+// logStream writes log lines as server-sent "log" events, and the containers whose logs
+// could not be read as "error" events. The event stream is only started with the first
+// event, so errors found before that (unknown app, unknown cluster) are still answered
+// with a JSON error and a proper status code.
+type logStream struct {
+	c       *gin.Context
+	started bool
+}
+
+func newLogStream(c *gin.Context) *logStream {
+	return &logStream{c: c}
+}
+
+func (s *logStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.c.Writer.Header().Set("Content-Type", "text/event-stream")
+	s.c.Writer.Header().Set("Cache-Control", "no-cache")
+	s.c.Writer.Header().Set("Connection", "keep-alive")
+	// disable response buffering in nginx ingress
+	s.c.Writer.Header().Set("X-Accel-Buffering", "no")
+	s.c.Status(http.StatusOK)
+}
+
+func (s *logStream) Line(line dto.LogLine) error {
+	s.start()
+	s.c.SSEvent("log", line)
+	s.c.Writer.Flush()
+	return s.c.Request.Context().Err()
+}
+
+func (s *logStream) Error(logErr dto.LogError) error {
+	s.start()
+	s.c.SSEvent("error", logErr)
+	s.c.Writer.Flush()
+	return s.c.Request.Context().Err()
+}
+
+// finish ends the stream with an "end" event, or an "error" event when it failed midway.
+func (s *logStream) finish(err error) {
+	if err != nil && !s.started {
+		writeLogError(s.c, err)
+		return
+	}
+	s.start()
+	if err != nil {
+		s.c.SSEvent("error", gin.H{"error": err.Error()})
+	} else {
+		s.c.SSEvent("end", gin.H{})
+	}
+	s.c.Writer.Flush()
+}
+
+func writeLogError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrBuildNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnknownCluster):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to stream logs", "details": err.Error()})
+	}
+}
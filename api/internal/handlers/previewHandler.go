@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/dto"
+	"github.com/mofe64/vulkan/api/internal/service"
+)
+
+type PreviewHandler interface {
+	ListPreviews() gin.HandlerFunc
+	CreatePreview() gin.HandlerFunc
+	DeletePreview() gin.HandlerFunc
+}
+
+type previewHandler struct {
+	previewService service.PreviewService
+}
+
+func NewPreviewHandler(previewService service.PreviewService) PreviewHandler {
+	return &previewHandler{
+		previewService: previewService,
+	}
+}
+
+func (h *previewHandler) ListPreviews() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		previews, err := h.previewService.ListPreviews(ctx, c.Param("org"), c.Param("proj"), c.Param("app"))
+		if err != nil {
+			writePreviewError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"previews": previews})
+	}
+}
+
+func (h *previewHandler) CreatePreview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.PreviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		ctx := c.Request.Context()
+		preview, err := h.previewService.CreatePreview(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), req, requester(c))
+		if err != nil {
+			writePreviewError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, preview)
+	}
+}
+
+func (h *previewHandler) DeletePreview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if err := h.previewService.DeletePreview(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), c.Param("preview")); err != nil {
+			writePreviewError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func writePreviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrPreviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidPreview):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPreviewsDisabled), errors.Is(err, service.ErrPreviewConflict),
+		errors.Is(err, service.ErrPreviewNotBuilt):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "preview operation failed", "details": err.Error()})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/dto"
+	"github.com/mofe64/vulkan/api/internal/service"
+)
+
+type SecretHandler interface {
+	ListSecrets() gin.HandlerFunc
+	SetSecret() gin.HandlerFunc
+	DeleteSecret() gin.HandlerFunc
+}
+
+type secretHandler struct {
+	secretService service.SecretService
+}
+
+func NewSecretHandler(secretService service.SecretService) SecretHandler {
+	return &secretHandler{
+		secretService: secretService,
+	}
+}
+
+func (h *secretHandler) ListSecrets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		secrets, err := h.secretService.ListSecrets(ctx, c.Param("org"), c.Param("proj"), c.Param("app"))
+		if err != nil {
+			writeSecretError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"secrets": secrets})
+	}
+}
+
+func (h *secretHandler) SetSecret() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.SecretRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		ctx := c.Request.Context()
+		if err := h.secretService.SetSecret(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), c.Param("secret"), req.Value, requester(c)); err != nil {
+			writeSecretError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *secretHandler) DeleteSecret() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if err := h.secretService.DeleteSecret(ctx, c.Param("org"), c.Param("proj"), c.Param("app"), c.Param("secret"), requester(c)); err != nil {
+			writeSecretError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// writeSecretError maps service errors to responses. Errors never carry secret values.
+func writeSecretError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrApplicationNotFound), errors.Is(err, service.ErrSecretNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidSecret):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSecretsDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "secret operation failed", "details": err.Error()})
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/service"
+)
+
+// maxWebhookBodyBytes bounds the size of a payload read from an unauthenticated caller.
+const maxWebhookBodyBytes = 5 << 20
+
+type WebhookHandler interface {
+	GitEvent() gin.HandlerFunc
+}
+
+type webhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) WebhookHandler {
+	return &webhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// GitEvent receives push and pull request webhooks from the git provider named by the
+// :provider param. The raw body is read before decoding since the signature is computed
+// over it. Other events are acknowledged and ignored.
+func (h *webhookHandler) GitEvent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes))
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		result, err := h.webhookService.HandlePush(ctx, c.Param("provider"), c.Request.Header, body)
+		if errors.Is(err, service.ErrNotPushEvent) {
+			result, err = h.webhookService.HandlePullRequest(ctx, c.Param("provider"), c.Request.Header, body)
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, service.ErrNotPullRequest):
+			c.JSON(http.StatusAccepted, gin.H{"status": "ignored"})
+			return
+		case errors.Is(err, service.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrInvalidPayload):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+			return
+		case errors.Is(err, service.ErrInvalidSignature):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrPreviewConflict), errors.Is(err, service.ErrPreviewNotBuilt):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process webhook", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, result)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,16 +29,7 @@ func init() {
 // inCluster should be true when code executes in a Pod, false when running on
 // a developer laptop.
 func New(ctx context.Context, inCluster bool) (client.Client, error) {
-	var cfg *rest.Config
-	var err error
-	if inCluster {
-		// if Running INSIDE a pod → use the service-account token and the
-		//    env vars KUBERNETES_SERVICE_HOST / _PORT injected by Kubernetes
-		cfg, err = rest.InClusterConfig()
-	} else {
-		// if running outside pod (eg developer laptop) → fall back to ~/.kube/config
-		cfg, err = clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
-	}
+	cfg, err := controlPlaneConfig(inCluster)
 	// propagate error if we could not build a rest.Config
 	if err != nil {
 		return nil, err
@@ -52,12 +44,62 @@ func New(ctx context.Context, inCluster bool) (client.Client, error) {
 	return client.New(cfg, client.Options{Scheme: scheme})
 }
 
+// NewClientset is New for the typed clientset of the control-plane cluster, needed
+// for subresources the controller-runtime client cannot stream (e.g. pods/log).
+func NewClientset(inCluster bool) (kubernetes.Interface, error) {
+	cfg, err := controlPlaneConfig(inCluster)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// controlPlaneConfig builds the rest.Config of the cluster Vulkan is running in.
+func controlPlaneConfig(inCluster bool) (*rest.Config, error) {
+	if inCluster {
+		// if Running INSIDE a pod → use the service-account token and the
+		//    env vars KUBERNETES_SERVICE_HOST / _PORT injected by Kubernetes
+		return rest.InClusterConfig()
+	}
+	// if running outside pod (eg developer laptop) → fall back to ~/.kube/config
+	return clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
+}
+
 // NewRemoteFromSecret builds a client for a remote workload cluster whose
 // kubeconfig YAML is stored in a Secret *inside the control‑plane cluster*.
 //
 //	cpClient      – already‑initialised client for the control‑plane
 //	secretNS/Name – location of the Secret that holds key "kubeconfig" (bytes)
 func NewRemoteFromSecret(ctx context.Context, cpClient client.Client, secretNS, secretName string) (client.Client, error) {
+	restCfg, err := remoteConfigFromSecret(ctx, cpClient, secretNS, secretName)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(restCfg, client.Options{Scheme: scheme})
+}
+
+// NewRemoteClientsFromSecret is NewRemoteFromSecret returning the typed clientset as
+// well, needed for subresources the controller-runtime client cannot stream (e.g.
+// pods/log). Both are built from a single read of the Secret.
+func NewRemoteClientsFromSecret(ctx context.Context, cpClient client.Client, secretNS, secretName string) (client.Client, kubernetes.Interface, error) {
+	restCfg, err := remoteConfigFromSecret(ctx, cpClient, secretNS, secretName)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := client.New(restCfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, clientset, nil
+}
+
+// remoteConfigFromSecret reads the kubeconfig stored under key "kubeconfig" of a Secret
+// in the control-plane cluster.
+func remoteConfigFromSecret(ctx context.Context, cpClient client.Client, secretNS, secretName string) (*rest.Config, error) {
 	var sec corev1.Secret
 	if err := cpClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: secretNS}, &sec); err != nil {
 		return nil, fmt.Errorf("load kubeconfig secret: %w", err)
@@ -72,8 +114,7 @@ func NewRemoteFromSecret(ctx context.Context, cpClient client.Client, secretNS,
 	if err != nil {
 		return nil, fmt.Errorf("parse kubeconfig: %w", err)
 	}
-
-	return client.New(restCfg, client.Options{Scheme: scheme})
+	return restCfg, nil
 }
 
 // BuildFromBytes is exported in case a reconciler already has the raw kubeconfig
@@ -78,7 +78,7 @@ func buildOPAInput(c *gin.Context) ([]byte, error) {
 	input := map[string]any{
 		"action": act,
 		"resource": map[string]any{
-			"kind":       c.Param("kind"), // e.g. "org", "project", "application"
+			"kind":       resourceKind(c), // e.g. "org", "project", "application"
 			"org_id":     c.Param("org"),  // adjust to your router params
 			"project_id": c.Param("proj"),
 			"app_id":     c.Param("app"),
@@ -88,6 +88,22 @@ func buildOPAInput(c *gin.Context) ([]byte, error) {
 	return json.Marshal(map[string]any{"input": input})
 }
 
+// resourceKind is the :kind route param, or else the innermost resource named by the
+// route params, e.g. "application" for /orgs/:org/projects/:proj/apps/:app/logs.
+func resourceKind(c *gin.Context) string {
+	switch {
+	case c.Param("kind") != "":
+		return c.Param("kind")
+	case c.Param("app") != "":
+		return "application"
+	case c.Param("proj") != "":
+		return "project"
+	case c.Param("org") != "":
+		return "org"
+	}
+	return ""
+}
+
 // queryOPA sends the input to OPA and returns its boolean result.
 func queryOPA(ctx context.Context, client *http.Client, url string, body []byte) (bool, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
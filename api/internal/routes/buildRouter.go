@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/handlers"
+)
+
+func RegisterBuildRoutes(router *gin.Engine, buildHandler handlers.BuildHandler) {
+	buildGroup := router.Group("/orgs/:org/projects/:proj/apps/:app/builds")
+	{
+		buildGroup.GET("", buildHandler.ListBuilds())
+		buildGroup.POST("", buildHandler.Rebuild())
+		buildGroup.POST("/:build/retry", buildHandler.Retry())
+		buildGroup.POST("/:build/cancel", buildHandler.Cancel())
+	}
+}
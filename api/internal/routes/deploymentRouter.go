@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/handlers"
+)
+
+func RegisterDeploymentRoutes(router *gin.Engine, deploymentHandler handlers.DeploymentHandler) {
+	appGroup := router.Group("/orgs/:org/projects/:proj/apps/:app")
+	{
+		appGroup.GET("/deployments", deploymentHandler.ListDeployments())
+		appGroup.POST("/rollback", deploymentHandler.Rollback())
+		appGroup.DELETE("/rollback", deploymentHandler.ClearRollback())
+		appGroup.POST("/rollout/promote", deploymentHandler.Promote())
+	}
+}
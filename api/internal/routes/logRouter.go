@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/handlers"
+)
+
+func RegisterLogRoutes(router *gin.Engine, logHandler handlers.LogHandler) {
+	appGroup := router.Group("/orgs/:org/projects/:proj/apps/:app")
+	{
+		appGroup.GET("/logs", logHandler.AppLogs())
+		appGroup.GET("/builds/:build/logs", logHandler.BuildLogs())
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/handlers"
+)
+
+func RegisterPreviewRoutes(router *gin.Engine, previewHandler handlers.PreviewHandler) {
+	previewGroup := router.Group("/orgs/:org/projects/:proj/apps/:app/previews")
+	{
+		previewGroup.GET("", previewHandler.ListPreviews())
+		previewGroup.POST("", previewHandler.CreatePreview())
+		previewGroup.DELETE("/:preview", previewHandler.DeletePreview())
+	}
+}
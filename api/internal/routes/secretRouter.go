@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/handlers"
+)
+
+func RegisterSecretRoutes(router *gin.Engine, secretHandler handlers.SecretHandler) {
+	secretGroup := router.Group("/orgs/:org/projects/:proj/apps/:app/secrets")
+	{
+		secretGroup.GET("", secretHandler.ListSecrets())
+		secretGroup.PUT("/:secret", secretHandler.SetSecret())
+		secretGroup.DELETE("/:secret", secretHandler.DeleteSecret())
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mofe64/vulkan/api/internal/handlers"
+)
+
+// RegisterWebhookRoutes must be called before the auth middleware is installed: git
+// providers cannot present a user token, each request is verified against the
+// webhook secret of the Applications it targets instead.
+func RegisterWebhookRoutes(router *gin.Engine, webhookHandler handlers.WebhookHandler) {
+	webhookGroup := router.Group("/webhooks")
+	{
+		webhookGroup.POST("/git/:provider", webhookHandler.GitEvent())
+	}
+}
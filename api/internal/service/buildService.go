@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mofe64/vulkan/api/internal/dto"
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Build operations recorded on an Application.
+const (
+	BuildOperationRebuild = "rebuild"
+	BuildOperationRetry   = "retry"
+	BuildOperationCancel  = "cancel"
+)
+
+var (
+	ErrApplicationNotFound = errors.New("application not found")
+	ErrBuildNotFound       = errors.New("build not found")
+	ErrBuildNotLatest      = errors.New("only the latest build can be retried or cancelled")
+	ErrBuildNotRunning     = errors.New("build is not running")
+	ErrBuildNotRetryable   = errors.New("only failed or cancelled builds can be retried")
+)
+
+type BuildService interface {
+	ListBuilds(ctx context.Context, orgID, projectID, appName string) ([]platformv1.BuildRecord, error)
+	Rebuild(ctx context.Context, orgID, projectID, appName, requestedBy string) (dto.BuildOperation, error)
+	Retry(ctx context.Context, orgID, projectID, appName, build, requestedBy string) (dto.BuildOperation, error)
+	Cancel(ctx context.Context, orgID, projectID, appName, build, requestedBy string) (dto.BuildOperation, error)
+}
+
+type buildService struct {
+	k8s    client.Client
+	logger *zap.Logger
+}
+
+func NewBuildService(k8s client.Client, logger *zap.Logger) BuildService {
+	return &buildService{
+		k8s:    k8s,
+		logger: logger,
+	}
+}
+
+func (s *buildService) ListBuilds(ctx context.Context, orgID, projectID, appName string) ([]platformv1.BuildRecord, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return nil, err
+	}
+	if app.Status.Builds == nil {
+		return []platformv1.BuildRecord{}, nil
+	}
+	return app.Status.Builds, nil
+}
+
+func (s *buildService) Rebuild(ctx context.Context, orgID, projectID, appName, requestedBy string) (dto.BuildOperation, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.BuildOperation{}, err
+	}
+	return s.request(ctx, app, BuildOperationRebuild, "", platformv1.RebuildRequestedAtAnnotation, requestedBy)
+}
+
+func (s *buildService) Retry(ctx context.Context, orgID, projectID, appName, build, requestedBy string) (dto.BuildOperation, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.BuildOperation{}, err
+	}
+	latest, err := latestBuild(app, build)
+	if err != nil {
+		return dto.BuildOperation{}, err
+	}
+	if latest.Outcome != platformv1.BuildOutcomeFailed && latest.Outcome != platformv1.BuildOutcomeCancelled {
+		return dto.BuildOperation{}, ErrBuildNotRetryable
+	}
+	return s.request(ctx, app, BuildOperationRetry, build, platformv1.RetryRequestedAtAnnotation, requestedBy)
+}
+
+func (s *buildService) Cancel(ctx context.Context, orgID, projectID, appName, build, requestedBy string) (dto.BuildOperation, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.BuildOperation{}, err
+	}
+	latest, err := latestBuild(app, build)
+	if err != nil {
+		return dto.BuildOperation{}, err
+	}
+	if latest.Outcome != platformv1.BuildOutcomeRunning {
+		return dto.BuildOperation{}, ErrBuildNotRunning
+	}
+	return s.request(ctx, app, BuildOperationCancel, build, platformv1.CancelRequestedAtAnnotation, requestedBy)
+}
+
+// request stamps the operation's annotation with the current time, together with who
+// asked for it, which the operator picks up on its next reconcile.
+func (s *buildService) request(ctx context.Context, app *platformv1.Application, operation, build, annotation, requestedBy string) (dto.BuildOperation, error) {
+	now := time.Now().UTC()
+	patch := client.MergeFrom(app.DeepCopy())
+	if app.Annotations == nil {
+		app.Annotations = map[string]string{}
+	}
+	app.Annotations[annotation] = now.Format(time.RFC3339Nano)
+	app.Annotations[platformv1.RequestedByAnnotation] = requestedBy
+	if err := s.k8s.Patch(ctx, app, patch); err != nil {
+		return dto.BuildOperation{}, fmt.Errorf("failed to request %s of application %s: %w", operation, app.Name, err)
+	}
+
+	s.logger.Info("build operation requested",
+		zap.String("application", client.ObjectKeyFromObject(app).String()),
+		zap.String("operation", operation),
+		zap.String("requested_by", requestedBy))
+	return dto.BuildOperation{
+		Application: app.Name,
+		Operation:   operation,
+		Build:       build,
+		RequestedAt: now,
+		RequestedBy: requestedBy,
+	}, nil
+}
+
+// latestBuild returns the newest entry of the Application's build history, which must be
+// the named build: only one build runs at a time, so older builds are already finished.
+func latestBuild(app *platformv1.Application, build string) (platformv1.BuildRecord, error) {
+	for i, record := range app.Status.Builds {
+		if record.PipelineRun != build {
+			continue
+		}
+		if i != 0 {
+			return record, ErrBuildNotLatest
+		}
+		return record, nil
+	}
+	return platformv1.BuildRecord{}, ErrBuildNotFound
+}
+
+// getApplication finds the Application CR of an app by its org, project and name.
+// Applications live in their project's namespace, which is not known here, so they are
+// selected by the org and project labels the operator sets from their refs instead.
+func getApplication(ctx context.Context, k8s client.Client, orgID, projectID, appName string) (*platformv1.Application, error) {
+	if len(validation.IsValidLabelValue(orgID)) > 0 || len(validation.IsValidLabelValue(projectID)) > 0 {
+		return nil, ErrApplicationNotFound
+	}
+	var apps platformv1.ApplicationList
+	if err := k8s.List(ctx, &apps, client.MatchingLabels{
+		platformv1.OrgLabel:     orgID,
+		platformv1.ProjectLabel: projectID,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list applications: %w", err)
+	}
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if app.Name == appName && app.Spec.OrgRef == orgID && app.Spec.ProjectRef == projectID {
+			return app, nil
+		}
+	}
+	return nil, ErrApplicationNotFound
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetApplication(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := platformv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	app := func(namespace, org, project string) *platformv1.Application {
+		return &platformv1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Labels: map[string]string{
+				platformv1.OrgLabel:     org,
+				platformv1.ProjectLabel: project,
+			}},
+			Spec: platformv1.ApplicationSpec{OrgRef: org, ProjectRef: project},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(app("proj-a", "org", "a"), app("proj-b", "org", "b")).
+		Build()
+
+	got, err := getApplication(context.Background(), c, "org", "b", "web")
+	if err != nil {
+		t.Fatalf("getApplication() error = %v", err)
+	}
+	if got.Namespace != "proj-b" {
+		t.Errorf("getApplication() namespace = %q, want proj-b", got.Namespace)
+	}
+
+	for _, tt := range []struct{ org, project, name string }{
+		{"org", "c", "web"},
+		{"org", "a", "api"},
+		{"org", "not a label/value", "web"},
+	} {
+		if _, err := getApplication(context.Background(), c, tt.org, tt.project, tt.name); !errors.Is(err, ErrApplicationNotFound) {
+			t.Errorf("getApplication(%q, %q, %q) error = %v, want %v", tt.org, tt.project, tt.name, err, ErrApplicationNotFound)
+		}
+	}
+}
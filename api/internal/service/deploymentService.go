@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mofe64/vulkan/api/internal/dto"
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"go.uber.org/zap"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	ErrRollbackTargetNotFound = errors.New("no earlier deployment or successful build matches the rollback target")
+	ErrRollbackUnsupported    = errors.New("applications deployed by Argo CD are rolled back by reverting the GitOps repository")
+	ErrNotPinned              = errors.New("application is not rolled back")
+	ErrNoRolloutInProgress    = errors.New("no rollout is waiting to be promoted")
+)
+
+type DeploymentService interface {
+	ListDeployments(ctx context.Context, orgID, projectID, appName string) (dto.Deployments, error)
+	Rollback(ctx context.Context, orgID, projectID, appName, target, requestedBy string) (dto.Deployments, error)
+	ClearRollback(ctx context.Context, orgID, projectID, appName, requestedBy string) error
+	Promote(ctx context.Context, orgID, projectID, appName, requestedBy string) (dto.Deployments, error)
+}
+
+type deploymentService struct {
+	k8s    client.Client
+	logger *zap.Logger
+}
+
+func NewDeploymentService(k8s client.Client, logger *zap.Logger) DeploymentService {
+	return &deploymentService{
+		k8s:    k8s,
+		logger: logger,
+	}
+}
+
+func (s *deploymentService) ListDeployments(ctx context.Context, orgID, projectID, appName string) (dto.Deployments, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.Deployments{}, err
+	}
+	return deploymentsOf(app), nil
+}
+
+// Rollback pins the Application to an earlier deployment through the rollback annotation.
+// The target is checked against the history here so a typo is reported to the caller
+// rather than only as a condition on the Application.
+func (s *deploymentService) Rollback(ctx context.Context, orgID, projectID, appName, target, requestedBy string) (dto.Deployments, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.Deployments{}, err
+	}
+	if app.Spec.Deploy.Backend == platformv1.DeployBackendArgoCD {
+		return dto.Deployments{}, ErrRollbackUnsupported
+	}
+	if _, ok := app.Status.FindRollbackTarget(target); !ok {
+		return dto.Deployments{}, ErrRollbackTargetNotFound
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	if app.Annotations == nil {
+		app.Annotations = map[string]string{}
+	}
+	app.Annotations[platformv1.RollbackToAnnotation] = target
+	app.Annotations[platformv1.RequestedByAnnotation] = requestedBy
+	if err := s.k8s.Patch(ctx, app, patch); err != nil {
+		return dto.Deployments{}, fmt.Errorf("failed to roll back application %s: %w", app.Name, err)
+	}
+
+	s.logger.Info("rollback requested",
+		zap.String("application", client.ObjectKeyFromObject(app).String()),
+		zap.String("target", target),
+		zap.String("requested_by", requestedBy))
+	return deploymentsOf(app), nil
+}
+
+// ClearRollback removes the rollback annotation, after which the operator deploys the
+// latest built image again.
+func (s *deploymentService) ClearRollback(ctx context.Context, orgID, projectID, appName, requestedBy string) error {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return err
+	}
+	if _, ok := app.Annotations[platformv1.RollbackToAnnotation]; !ok {
+		return ErrNotPinned
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	delete(app.Annotations, platformv1.RollbackToAnnotation)
+	app.Annotations[platformv1.RequestedByAnnotation] = requestedBy
+	if err := s.k8s.Patch(ctx, app, patch); err != nil {
+		return fmt.Errorf("failed to clear rollback of application %s: %w", app.Name, err)
+	}
+
+	s.logger.Info("rollback cleared",
+		zap.String("application", client.ObjectKeyFromObject(app).String()),
+		zap.String("requested_by", requestedBy))
+	return nil
+}
+
+// Promote moves a progressive rollout on past its current pause: a canary goes to its
+// next step, a blue-green rollout switches traffic to the new image.
+func (s *deploymentService) Promote(ctx context.Context, orgID, projectID, appName, requestedBy string) (dto.Deployments, error) {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.Deployments{}, err
+	}
+	rollout := app.Status.Rollout
+	if rollout == nil || (rollout.Phase != platformv1.RolloutPhaseProgressing && rollout.Phase != platformv1.RolloutPhasePaused) {
+		return dto.Deployments{}, ErrNoRolloutInProgress
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	if app.Annotations == nil {
+		app.Annotations = map[string]string{}
+	}
+	app.Annotations[platformv1.PromoteRequestedAtAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	app.Annotations[platformv1.RequestedByAnnotation] = requestedBy
+	if err := s.k8s.Patch(ctx, app, patch); err != nil {
+		return dto.Deployments{}, fmt.Errorf("failed to promote rollout of application %s: %w", app.Name, err)
+	}
+
+	s.logger.Info("rollout promotion requested",
+		zap.String("application", client.ObjectKeyFromObject(app).String()),
+		zap.String("image", rollout.Image),
+		zap.String("requested_by", requestedBy))
+	return deploymentsOf(app), nil
+}
+
+func deploymentsOf(app *platformv1.Application) dto.Deployments {
+	deployments := app.Status.Deployments
+	if deployments == nil {
+		deployments = []platformv1.DeploymentRecord{}
+	}
+	return dto.Deployments{Deployments: deployments, Pinned: app.Status.Pinned, Rollout: app.Status.Rollout}
+}
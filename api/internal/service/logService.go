@@ -0,0 +1,374 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mofe64/vulkan/api/internal/dto"
+	"github.com/mofe64/vulkan/api/internal/k8s"
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Labels Tekton propagates onto the pods of a PipelineRun.
+const (
+	tektonPipelineRunLabel  = "tekton.dev/pipelineRun"
+	tektonPipelineTaskLabel = "tekton.dev/pipelineTask"
+)
+
+// tektonStepPrefix prefixes the container of every Tekton step.
+const tektonStepPrefix = "step-"
+
+// defaultRuntimeTailLines bounds runtime logs when no tail is requested, long-running
+// pods would otherwise replay their whole output.
+const defaultRuntimeTailLines int64 = 100
+
+// logPollInterval is how often a followed build is checked for new task pods and steps.
+const logPollInterval = 2 * time.Second
+
+// clusterTypeAttached is the Cluster type of the control-plane cluster itself.
+const clusterTypeAttached = "attached"
+
+var ErrUnknownCluster = errors.New("application does not run in the requested cluster")
+
+// LogEmitter receives the streamed lines, and the errors of the containers whose logs
+// could not be read while the others keep streaming. An error returned by either stops
+// the stream.
+type LogEmitter interface {
+	Line(dto.LogLine) error
+	Error(dto.LogError) error
+}
+
+type LogService interface {
+	// StreamBuildLogs streams the step logs of a build PipelineRun, task by task.
+	StreamBuildLogs(ctx context.Context, orgID, projectID, appName, build string, opts dto.LogOptions, emit LogEmitter) error
+	// StreamAppLogs streams the logs of the Application's running pods, interleaved.
+	StreamAppLogs(ctx context.Context, orgID, projectID, appName string, opts dto.LogOptions, emit LogEmitter) error
+}
+
+type logService struct {
+	k8s       client.Client
+	clientset kubernetes.Interface
+	logger    *zap.Logger
+}
+
+func NewLogService(k8s client.Client, clientset kubernetes.Interface, logger *zap.Logger) LogService {
+	return &logService{
+		k8s:       k8s,
+		clientset: clientset,
+		logger:    logger,
+	}
+}
+
+// targetCluster holds the clients of the workload cluster an Application runs on.
+type targetCluster struct {
+	client    client.Client
+	clientset kubernetes.Interface
+}
+
+// controlPlane is the cluster Vulkan runs in, where builds run.
+func (s *logService) controlPlane() *targetCluster {
+	return &targetCluster{client: s.k8s, clientset: s.clientset}
+}
+
+// appTarget resolves the cluster an Application runs in: one of the clusters spec.placement
+// put it in, or else one of the clusters its project is bound to through
+// ProjectClusterBindings. clusterID picks one of them, otherwise the first by name is
+// used. Projects bound to no cluster run their unplaced Applications on the control plane,
+// as does Argo CD the unplaced Applications of the argocd backend.
+func (s *logService) appTarget(ctx context.Context, app *platformv1.Application, clusterID string) (*targetCluster, error) {
+	if app.Spec.Placement == nil && app.Spec.Deploy.Backend == platformv1.DeployBackendArgoCD && clusterID == "" {
+		return s.controlPlane(), nil
+	}
+	clusters, err := s.appClusters(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	if len(clusters) == 0 && clusterID == "" && app.Spec.Placement == nil {
+		return s.controlPlane(), nil
+	}
+	for _, name := range clusters {
+		if clusterID == "" || name == clusterID {
+			return s.connect(ctx, name)
+		}
+	}
+	return nil, ErrUnknownCluster
+}
+
+// appClusters are the names of the clusters an Application may run in, sorted: the ones
+// its status reports it placed in, through Argo CD with the argocd backend, or those its
+// project is bound to.
+func (s *logService) appClusters(ctx context.Context, app *platformv1.Application) ([]string, error) {
+	if app.Spec.Placement == nil {
+		return s.boundClusters(ctx, app.Spec.ProjectRef)
+	}
+	var clusters []string
+	for _, status := range app.Status.Clusters {
+		clusters = append(clusters, status.Cluster)
+	}
+	for _, status := range app.Status.ArgoCD {
+		if status.Cluster != "" {
+			clusters = append(clusters, status.Cluster)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// boundClusters are the names of the clusters a project is bound to, sorted.
+func (s *logService) boundClusters(ctx context.Context, projectRef string) ([]string, error) {
+	var bindings platformv1.ProjectClusterBindingList
+	if err := s.k8s.List(ctx, &bindings); err != nil {
+		return nil, fmt.Errorf("failed to list project cluster bindings: %w", err)
+	}
+	var clusters []string
+	for _, binding := range bindings.Items {
+		if binding.Spec.ProjectRef == projectRef && !slices.Contains(clusters, binding.Spec.ClusterRef) {
+			clusters = append(clusters, binding.Spec.ClusterRef)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// connect returns the clients of a cluster: the control plane's own for attached
+// clusters, ones built from the kubeconfig Secret of the Cluster for the others.
+func (s *logService) connect(ctx context.Context, name string) (*targetCluster, error) {
+	var clu platformv1.Cluster
+	if err := s.k8s.Get(ctx, client.ObjectKey{Name: name}, &clu); err != nil {
+		return nil, fmt.Errorf("failed to get cluster %s: %w", name, err)
+	}
+	if clu.Spec.Type == clusterTypeAttached {
+		return s.controlPlane(), nil
+	}
+	secretNS := clu.Spec.KubeconfigSecretNamespace
+	if secretNS == "" {
+		secretNS = "default"
+	}
+	remote, clientset, err := k8s.NewRemoteClientsFromSecret(ctx, s.k8s, secretNS, clu.Spec.KubeconfigSecretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cluster %s: %w", name, err)
+	}
+	return &targetCluster{client: remote, clientset: clientset}, nil
+}
+
+func (s *logService) StreamBuildLogs(ctx context.Context, orgID, projectID, appName, build string, opts dto.LogOptions, emit LogEmitter) error {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return err
+	}
+	if _, ok := findBuild(app, build); !ok {
+		return ErrBuildNotFound
+	}
+	// PipelineRuns run next to the Application CR
+	t := s.controlPlane()
+
+	// Task pods are created as the pipeline progresses, so a followed build is polled
+	// for new pods until its history entry is no longer Running.
+	streamed := map[string]bool{}
+	for {
+		running := false
+		if opts.Follow {
+			if app, err = getApplication(ctx, s.k8s, orgID, projectID, appName); err != nil {
+				return err
+			}
+			record, _ := findBuild(app, build)
+			running = record.Outcome == platformv1.BuildOutcomeRunning
+		}
+
+		var pods corev1.PodList
+		if err := t.client.List(ctx, &pods, client.InNamespace(app.Namespace),
+			client.MatchingLabels{tektonPipelineRunLabel: build}); err != nil {
+			return fmt.Errorf("failed to list build pods: %w", err)
+		}
+		sort.Slice(pods.Items, func(i, j int) bool {
+			return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+		})
+
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			task := pod.Labels[tektonPipelineTaskLabel]
+			if streamed[pod.Name] || (opts.Task != "" && task != opts.Task) {
+				continue
+			}
+			for _, container := range pod.Spec.Containers {
+				step, ok := strings.CutPrefix(container.Name, tektonStepPrefix)
+				if !ok || (opts.Step != "" && step != opts.Step) {
+					continue
+				}
+				started, err := s.waitForContainer(ctx, t, pod, container.Name, opts.Follow)
+				if err != nil {
+					return err
+				}
+				if !started {
+					continue
+				}
+				meta := dto.LogLine{Pod: pod.Name, Container: container.Name, Task: task, Step: step}
+				if err := streamContainer(ctx, t.clientset, pod, container.Name, opts.Follow, opts.TailLines, meta, emit); err != nil {
+					return err
+				}
+			}
+			streamed[pod.Name] = true
+		}
+
+		if !running {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+func (s *logService) StreamAppLogs(ctx context.Context, orgID, projectID, appName string, opts dto.LogOptions, emit LogEmitter) error {
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return err
+	}
+	t, err := s.appTarget(ctx, app, opts.Cluster)
+	if err != nil {
+		return err
+	}
+
+	// every pod the operator runs for the application carries its name: the pods of its
+	// Deployments and of the Jobs of cron and job applications, and the build pods,
+	// which inherit the labels of their PipelineRun
+	selector, err := labels.Parse(fmt.Sprintf("app.kubernetes.io/name=%s,%s=%s,app.kubernetes.io/component!=build",
+		app.Name, platformv1.ManagedByLabel, platformv1.ManagedByValue))
+	if err != nil {
+		return err
+	}
+	var pods corev1.PodList
+	if err := t.client.List(ctx, &pods, client.InNamespace(app.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return fmt.Errorf("failed to list application pods: %w", err)
+	}
+
+	tail := opts.TailLines
+	if tail == nil {
+		tail = new(int64)
+		*tail = defaultRuntimeTailLines
+	}
+
+	// pods are streamed concurrently, emit is not safe for concurrent use
+	serial := &serialEmitter{emit: emit}
+
+	// a container whose logs cannot be read is reported right away, without holding up
+	// the others until they end
+	var wg sync.WaitGroup
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if opts.Pod != "" && pod.Name != opts.Pod {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if opts.Container != "" && container.Name != opts.Container {
+				continue
+			}
+			wg.Add(1)
+			go func(pod *corev1.Pod, container string) {
+				defer wg.Done()
+				meta := dto.LogLine{Pod: pod.Name, Container: container}
+				if err := streamContainer(ctx, t.clientset, pod, container, opts.Follow, tail, meta, serial); err != nil && ctx.Err() == nil {
+					_ = serial.Error(dto.LogError{Pod: pod.Name, Container: container, Error: err.Error()})
+				}
+			}(pod, container.Name)
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+// serialEmitter serializes the calls of the goroutines streaming several containers.
+type serialEmitter struct {
+	mu   sync.Mutex
+	emit LogEmitter
+}
+
+func (e *serialEmitter) Line(line dto.LogLine) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.emit.Line(line)
+}
+
+func (e *serialEmitter) Error(logErr dto.LogError) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.emit.Error(logErr)
+}
+
+// waitForContainer reports whether a container has started, so its logs can be read.
+// When following it polls until the container starts or its pod finishes without
+// running it (e.g. a step skipped after an earlier one failed).
+func (s *logService) waitForContainer(ctx context.Context, t *targetCluster, pod *corev1.Pod, container string, follow bool) (bool, error) {
+	for {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == container && (status.State.Running != nil || status.State.Terminated != nil) {
+				return true, nil
+			}
+		}
+		if !follow || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-time.After(logPollInterval):
+		}
+		if err := t.client.Get(ctx, client.ObjectKeyFromObject(pod), pod); err != nil {
+			return false, fmt.Errorf("failed to get pod %s: %w", pod.Name, err)
+		}
+	}
+}
+
+// streamContainer emits the log lines of one container, tagged with meta.
+func streamContainer(ctx context.Context, cs kubernetes.Interface, pod *corev1.Pod, container string, follow bool, tail *int64, meta dto.LogLine, emit LogEmitter) error {
+	req := cs.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+		TailLines: tail,
+	})
+	stream, err := req.Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open logs of %s/%s: %w", pod.Name, container, err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := meta
+		line.Line = scanner.Text()
+		if err := emit.Line(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs of %s/%s: %w", pod.Name, container, err)
+	}
+	return nil
+}
+
+// findBuild returns the entry of the Application's build history for a PipelineRun.
+func findBuild(app *platformv1.Application, build string) (platformv1.BuildRecord, bool) {
+	for _, record := range app.Status.Builds {
+		if record.PipelineRun == build {
+			return record, true
+		}
+	}
+	return platformv1.BuildRecord{}, false
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mofe64/vulkan/api/internal/dto"
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// maxPreviewNameLength leaves room in the names of the objects the operator derives from
+// an Application name (Services are limited to 63 characters).
+const maxPreviewNameLength = 40
+
+var (
+	ErrPreviewsDisabled = errors.New("previews are not enabled for this application")
+	ErrPreviewNotFound  = errors.New("preview not found")
+	ErrPreviewConflict  = errors.New("an application with the preview's name already exists")
+	ErrInvalidPreview   = errors.New("invalid preview request")
+	ErrPreviewNotBuilt  = errors.New("applications deploying a prebuilt image cannot be previewed")
+)
+
+type PreviewService interface {
+	ListPreviews(ctx context.Context, orgID, projectID, appName string) ([]dto.Preview, error)
+	CreatePreview(ctx context.Context, orgID, projectID, appName string, req dto.PreviewRequest, requestedBy string) (dto.Preview, error)
+	DeletePreview(ctx context.Context, orgID, projectID, appName, preview string) error
+}
+
+type previewService struct {
+	k8s    client.Client
+	logger *zap.Logger
+}
+
+func NewPreviewService(k8s client.Client, logger *zap.Logger) PreviewService {
+	return &previewService{
+		k8s:    k8s,
+		logger: logger,
+	}
+}
+
+func (s *previewService) ListPreviews(ctx context.Context, orgID, projectID, appName string) ([]dto.Preview, error) {
+	parent, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return nil, err
+	}
+	var apps platformv1.ApplicationList
+	if err := s.k8s.List(ctx, &apps, client.InNamespace(parent.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list applications: %w", err)
+	}
+	previews := []dto.Preview{}
+	for i := range apps.Items {
+		if app := &apps.Items[i]; isPreviewOf(app, parent) {
+			previews = append(previews, previewOf(app))
+		}
+	}
+	return previews, nil
+}
+
+func (s *previewService) CreatePreview(ctx context.Context, orgID, projectID, appName string, req dto.PreviewRequest, requestedBy string) (dto.Preview, error) {
+	parent, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return dto.Preview{}, err
+	}
+	var ttl *metav1.Duration
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			return dto.Preview{}, fmt.Errorf("%w: ttl must be a positive duration", ErrInvalidPreview)
+		}
+		ttl = &metav1.Duration{Duration: d}
+	}
+
+	preview, err := ensurePreview(ctx, s.k8s, parent, req.Ref, req.PullRequest, "", ttl, requestedBy)
+	if err != nil {
+		return dto.Preview{}, err
+	}
+	s.logger.Info("preview created",
+		zap.String("application", client.ObjectKeyFromObject(parent).String()),
+		zap.String("preview", preview.Name),
+		zap.String("ref", req.Ref),
+		zap.String("requested_by", requestedBy))
+	return previewOf(preview), nil
+}
+
+func (s *previewService) DeletePreview(ctx context.Context, orgID, projectID, appName, preview string) error {
+	parent, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return err
+	}
+	if err := deletePreview(ctx, s.k8s, parent, preview); err != nil {
+		return err
+	}
+	s.logger.Info("preview deleted",
+		zap.String("application", client.ObjectKeyFromObject(parent).String()),
+		zap.String("preview", preview))
+	return nil
+}
+
+// ensurePreview creates the preview of parent for a branch (and pull request), or points
+// an existing one at the branch. A known head revision is recorded on it so the operator
+// builds exactly that commit, the same way pushes are recorded.
+func ensurePreview(ctx context.Context, k8s client.Client, parent *platformv1.Application, ref string, pullRequest int32, revision string, ttl *metav1.Duration, requestedBy string) (*platformv1.Application, error) {
+	if parent.Spec.Previews == nil || parent.Spec.Preview != nil {
+		return nil, ErrPreviewsDisabled
+	}
+	if parent.Spec.Image != nil {
+		return nil, ErrPreviewNotBuilt
+	}
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("%w: ref is required", ErrInvalidPreview)
+	}
+
+	name := previewName(parent.Name, ref, pullRequest)
+	existing := &platformv1.Application{}
+	err := k8s.Get(ctx, client.ObjectKey{Namespace: parent.Namespace, Name: name}, existing)
+	if err == nil {
+		if !isPreviewOf(existing, parent) {
+			return nil, ErrPreviewConflict
+		}
+		patch := client.MergeFrom(existing.DeepCopy())
+		existing.Spec.Build.Ref = ref
+		if ttl != nil {
+			existing.Spec.Preview.TTL = ttl
+		}
+		recordPreviewRevision(existing, ref, revision, requestedBy)
+		if err := k8s.Patch(ctx, existing, patch); err != nil {
+			return nil, fmt.Errorf("failed to update preview %s: %w", name, err)
+		}
+		return existing, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get preview %s: %w", name, err)
+	}
+
+	// a preview runs the parent's spec on another branch, as a single replica, without
+	// progressive rollouts or the pre-deploy hook and on its own hostname only
+	preview := &platformv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: parent.Namespace},
+		Spec:       *parent.Spec.DeepCopy(),
+	}
+	preview.Spec.Build.Ref = ref
+	preview.Spec.Autoscaling = platformv1.HPAPolicy{Min: 1}
+	preview.Spec.Rollout = nil
+	preview.Spec.PreDeploy = nil
+	preview.Spec.Routes = nil
+	preview.Spec.Previews = nil
+	preview.Spec.Preview = &platformv1.PreviewSpec{Parent: parent.Name, PullRequest: pullRequest, TTL: ttl}
+	// previews get the managed secrets of their parent, later versions are set by the secret service
+	if version := parent.Annotations[platformv1.SecretsVersionAnnotation]; version != "" {
+		preview.Annotations = map[string]string{platformv1.SecretsVersionAnnotation: version}
+	}
+	recordPreviewRevision(preview, ref, revision, requestedBy)
+	if err := controllerutil.SetControllerReference(parent, preview, k8s.Scheme()); err != nil {
+		return nil, err
+	}
+	if err := k8s.Create(ctx, preview); err != nil {
+		return nil, fmt.Errorf("failed to create preview %s: %w", name, err)
+	}
+	return preview, nil
+}
+
+// deletePreview deletes a preview of parent, the operator garbage collects its workload.
+func deletePreview(ctx context.Context, k8s client.Client, parent *platformv1.Application, name string) error {
+	preview := &platformv1.Application{}
+	if err := k8s.Get(ctx, client.ObjectKey{Namespace: parent.Namespace, Name: name}, preview); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ErrPreviewNotFound
+		}
+		return fmt.Errorf("failed to get preview %s: %w", name, err)
+	}
+	if !isPreviewOf(preview, parent) {
+		return ErrPreviewNotFound
+	}
+	if err := k8s.Delete(ctx, preview); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete preview %s: %w", name, err)
+	}
+	return nil
+}
+
+func recordPreviewRevision(preview *platformv1.Application, ref, revision, requestedBy string) {
+	if revision == "" {
+		return
+	}
+	if preview.Annotations == nil {
+		preview.Annotations = map[string]string{}
+	}
+	preview.Annotations[platformv1.SourceRefAnnotation] = ref
+	preview.Annotations[platformv1.SourceRevisionAnnotation] = revision
+	preview.Annotations[platformv1.SourcePushedByAnnotation] = requestedBy
+}
+
+func isPreviewOf(app, parent *platformv1.Application) bool {
+	return app.Namespace == parent.Namespace && app.Spec.Preview != nil && app.Spec.Preview.Parent == parent.Name
+}
+
+// previewName names the preview of a pull request <parent>-pr-<n>, and that of a branch
+// <parent>-<branch>. Names too long are shortened and suffixed with a hash of the ref.
+func previewName(parent, ref string, pullRequest int32) string {
+	suffix := "pr-" + strconv.Itoa(int(pullRequest))
+	if pullRequest <= 0 {
+		suffix = slugify(ref)
+	}
+	name := parent + "-" + suffix
+	if len(name) <= maxPreviewNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(ref))
+	hash := hex.EncodeToString(sum[:])[:6]
+	return strings.TrimRight(name[:maxPreviewNameLength-len(hash)-1], "-") + "-" + hash
+}
+
+// slugify lowercases s and replaces runs of characters not allowed in a DNS label with a dash.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(s) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+func previewOf(app *platformv1.Application) dto.Preview {
+	preview := dto.Preview{
+		Name:        app.Name,
+		Ref:         app.Spec.Build.Ref,
+		PullRequest: app.Spec.Preview.PullRequest,
+		URL:         app.Status.URL,
+		Health:      app.Status.Health,
+		Image:       app.Status.Image,
+	}
+	if app.Status.ExpiresAt != nil {
+		expiresAt := app.Status.ExpiresAt.Time
+		preview.ExpiresAt = &expiresAt
+	}
+	return preview
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mofe64/vulkan/api/internal/db/repository"
+	"github.com/mofe64/vulkan/api/internal/dto"
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/pkg/secretbox"
+	"go.uber.org/zap"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxSecretValueSize keeps the managed secrets of an Application well within the 1MiB
+// limit of the Secret the operator syncs them into.
+const maxSecretValueSize = 64 << 10
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var (
+	ErrSecretsDisabled = errors.New("managed secrets are not enabled on this platform")
+	ErrSecretNotFound  = errors.New("secret not found")
+	ErrInvalidSecret   = errors.New("invalid secret")
+)
+
+type SecretService interface {
+	ListSecrets(ctx context.Context, orgID, projectID, appName string) ([]dto.Secret, error)
+	SetSecret(ctx context.Context, orgID, projectID, appName, name, value, requestedBy string) error
+	DeleteSecret(ctx context.Context, orgID, projectID, appName, name, requestedBy string) error
+}
+
+type secretService struct {
+	repo   repository.AppSecretRepository
+	k8s    client.Client
+	key    []byte
+	logger *zap.Logger
+}
+
+// NewSecretService returns a SecretService sealing values with key. Without a key every
+// call fails with ErrSecretsDisabled.
+func NewSecretService(repo repository.AppSecretRepository, k8s client.Client, key []byte, logger *zap.Logger) SecretService {
+	return &secretService{
+		repo:   repo,
+		k8s:    k8s,
+		key:    key,
+		logger: logger,
+	}
+}
+
+func (s *secretService) ListSecrets(ctx context.Context, orgID, projectID, appName string) ([]dto.Secret, error) {
+	app, err := s.getApplication(ctx, orgID, projectID, appName)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.repo.ListAppSecrets(ctx, app.Namespace, app.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	secrets := make([]dto.Secret, 0, len(stored))
+	for _, secret := range stored {
+		secrets = append(secrets, dto.Secret{Name: secret.Name, UpdatedBy: secret.UpdatedBy, UpdatedAt: secret.UpdatedAt})
+	}
+	return secrets, nil
+}
+
+// SetSecret seals the value and stores it, then bumps the secrets version of the
+// Application so the operator syncs it and restarts the pods.
+func (s *secretService) SetSecret(ctx context.Context, orgID, projectID, appName, name, value, requestedBy string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name must be a valid environment variable name", ErrInvalidSecret)
+	}
+	if len(value) > maxSecretValueSize {
+		return fmt.Errorf("%w: value must not exceed %d bytes", ErrInvalidSecret, maxSecretValueSize)
+	}
+	app, err := s.getApplication(ctx, orgID, projectID, appName)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := secretbox.Seal(s.key, []byte(value), secretbox.AdditionalData(app.Namespace, app.Name, name))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret %s: %w", name, err)
+	}
+	if err := s.repo.UpsertAppSecret(ctx, app.Namespace, app.Name, name, sealed, requestedBy); err != nil {
+		return fmt.Errorf("failed to store secret %s: %w", name, err)
+	}
+	if err := s.bumpSecretsVersion(ctx, app, true); err != nil {
+		return err
+	}
+
+	s.logger.Info("secret set",
+		zap.String("application", client.ObjectKeyFromObject(app).String()),
+		zap.String("secret", name),
+		zap.String("requested_by", requestedBy))
+	return nil
+}
+
+func (s *secretService) DeleteSecret(ctx context.Context, orgID, projectID, appName, name, requestedBy string) error {
+	app, err := s.getApplication(ctx, orgID, projectID, appName)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteAppSecret(ctx, app.Namespace, app.Name, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret %s: %w", name, err)
+	}
+	if !deleted {
+		return ErrSecretNotFound
+	}
+	remaining, err := s.repo.ListAppSecrets(ctx, app.Namespace, app.Name)
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+	if err := s.bumpSecretsVersion(ctx, app, len(remaining) > 0); err != nil {
+		return err
+	}
+
+	s.logger.Info("secret deleted",
+		zap.String("application", client.ObjectKeyFromObject(app).String()),
+		zap.String("secret", name),
+		zap.String("requested_by", requestedBy))
+	return nil
+}
+
+// getApplication looks up the Application whose secrets are managed. Previews share the
+// secrets of their parent and have none of their own.
+func (s *secretService) getApplication(ctx context.Context, orgID, projectID, appName string) (*platformv1.Application, error) {
+	if len(s.key) == 0 {
+		return nil, ErrSecretsDisabled
+	}
+	app, err := getApplication(ctx, s.k8s, orgID, projectID, appName)
+	if err != nil {
+		return nil, err
+	}
+	if app.Spec.Preview != nil {
+		return nil, fmt.Errorf("%w: previews use the secrets of %s", ErrInvalidSecret, app.Spec.Preview.Parent)
+	}
+	return app, nil
+}
+
+// bumpSecretsVersion sets a new secrets version on the Application and its previews, or
+// removes it once the Application has no secrets left.
+func (s *secretService) bumpSecretsVersion(ctx context.Context, app *platformv1.Application, hasSecrets bool) error {
+	var apps platformv1.ApplicationList
+	if err := s.k8s.List(ctx, &apps, client.InNamespace(app.Namespace)); err != nil {
+		return fmt.Errorf("failed to list applications: %w", err)
+	}
+	version := time.Now().UTC().Format(time.RFC3339Nano)
+	for i := range apps.Items {
+		target := &apps.Items[i]
+		if target.Name != app.Name && !isPreviewOf(target, app) {
+			continue
+		}
+		patch := client.MergeFrom(target.DeepCopy())
+		setSecretsVersion(target, version, hasSecrets)
+		if err := s.k8s.Patch(ctx, target, patch); err != nil {
+			return fmt.Errorf("failed to update application %s: %w", target.Name, err)
+		}
+	}
+	return nil
+}
+
+func setSecretsVersion(app *platformv1.Application, version string, hasSecrets bool) {
+	if !hasSecrets {
+		delete(app.Annotations, platformv1.SecretsVersionAnnotation)
+		return
+	}
+	if app.Annotations == nil {
+		app.Annotations = map[string]string{}
+	}
+	app.Annotations[platformv1.SecretsVersionAnnotation] = version
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mofe64/vulkan/api/internal/dto"
+	platformv1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Git providers accepted by the webhook receiver.
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// defaultBuildRef mirrors the operator's default for an empty spec.build.ref.
+const defaultBuildRef = "main"
+
+// zeroRevision is the "after" SHA of a push that deleted the ref.
+const zeroRevision = "0000000000000000000000000000000000000000"
+
+var (
+	ErrUnknownProvider  = errors.New("unknown git provider")
+	ErrNotPushEvent     = errors.New("not a push event")
+	ErrNotPullRequest   = errors.New("not a pull request event")
+	ErrInvalidPayload   = errors.New("invalid push payload")
+	ErrInvalidSignature = errors.New("webhook signature does not match any application")
+)
+
+type WebhookService interface {
+	// HandlePush verifies a push webhook against the secret of every Application built
+	// from the pushed repository and ref, and records the pushed commit on those whose
+	// secret matches so the operator builds it.
+	HandlePush(ctx context.Context, provider string, header http.Header, body []byte) (dto.WebhookResult, error)
+
+	// HandlePullRequest verifies a pull or merge request webhook the same way, against
+	// the Applications built from its base branch that have previews enabled, and creates,
+	// updates or deletes their preview of the pull request.
+	HandlePullRequest(ctx context.Context, provider string, header http.Header, body []byte) (dto.WebhookResult, error)
+}
+
+type webhookService struct {
+	k8s    client.Client
+	logger *zap.Logger
+}
+
+func NewWebhookService(k8s client.Client, logger *zap.Logger) WebhookService {
+	return &webhookService{
+		k8s:    k8s,
+		logger: logger,
+	}
+}
+
+func (s *webhookService) HandlePush(ctx context.Context, provider string, header http.Header, body []byte) (dto.WebhookResult, error) {
+	event, err := parsePushEvent(provider, header, body)
+	if err != nil {
+		return dto.WebhookResult{}, err
+	}
+
+	ref := shortRef(event.Ref)
+	result := dto.WebhookResult{Ref: ref, Revision: event.Revision, Applications: []string{}}
+	if event.Deleted {
+		// nothing to build when a branch or tag is deleted
+		return result, nil
+	}
+
+	repoURLs, apps, err := s.repoApplications(ctx, event.RepoURLs)
+	if err != nil {
+		return result, err
+	}
+
+	candidates := 0
+	for i := range apps {
+		app := &apps[i]
+		if app.Spec.Build.Webhook == nil || !repoURLs[platformv1.NormalizeRepoURL(app.Spec.RepoURL)] || appBuildRef(app) != ref {
+			continue
+		}
+		candidates++
+
+		secret, err := s.webhookSecret(ctx, app)
+		if err != nil {
+			s.logger.Warn("failed to read webhook secret",
+				zap.String("application", client.ObjectKeyFromObject(app).String()), zap.Error(err))
+			continue
+		}
+		if !verifySignature(provider, header, body, secret) {
+			s.logger.Warn("webhook signature mismatch",
+				zap.String("application", client.ObjectKeyFromObject(app).String()))
+			continue
+		}
+
+		if err := s.recordRevision(ctx, app, ref, event.Revision, provider+":"+event.Pusher); err != nil {
+			return result, fmt.Errorf("failed to record revision on application %s: %w", client.ObjectKeyFromObject(app), err)
+		}
+		result.Applications = append(result.Applications, client.ObjectKeyFromObject(app).String())
+	}
+
+	if candidates > 0 && len(result.Applications) == 0 {
+		return result, ErrInvalidSignature
+	}
+	return result, nil
+}
+
+func (s *webhookService) HandlePullRequest(ctx context.Context, provider string, header http.Header, body []byte) (dto.WebhookResult, error) {
+	event, err := parsePullRequestEvent(provider, header, body)
+	if err != nil {
+		return dto.WebhookResult{}, err
+	}
+
+	result := dto.WebhookResult{Ref: event.HeadRef, Revision: event.Revision, Applications: []string{}}
+	// branches of forks are not in the repository previews are built from, and building
+	// them would run untrusted code with the platform's credentials
+	if event.Action == "" || event.Fork {
+		return result, nil
+	}
+
+	repoURLs, apps, err := s.repoApplications(ctx, event.RepoURLs)
+	if err != nil {
+		return result, err
+	}
+
+	candidates, verified := 0, 0
+	for i := range apps {
+		app := &apps[i]
+		if app.Spec.Previews == nil || app.Spec.Preview != nil || app.Spec.Build.Webhook == nil ||
+			!repoURLs[platformv1.NormalizeRepoURL(app.Spec.RepoURL)] || appBuildRef(app) != event.BaseRef {
+			continue
+		}
+		candidates++
+
+		secret, err := s.webhookSecret(ctx, app)
+		if err != nil {
+			s.logger.Warn("failed to read webhook secret",
+				zap.String("application", client.ObjectKeyFromObject(app).String()), zap.Error(err))
+			continue
+		}
+		if !verifySignature(provider, header, body, secret) {
+			s.logger.Warn("webhook signature mismatch",
+				zap.String("application", client.ObjectKeyFromObject(app).String()))
+			continue
+		}
+		verified++
+
+		name := previewName(app.Name, event.HeadRef, event.Number)
+		if event.Action == PullRequestClosed {
+			if err := deletePreview(ctx, s.k8s, app, name); err != nil {
+				if errors.Is(err, ErrPreviewNotFound) {
+					continue
+				}
+				return result, err
+			}
+		} else if _, err := ensurePreview(ctx, s.k8s, app, event.HeadRef, event.Number, event.Revision, nil, provider+":"+event.Author); err != nil {
+			return result, err
+		}
+		result.Applications = append(result.Applications, app.Namespace+"/"+name)
+	}
+
+	if candidates > 0 && verified == 0 {
+		return result, ErrInvalidSignature
+	}
+	return result, nil
+}
+
+// repoApplications lists the Applications labelled with one of the repository URLs of
+// an event, and returns those URLs normalized. The label only narrows the list down, the
+// URLs are still compared.
+func (s *webhookService) repoApplications(ctx context.Context, urls []string) (map[string]bool, []platformv1.Application, error) {
+	repoURLs := make(map[string]bool, len(urls))
+	hashes := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if u == "" || repoURLs[platformv1.NormalizeRepoURL(u)] {
+			continue
+		}
+		repoURLs[platformv1.NormalizeRepoURL(u)] = true
+		hashes = append(hashes, platformv1.RepoLabelValue(u))
+	}
+	if len(hashes) == 0 {
+		return repoURLs, nil, nil
+	}
+
+	repo, err := labels.NewRequirement(platformv1.RepoLabel, selection.In, hashes)
+	if err != nil {
+		return nil, nil, err
+	}
+	var apps platformv1.ApplicationList
+	if err := s.k8s.List(ctx, &apps, client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*repo)}); err != nil {
+		return nil, nil, fmt.Errorf("failed to list applications: %w", err)
+	}
+	return repoURLs, apps.Items, nil
+}
+
+// webhookSecret reads the webhook secret referenced by the Application from its namespace.
+func (s *webhookService) webhookSecret(ctx context.Context, app *platformv1.Application) ([]byte, error) {
+	ref := app.Spec.Build.Webhook.SecretRef
+	var sec corev1.Secret
+	if err := s.k8s.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: ref.Name}, &sec); err != nil {
+		return nil, err
+	}
+	value, ok := sec.Data[ref.Key]
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("secret %s/%s missing key '%s'", app.Namespace, ref.Name, ref.Key)
+	}
+	return value, nil
+}
+
+// recordRevision annotates the Application with the pushed ref and commit, which the
+// operator picks up as a request to build that commit on behalf of pushedBy.
+// Redelivered webhooks are no-ops.
+func (s *webhookService) recordRevision(ctx context.Context, app *platformv1.Application, ref, revision, pushedBy string) error {
+	if app.Annotations[platformv1.SourceRefAnnotation] == ref &&
+		app.Annotations[platformv1.SourceRevisionAnnotation] == revision {
+		return nil
+	}
+	patch := client.MergeFrom(app.DeepCopy())
+	if app.Annotations == nil {
+		app.Annotations = map[string]string{}
+	}
+	app.Annotations[platformv1.SourceRefAnnotation] = ref
+	app.Annotations[platformv1.SourceRevisionAnnotation] = revision
+	app.Annotations[platformv1.SourcePushedByAnnotation] = pushedBy
+	return s.k8s.Patch(ctx, app, patch)
+}
+
+// parsePushEvent checks the provider's event header and decodes its push payload.
+func parsePushEvent(provider string, header http.Header, body []byte) (dto.PushEvent, error) {
+	var event dto.PushEvent
+	switch provider {
+	case ProviderGitHub, ProviderGitea:
+		eventHeader := "X-GitHub-Event"
+		if provider == ProviderGitea {
+			eventHeader = "X-Gitea-Event"
+		}
+		if header.Get(eventHeader) != "push" {
+			return event, ErrNotPushEvent
+		}
+		var payload dto.GitHubPushPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return event, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		// GitHub names the pusher, Gitea sends the full user
+		pusher := payload.Pusher.Name
+		if payload.Pusher.Login != "" {
+			pusher = payload.Pusher.Login
+		} else if payload.Pusher.Username != "" {
+			pusher = payload.Pusher.Username
+		}
+		event = dto.PushEvent{
+			RepoURLs: []string{payload.Repository.CloneURL, payload.Repository.HTMLURL, payload.Repository.SSHURL},
+			Ref:      payload.Ref,
+			Revision: payload.After,
+			Deleted:  payload.Deleted,
+			Pusher:   pusher,
+		}
+	case ProviderGitLab:
+		switch header.Get("X-Gitlab-Event") {
+		case "Push Hook", "Tag Push Hook":
+		default:
+			return event, ErrNotPushEvent
+		}
+		var payload dto.GitLabPushPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return event, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		revision := payload.CheckoutSHA
+		if revision == "" {
+			revision = payload.After
+		}
+		event = dto.PushEvent{
+			RepoURLs: []string{payload.Project.GitHTTPURL, payload.Project.WebURL, payload.Project.GitSSHURL},
+			Ref:      payload.Ref,
+			Revision: revision,
+			Pusher:   payload.UserName,
+		}
+	default:
+		return event, ErrUnknownProvider
+	}
+
+	if event.Revision == zeroRevision || event.Revision == "" {
+		event.Deleted = true
+	}
+	if event.Ref == "" {
+		return event, fmt.Errorf("%w: missing ref", ErrInvalidPayload)
+	}
+	return event, nil
+}
+
+// Pull request actions that affect previews, as normalised in dto.PullRequestEvent.
+const (
+	PullRequestOpened  = "opened"
+	PullRequestUpdated = "updated"
+	PullRequestClosed  = "closed"
+)
+
+// parsePullRequestEvent checks the provider's event header and decodes its pull or merge
+// request payload. Actions other than opening, updating, reopening, closing or merging
+// leave the event's Action empty.
+func parsePullRequestEvent(provider string, header http.Header, body []byte) (dto.PullRequestEvent, error) {
+	var event dto.PullRequestEvent
+	switch provider {
+	case ProviderGitHub, ProviderGitea:
+		eventHeader := "X-GitHub-Event"
+		if provider == ProviderGitea {
+			eventHeader = "X-Gitea-Event"
+		}
+		if header.Get(eventHeader) != "pull_request" {
+			return event, ErrNotPullRequest
+		}
+		var payload dto.GitHubPullRequestPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return event, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		author := payload.Sender.Login
+		if author == "" {
+			author = payload.Sender.Username
+		}
+		head, base := payload.PullRequest.Head, payload.PullRequest.Base
+		event = dto.PullRequestEvent{
+			RepoURLs: []string{payload.Repository.CloneURL, payload.Repository.HTMLURL, payload.Repository.SSHURL},
+			Number:   payload.Number,
+			HeadRef:  head.Ref,
+			BaseRef:  base.Ref,
+			Revision: head.SHA,
+			Fork:     head.Repo.ID != 0 && head.Repo.ID != base.Repo.ID,
+			Author:   author,
+		}
+		switch payload.Action {
+		case "opened", "reopened":
+			event.Action = PullRequestOpened
+		case "synchronize", "synchronized":
+			event.Action = PullRequestUpdated
+		case "closed":
+			event.Action = PullRequestClosed
+		}
+	case ProviderGitLab:
+		if header.Get("X-Gitlab-Event") != "Merge Request Hook" {
+			return event, ErrNotPullRequest
+		}
+		var payload dto.GitLabMergeRequestPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return event, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		attrs := payload.ObjectAttributes
+		event = dto.PullRequestEvent{
+			RepoURLs: []string{payload.Project.GitHTTPURL, payload.Project.WebURL, payload.Project.GitSSHURL},
+			Number:   attrs.IID,
+			HeadRef:  attrs.SourceBranch,
+			BaseRef:  attrs.TargetBranch,
+			Revision: attrs.LastCommit.ID,
+			Fork:     attrs.SourceProjectID != attrs.TargetProjectID,
+			Author:   payload.User.Username,
+		}
+		switch attrs.Action {
+		case "open", "reopen":
+			event.Action = PullRequestOpened
+		case "update":
+			event.Action = PullRequestUpdated
+		case "close", "merge":
+			event.Action = PullRequestClosed
+		}
+	default:
+		return event, ErrUnknownProvider
+	}
+
+	if event.Number <= 0 || event.HeadRef == "" || event.BaseRef == "" {
+		return event, fmt.Errorf("%w: missing pull request number or branches", ErrInvalidPayload)
+	}
+	return event, nil
+}
+
+// verifySignature checks the webhook against the Application's secret: GitHub and Gitea
+// sign the body with HMAC-SHA256, GitLab sends the secret itself as a token.
+func verifySignature(provider string, header http.Header, body, secret []byte) bool {
+	switch provider {
+	case ProviderGitHub:
+		return validHMAC(body, secret, strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256="))
+	case ProviderGitea:
+		return validHMAC(body, secret, header.Get("X-Gitea-Signature"))
+	case ProviderGitLab:
+		token := header.Get("X-Gitlab-Token")
+		return token != "" && subtle.ConstantTimeCompare([]byte(token), secret) == 1
+	}
+	return false
+}
+
+func validHMAC(body, secret []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// shortRef strips the refs/heads/ or refs/tags/ prefix from a pushed ref, matching the
+// branch or tag names used in spec.build.ref.
+func shortRef(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
+
+func appBuildRef(app *platformv1.Application) string {
+	if app.Spec.Build.Ref != "" {
+		return app.Spec.Build.Ref
+	}
+	return defaultBuildRef
+}
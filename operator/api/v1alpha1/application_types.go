@@ -1,23 +1,140 @@
 package v1alpha1
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// +kubebuilder:validation:XValidation:rule="!has(self.type) || self.type != 'cron' || (has(self.schedule) && size(self.schedule) > 0)",message="cron Applications require a schedule"
+// +kubebuilder:validation:XValidation:rule="!has(self.schedule) || (has(self.type) && self.type == 'cron')",message="schedule is only used by cron Applications"
+// +kubebuilder:validation:XValidation:rule="!has(self.rollout) || !has(self.type) || self.type == 'web' || self.type == 'worker'",message="rollout is only supported by web and worker Applications"
+// +kubebuilder:validation:XValidation:rule="!has(self.preDeploy) || !has(self.type) || self.type == 'web' || self.type == 'worker'",message="preDeploy is only supported by web and worker Applications"
+// +kubebuilder:validation:XValidation:rule="!has(self.routes) || !has(self.type) || self.type == 'web'",message="routes are only supported by web Applications"
+// +kubebuilder:validation:XValidation:rule="!has(self.placement) || !has(self.rollout)",message="progressive rollouts are not supported with placement"
+// +kubebuilder:validation:XValidation:rule="!has(self.deploy) || !has(self.deploy.backend) || self.deploy.backend != 'argocd' || ((!has(self.type) || self.type == 'web') && !has(self.image) && !has(self.rollout) && !has(self.preDeploy) && !has(self.routes))",message="the argocd backend only deploys web Applications built from source, without image, rollout, preDeploy or routes"
 type ApplicationSpec struct {
-	// Git repository to build & deploy.
+	// Type is the kind of workload the image runs as: a web service exposed through a
+	// Service and routes, a worker without them, a cron job run on spec.schedule, or a
+	// job run once for every new image. Defaults to web.
+	// +kubebuilder:validation:Enum=web;worker;cron;job
+	// +kubebuilder:default=web
+	// +optional
+	Type ApplicationType `json:"type,omitempty"`
+
+	// Schedule is the cron schedule of cron Applications, in the CronJob format.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Command overrides the entrypoint of the image.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args overrides the arguments passed to the entrypoint.
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// Job configures the Jobs run for cron and job Applications, and for the pre-deploy hook.
+	// +optional
+	Job JobConfig `json:"job,omitempty"`
+
+	// PreDeploy runs a command, such as a database migration, with every new image before
+	// it is rolled out. The previous image keeps running until the command succeeds;
+	// when it fails the new image is not deployed and JobFailed is set.
+	// +optional
+	PreDeploy *PreDeployHook `json:"preDeploy,omitempty"`
+
+	// Git repository to build & deploy. Required by every strategy that builds
+	// from source, unused when deploying a prebuilt image.
 	// +kubebuilder:validation:Format=uri
-	RepoURL string `json:"repoURL"`
+	// +optional
+	RepoURL string `json:"repoURL,omitempty"`
+
+	// Build selects the build strategy and its configuration. It can be omitted
+	// when Image is set, the prebuilt strategy is then implied.
+	// +optional
+	Build BuildConfig `json:"build,omitempty"`
 
-	// Build is either buildpack or dockerfile.
-	Build BuildConfig `json:"build"`
+	// Image is an existing image built outside of the platform. It is deployed by
+	// the prebuilt strategy without running a build; tags are resolved to digests.
+	// +optional
+	Image *ImageSource `json:"image,omitempty"`
 
-	// Runtime environment variables (key=value)
+	// Runtime environment variables, set to a literal value or to a key of a Secret or
+	// ConfigMap in the Application's namespace.
 	Env []EnvVar `json:"env,omitempty"`
 
+	// EnvFrom exposes every key of a Secret or ConfigMap in the Application's namespace
+	// as an environment variable. Variables in Env take precedence. The secrets managed
+	// through the platform API are added after these.
+	// +optional
+	EnvFrom []EnvFromSource `json:"envFrom,omitempty"`
+
 	// Autoscaling policy (passed to HPA)
 	Autoscaling HPAPolicy `json:"autoscaling,omitempty"`
 
+	// Port is the container port the application listens on. Defaults to 8080.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Resources are the CPU and memory requests and limits of the application container.
+	// Unset values fall back to the defaults of the project's LimitRange. The minimum
+	// replicas times the requests must fit in the project's remaining ResourceQuota.
+	// +optional
+	Resources ResourceRequirements `json:"resources,omitempty"`
+
+	// LivenessProbe restarts the container when it fails.
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// ReadinessProbe keeps the pod out of the Service until it succeeds.
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+
+	// StartupProbe holds off the other probes until the application has started.
+	// +optional
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Rollout enables progressive delivery of new images, through canary steps or a
+	// blue-green switch. When omitted, a new image replaces the running pods at once.
+	// +optional
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+
+	// Routes expose the Application on custom hostnames, in addition to the default
+	// hostname it gets under the operator's --apps-domain. A route whose host and path
+	// are already claimed by an older Application is not served and reported through
+	// the RouteConflict condition.
+	// +kubebuilder:validation:MaxItems=20
+	// +optional
+	Routes []Route `json:"routes,omitempty"`
+
+	// Placement runs the Application in clusters its project is bound to through
+	// ProjectClusterBindings, instead of next to the CR on the control plane. The image
+	// is built once and deployed to every selected cluster.
+	// +optional
+	Placement *Placement `json:"placement,omitempty"`
+
+	// Deploy selects how the built image is deployed: rendered by the operator itself
+	// (the default), or synced by Argo CD from the GitOps repository the build updates.
+	// +optional
+	Deploy DeployConfig `json:"deploy,omitempty"`
+
+	// Previews enables preview environments: ephemeral copies of this Application built
+	// from another branch or from a pull request.
+	// +optional
+	Previews *PreviewConfig `json:"previews,omitempty"`
+
+	// Preview marks this Application as a preview environment of another one. Previews
+	// are owned by their parent and deleted once their TTL has passed.
+	// +optional
+	Preview *PreviewSpec `json:"preview,omitempty"`
+
 	// ProjectRef is the reference to the project that the application belongs to.
 	ProjectRef string `json:"projectRef"`
 
@@ -26,17 +143,315 @@ type ApplicationSpec struct {
 }
 
 type BuildConfig struct {
-	// +kubebuilder:validation:Enum=buildpack;dockerfile
-	Strategy string `json:"strategy"`
+	// Strategy is the name of a build strategy registered with the operator. The
+	// built-in strategies are dockerfile, buildpack, ko, prebuilt and pipeline.
+	// An unknown strategy sets the InvalidSpec condition. Defaults to prebuilt
+	// when spec.image is set.
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
 	// Branch or tag (defaults to main)
 	Ref string `json:"ref,omitempty"`
 	// Optional Dockerfile path, relevant only for dockerfile strategy
 	Dockerfile string `json:"dockerfile,omitempty"`
+
+	// ContextDir is the build context, relative to the repository root (defaults to ".").
+	// +kubebuilder:validation:Pattern=`^[^/].*$|^$`
+	ContextDir string `json:"contextDir,omitempty"`
+
+	// BuildArgs are passed to the Dockerfile build as --build-arg KEY=VALUE.
+	// Values must not contain newlines.
+	BuildArgs map[string]string `json:"buildArgs,omitempty"`
+
+	// Target is the Dockerfile stage to build, relevant only for dockerfile strategy.
+	Target string `json:"target,omitempty"`
+
+	// BuilderImage is the Cloud Native Buildpacks builder for the buildpack strategy
+	// (defaults to paketobuildpacks/builder:base), or the base image for the ko strategy.
+	BuilderImage string `json:"builderImage,omitempty"`
+
+	// Pipeline is the name of a user-supplied Tekton Pipeline in the Application's
+	// namespace, required by the pipeline strategy. It receives the same params and
+	// workspaces as the built-in pipelines and must publish the image-digest result.
+	Pipeline string `json:"pipeline,omitempty"`
+
+	// Env are environment variables available at build time. For the buildpack
+	// strategy they are handed to the buildpacks, for the dockerfile strategy they
+	// are passed as build args.
+	Env []BuildEnvVar `json:"env,omitempty"`
+
+	// Webhook enables builds triggered by git push webhooks sent to the API at
+	// /webhooks/git/{github,gitlab,gitea}.
+	// +optional
+	Webhook *WebhookConfig `json:"webhook,omitempty"`
+
+	// Retention bounds how many finished build PipelineRuns (and their workspace PVCs)
+	// are kept. Unset limits fall back to the operator defaults.
+	// +optional
+	Retention *BuildRetention `json:"retention,omitempty"`
+}
+
+// BuildRetention limits the finished build PipelineRuns kept per Application. The most
+// recent run is always kept, whatever its outcome, as new builds are compared to it.
+type BuildRetention struct {
+	// SucceededRunsHistoryLimit is the number of succeeded runs to keep.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SucceededRunsHistoryLimit *int32 `json:"succeededRunsHistoryLimit,omitempty"`
+
+	// FailedRunsHistoryLimit is the number of failed or cancelled runs to keep.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+}
+
+// Merge returns a copy of r with every set limit of override applied on top.
+func (r BuildRetention) Merge(override *BuildRetention) BuildRetention {
+	if override == nil {
+		return r
+	}
+	if override.SucceededRunsHistoryLimit != nil {
+		r.SucceededRunsHistoryLimit = override.SucceededRunsHistoryLimit
+	}
+	if override.FailedRunsHistoryLimit != nil {
+		r.FailedRunsHistoryLimit = override.FailedRunsHistoryLimit
+	}
+	return r
+}
+
+// WebhookConfig configures how git push webhooks for an Application are verified.
+type WebhookConfig struct {
+	// SecretRef selects the key of a Secret in the Application's namespace holding
+	// the webhook secret. GitHub and Gitea payloads must carry a valid HMAC-SHA256
+	// signature of it, GitLab payloads must send it as X-Gitlab-Token.
+	SecretRef corev1.SecretKeySelector `json:"secretRef"`
+}
+
+// NormalizeRepoURL reduces the https, ssh and scp-like forms of a repository URL to
+// host/path so they compare equal, e.g. git@github.com:org/app.git and
+// https://github.com/org/app both become github.com/org/app.
+func NormalizeRepoURL(raw string) string {
+	u := strings.ToLower(strings.TrimSpace(raw))
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+	// drop user info (git@, oauth2:token@)
+	if slash := strings.Index(u, "/"); slash >= 0 {
+		if at := strings.LastIndex(u[:slash], "@"); at >= 0 {
+			u = u[at+1:]
+		}
+	} else if at := strings.LastIndex(u, "@"); at >= 0 {
+		u = u[at+1:]
+	}
+	// host:port/path or scp-like host:path
+	if colon := strings.Index(u, ":"); colon >= 0 {
+		rest := u[colon+1:]
+		if slash := strings.Index(rest, "/"); slash > 0 && strings.Trim(rest[:slash], "0123456789") == "" {
+			rest = rest[slash+1:]
+		}
+		u = u[:colon] + "/" + rest
+	}
+	u = strings.TrimSuffix(u, "/")
+	return strings.TrimSuffix(u, ".git")
+}
+
+// RepoLabelValue returns the RepoLabel value of a repository URL, a hash of its
+// normalized form that fits a label value.
+func RepoLabelValue(repoURL string) string {
+	sum := sha256.Sum256([]byte(NormalizeRepoURL(repoURL)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ImageSource references an image built outside of the platform.
+type ImageSource struct {
+	// Reference is the image reference, by tag or by digest. A tag is resolved to
+	// its digest through the registry API when the reference changes.
+	// +kubebuilder:validation:MinLength=1
+	Reference string `json:"reference"`
+
+	// PullSecret is the name of a kubernetes.io/dockerconfigjson Secret in the
+	// Application's namespace used to resolve and pull the image.
+	// +optional
+	PullSecret string `json:"pullSecret,omitempty"`
+}
+
+// BuildEnvVar is a build-time environment variable, set either to a literal value or
+// to a key of a Secret in the Application's namespace. Secret values are injected
+// into the build pod and never written to the PipelineRun spec.
+type BuildEnvVar struct {
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Name string `json:"name"`
+
+	// Value is a literal value. Must not contain commas.
+	Value string `json:"value,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret in the Application's namespace.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
 }
 
+// EnvVar is a runtime environment variable, set either to a literal value or to a key
+// of a Secret or ConfigMap in the Application's namespace.
+// +kubebuilder:validation:XValidation:rule="!(has(self.value) && has(self.valueFrom))",message="value and valueFrom are mutually exclusive"
 type EnvVar struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
+	Name string `json:"name"`
+
+	// Value is a literal value.
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// ValueFrom selects the value from a Secret or ConfigMap.
+	// +optional
+	ValueFrom *EnvVarSource `json:"valueFrom,omitempty"`
+}
+
+// EnvVarSource selects the value of an environment variable.
+// +kubebuilder:validation:XValidation:rule="has(self.secretKeyRef) != has(self.configMapKeyRef)",message="exactly one of secretKeyRef or configMapKeyRef must be set"
+type EnvVarSource struct {
+	// SecretKeyRef selects a key of a Secret in the Application's namespace.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap in the Application's namespace.
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// EnvFromSource is a Secret or ConfigMap whose keys are all exposed as environment variables.
+// +kubebuilder:validation:XValidation:rule="has(self.secretRef) != has(self.configMapRef)",message="exactly one of secretRef or configMapRef must be set"
+type EnvFromSource struct {
+	// Prefix is prepended to the name of every variable.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// SecretRef is a Secret in the Application's namespace.
+	// +optional
+	SecretRef *corev1.SecretEnvSource `json:"secretRef,omitempty"`
+
+	// ConfigMapRef is a ConfigMap in the Application's namespace.
+	// +optional
+	ConfigMapRef *corev1.ConfigMapEnvSource `json:"configMapRef,omitempty"`
+}
+
+// Route exposes a path of the Application on a hostname.
+// +kubebuilder:validation:XValidation:rule="!has(self.port) || self.port != 80",message="port 80 is the Service port of the application port, leave port unset to route to it"
+type Route struct {
+	// Host is a fully qualified domain name pointing at the platform's ingress controller.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)+$`
+	// +kubebuilder:validation:MaxLength=253
+	Host string `json:"host"`
+
+	// Path is the path prefix routed to the Application. Defaults to /.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Port is another container port the traffic is sent to, exposed by the Service
+	// under the same number. Defaults to the port the application listens on.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// Placement selects clusters among the ones the project of the Application is bound to.
+// The selectors are combined; an empty placement selects every bound cluster.
+type Placement struct {
+	// Clusters are the names of the Cluster CRs to run in.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Regions keeps the clusters whose spec.region is one of these.
+	// +optional
+	Regions []string `json:"regions,omitempty"`
+
+	// ClusterSelector keeps the clusters whose labels match.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+}
+
+// DeployConfig selects the deployment backend of an Application.
+type DeployConfig struct {
+	// Backend is direct to have the operator render the workload itself, or argocd to
+	// have it create an Argo CD Application syncing the manifests each build commits to
+	// the GitOps repository under apps/<name>. Defaults to direct.
+	// +kubebuilder:validation:Enum=direct;argocd
+	// +kubebuilder:default=direct
+	// +optional
+	Backend DeployBackend `json:"backend,omitempty"`
+
+	// ArgoCD configures the Argo CD Applications of the argocd backend.
+	// +optional
+	ArgoCD ArgoCDConfig `json:"argocd,omitempty"`
+}
+
+// DeployBackend is the way the image of an Application is deployed.
+type DeployBackend string
+
+const (
+	DeployBackendDirect DeployBackend = "direct"
+	DeployBackendArgoCD DeployBackend = "argocd"
+)
+
+// ArgoCDConfig configures the Argo CD Applications created for an Application.
+type ArgoCDConfig struct {
+	// Project is the Argo CD AppProject of the Applications. Defaults to default.
+	// +optional
+	Project string `json:"project,omitempty"`
+
+	// ManualSync turns off automated sync: Argo CD then only reports the Application
+	// out of sync until it is synced by hand. By default it is synced automatically,
+	// pruning removed objects and reverting changes made in the cluster.
+	// +optional
+	ManualSync bool `json:"manualSync,omitempty"`
+}
+
+// ApplicationType is the kind of workload an Application runs as.
+type ApplicationType string
+
+const (
+	ApplicationTypeWeb    ApplicationType = "web"
+	ApplicationTypeWorker ApplicationType = "worker"
+	ApplicationTypeCron   ApplicationType = "cron"
+	ApplicationTypeJob    ApplicationType = "job"
+)
+
+// JobConfig bounds how a Job of the Application runs.
+type JobConfig struct {
+	// BackoffLimit is how often a failed pod is retried before the Job fails. Defaults to 0.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds is how long the Job may run before it is failed.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+}
+
+// PreDeployHook is a command run with a new image before it is rolled out.
+type PreDeployHook struct {
+	// Command is run in place of the entrypoint of the image.
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+
+	// Args are passed to the command.
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// ResourceRequirements are the compute resources of the application container.
+type ResourceRequirements struct {
+	// +optional
+	Requests ComputeResources `json:"requests,omitempty"`
+	// +optional
+	Limits ComputeResources `json:"limits,omitempty"`
+}
+
+// ComputeResources is an amount of CPU and memory.
+type ComputeResources struct {
+	// +optional
+	CPU *resource.Quantity `json:"cpu,omitempty"`
+	// +optional
+	Memory *resource.Quantity `json:"memory,omitempty"`
 }
 
 type HPAPolicy struct {
@@ -44,13 +459,402 @@ type HPAPolicy struct {
 	Max int32 `json:"maxReplicas"`
 }
 
+// PreviewConfig configures the preview environments of an Application.
+type PreviewConfig struct {
+	// TTL is how long a preview lives before the operator deletes it. Defaults to 72h.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// Domain is the wildcard domain previews get their hostname under, as
+	// <preview>.<domain>. Defaults to the operator's --preview-domain.
+	// +optional
+	Domain string `json:"domain,omitempty"`
+}
+
+// PreviewSpec identifies a preview environment and the Application it was copied from.
+// The rest of a preview's spec is a copy of its parent's with spec.build.ref overridden.
+type PreviewSpec struct {
+	// Parent is the name of the Application, in the same namespace, this one previews.
+	// +kubebuilder:validation:MinLength=1
+	Parent string `json:"parent"`
+
+	// PullRequest is the number of the pull or merge request previewed, if any.
+	// +optional
+	PullRequest int32 `json:"pullRequest,omitempty"`
+
+	// Host overrides the hostname the preview is exposed on.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// TTL overrides the parent's spec.previews.ttl.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// RolloutStrategy configures how a new image is rolled out. The new pods run in a
+// second Deployment next to the stable one until they are promoted; the rollout is
+// aborted when they do not become ready or keep restarting.
+// +kubebuilder:validation:XValidation:rule="has(self.canary) != has(self.blueGreen)",message="exactly one of canary or blueGreen must be set"
+type RolloutStrategy struct {
+	// Canary shifts traffic to the new image in steps.
+	// +optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+
+	// BlueGreen brings the new image up next to the stable one, reachable through the
+	// <app>-preview Service, and switches all traffic to it once promoted.
+	// +optional
+	BlueGreen *BlueGreenStrategy `json:"blueGreen,omitempty"`
+
+	// MaxRestarts is how often any container of the new pods may restart before the
+	// rollout is aborted. Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRestarts *int32 `json:"maxRestarts,omitempty"`
+
+	// ReadinessTimeout is how long the new pods of a step may take to become ready
+	// before the rollout is aborted. Defaults to 5m.
+	// +optional
+	ReadinessTimeout *metav1.Duration `json:"readinessTimeout,omitempty"`
+}
+
+// CanaryStrategy rolls a new image out in steps of increasing traffic weight.
+type CanaryStrategy struct {
+	// Steps are run in order; the new image replaces the stable one after the last step.
+	// +kubebuilder:validation:MinItems=1
+	Steps []CanaryStep `json:"steps"`
+}
+
+// CanaryStep sends a share of the traffic to the new image and then pauses.
+type CanaryStep struct {
+	// Weight is the percentage of the traffic sent to the new image. Traffic is split
+	// by replica count, so the weight is approximated by the canary replicas.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	Weight int32 `json:"weight"`
+
+	// Pause is how long to stay at this step once the canary pods are ready. When
+	// omitted the rollout waits for PromoteRequestedAtAnnotation.
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+}
+
+// BlueGreenStrategy has no settings yet: the new image is promoted manually through
+// PromoteRequestedAtAnnotation.
+type BlueGreenStrategy struct{}
+
+// Strategies and phases reported in ApplicationStatus.Rollout.
+const (
+	RolloutStrategyCanary    = "Canary"
+	RolloutStrategyBlueGreen = "BlueGreen"
+
+	// RolloutPhaseProgressing waits for the new pods of the current step to become ready.
+	RolloutPhaseProgressing = "Progressing"
+	// RolloutPhasePaused waits for a pause to elapse or for a promotion request.
+	RolloutPhasePaused = "Paused"
+	// RolloutPhasePromoted rolls the new image out to the stable Deployment.
+	RolloutPhasePromoted = "Promoted"
+	// RolloutPhaseSucceeded is a rollout that completed.
+	RolloutPhaseSucceeded = "Succeeded"
+	// RolloutPhaseAborted is a rollout stopped because the new pods degraded. The stable
+	// Deployment keeps the previous image until a new one is built or deployed.
+	RolloutPhaseAborted = "Aborted"
+)
+
+// RolloutStatus reports the progressive rollout of the latest image.
+type RolloutStatus struct {
+	// Strategy is Canary or BlueGreen.
+	Strategy string `json:"strategy"`
+
+	// Phase is one of Progressing, Paused, Promoted, Succeeded or Aborted.
+	Phase string `json:"phase"`
+
+	// Image is the image being rolled out.
+	Image string `json:"image"`
+
+	// StableImage is the image that was running when the rollout started.
+	StableImage string `json:"stableImage,omitempty"`
+
+	// Step is the index of the current canary step.
+	Step int32 `json:"step,omitempty"`
+
+	// Weight is the traffic weight of the current canary step.
+	Weight int32 `json:"weight,omitempty"`
+
+	// StepStartedAt is when the current step (or the blue-green preview) started.
+	StepStartedAt *metav1.Time `json:"stepStartedAt,omitempty"`
+
+	// ObservedPromotion is the value of PromoteRequestedAtAnnotation last acted upon.
+	ObservedPromotion string `json:"observedPromotion,omitempty"`
+
+	// Message explains the phase, and why the rollout was aborted.
+	Message string `json:"message,omitempty"`
+}
+
+// Phases reported in JobStatus.
+const (
+	JobPhaseRunning   = "Running"
+	JobPhaseSucceeded = "Succeeded"
+	JobPhaseFailed    = "Failed"
+)
+
+// JobStatus reports the Job run for an image: the release of a job Application, or the
+// pre-deploy hook of a web or worker Application.
+type JobStatus struct {
+	// Name is the name of the Job. Finished Jobs are deleted after a day, their outcome
+	// is kept here.
+	Name string `json:"name"`
+
+	// Image is the image the Job runs.
+	Image string `json:"image"`
+
+	// Cluster is the cluster the Job runs in, for Applications with spec.placement. The
+	// Job runs once, in the first cluster that gets to it.
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// Phase is one of Running, Succeeded or Failed.
+	Phase string `json:"phase"`
+
+	// Message explains why the Job failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// Outcomes recorded for a build in ApplicationStatus.Builds.
+const (
+	BuildOutcomeRunning   = "Running"
+	BuildOutcomeSucceeded = "Succeeded"
+	BuildOutcomeFailed    = "Failed"
+	BuildOutcomeCancelled = "Cancelled"
+)
+
+// Reasons a build was started, recorded in BuildRecord.Trigger.
+const (
+	// BuildTriggerConfigChange is the first build, or a build after the build-relevant
+	// spec or the effective build settings changed.
+	BuildTriggerConfigChange = "ConfigChange"
+	// BuildTriggerPush is a build of a commit recorded by the git webhook receiver.
+	BuildTriggerPush = "Push"
+	// BuildTriggerRebuild is a build requested through RebuildRequestedAtAnnotation.
+	BuildTriggerRebuild = "Rebuild"
+	// BuildTriggerRetry is a build requested through RetryRequestedAtAnnotation.
+	BuildTriggerRetry = "Retry"
+)
+
+// Values reported in ApplicationStatus.Health.
+const (
+	HealthHealthy     = "Healthy"
+	HealthProgressing = "Progressing"
+	HealthError       = "Error"
+)
+
+// BuildRecord is one entry of the build history kept in ApplicationStatus.
+type BuildRecord struct {
+	// PipelineRun is the name of the Tekton PipelineRun that performed the build.
+	PipelineRun string `json:"pipelineRun"`
+
+	// Outcome is one of Running, Succeeded, Failed or Cancelled.
+	Outcome string `json:"outcome"`
+
+	// Revision is the git commit SHA that was built, once known.
+	Revision string `json:"revision,omitempty"`
+
+	// Image is the image reference (by digest when available) pushed by the build.
+	Image string `json:"image,omitempty"`
+
+	// Message carries the reason reported by Tekton for failed builds.
+	Message string `json:"message,omitempty"`
+
+	// Trigger is why the build was started: ConfigChange, Push, Rebuild or Retry.
+	Trigger string `json:"trigger,omitempty"`
+
+	// TriggeredBy names who requested the build, for pushes, rebuilds and retries.
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+
+	// CancelledBy names who cancelled the build, when it was cancelled on request.
+	CancelledBy string `json:"cancelledBy,omitempty"`
+
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DeploymentRecord is one entry of the deployment history kept in ApplicationStatus:
+// an image the workload was rolled out with.
+type DeploymentRecord struct {
+	// Image is the image reference (by digest) that was deployed.
+	Image string `json:"image"`
+
+	// Revision is the git commit SHA the image was built from, when known.
+	Revision string `json:"revision,omitempty"`
+
+	// PipelineRun is the build that produced the image, when it was built by the platform.
+	PipelineRun string `json:"pipelineRun,omitempty"`
+
+	// Rollback is true when the image was deployed by a rollback.
+	Rollback bool `json:"rollback,omitempty"`
+
+	DeployedAt metav1.Time `json:"deployedAt"`
+}
+
+// minRevisionPrefix is the shortest commit SHA prefix accepted as a rollback target.
+const minRevisionPrefix = 7
+
+// MatchesRollbackTarget reports whether the deployment is the one named by a rollback
+// target: its image, the PipelineRun that built it or its commit SHA (or a prefix).
+func (r DeploymentRecord) MatchesRollbackTarget(target string) bool {
+	switch {
+	case r.Image == target:
+		return true
+	case r.PipelineRun != "" && r.PipelineRun == target:
+		return true
+	case r.Revision != "" && len(target) >= minRevisionPrefix && strings.HasPrefix(r.Revision, target):
+		return true
+	}
+	return false
+}
+
 type ApplicationStatus struct {
-	// Latest image pushed by Tekton build.
+	// Conditions represent the latest available observations
+	// of the application's build and rollout (Building, BuildFailed, Ready).
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// Latest image pushed by Tekton build, or the prebuilt image pinned by digest.
 	Image string `json:"image,omitempty"`
+	// SourceImage is the spec.image reference Image was resolved from, for prebuilt images.
+	SourceImage string `json:"sourceImage,omitempty"`
 	// git SHA deployed
 	Revision string `json:"revision,omitempty"`
 	// Healthy, Progressing, Error
 	Health string `json:"health,omitempty"`
+
+	// URL is where the Application is exposed: its default hostname, or its first
+	// route when it has none.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Routes are the hostnames and paths the Application is served on, including its
+	// default hostname, and the ones not served because of a conflict.
+	// +optional
+	Routes []RouteStatus `json:"routes,omitempty"`
+
+	// ExpiresAt is when a preview environment will be deleted.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Builds is the history of the most recent builds, newest first.
+	// +kubebuilder:validation:MaxItems=20
+	Builds []BuildRecord `json:"builds,omitempty"`
+
+	// Deployments is the history of the most recently deployed images, newest first.
+	// +kubebuilder:validation:MaxItems=20
+	Deployments []DeploymentRecord `json:"deployments,omitempty"`
+
+	// Pinned is the earlier deployment the Application was rolled back to through
+	// RollbackToAnnotation. While set, it is deployed instead of Image, and new
+	// builds are recorded but not rolled out.
+	// +optional
+	Pinned *DeploymentRecord `json:"pinned,omitempty"`
+
+	// Rollout reports the progressive rollout of the latest image, when spec.rollout is set.
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Clusters reports the Application in each cluster selected by spec.placement.
+	// +optional
+	Clusters []PlacementStatus `json:"clusters,omitempty"`
+
+	// ArgoCD reports the Argo CD Applications of the argocd backend, one per cluster.
+	// +optional
+	ArgoCD []ArgoCDStatus `json:"argocd,omitempty"`
+
+	// Job reports the Job run for the latest image: the release of a job Application, or
+	// the pre-deploy hook when spec.preDeploy is set.
+	// +optional
+	Job *JobStatus `json:"job,omitempty"`
+}
+
+// FindRollbackTarget looks the rollback target up in the deployment history and then in
+// the successful builds, so a build that was never rolled out can be deployed as well.
+func (s *ApplicationStatus) FindRollbackTarget(target string) (DeploymentRecord, bool) {
+	for _, record := range s.Deployments {
+		if record.MatchesRollbackTarget(target) {
+			return record, true
+		}
+	}
+	for _, build := range s.Builds {
+		if build.Outcome != BuildOutcomeSucceeded || build.Image == "" {
+			continue
+		}
+		record := DeploymentRecord{Image: build.Image, Revision: build.Revision, PipelineRun: build.PipelineRun}
+		if record.MatchesRollbackTarget(target) {
+			return record, true
+		}
+	}
+	return DeploymentRecord{}, false
+}
+
+// PlacementStatus is the state of an Application in one of its clusters.
+type PlacementStatus struct {
+	// Cluster is the name of the Cluster CR.
+	Cluster string `json:"cluster"`
+
+	// Region is the region of the cluster.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Health is Healthy, Progressing or Error.
+	Health string `json:"health"`
+
+	// Reason and Message are those of the Ready condition in the cluster, or explain why
+	// the workload could not be rendered there.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ArgoCDStatus is the state of an Argo CD Application syncing an Application.
+type ArgoCDStatus struct {
+	// Name is the name of the Argo CD Application, in the Argo CD namespace.
+	Name string `json:"name"`
+
+	// Cluster is the Cluster CR it deploys to, when the Application has spec.placement.
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// Sync is the Argo CD sync status: Synced, OutOfSync or Unknown.
+	// +optional
+	Sync string `json:"sync,omitempty"`
+
+	// Health is the Argo CD health status: Healthy, Progressing, Degraded, Suspended,
+	// Missing or Unknown.
+	// +optional
+	Health string `json:"health,omitempty"`
+
+	// Revision is the GitOps repository commit last synced.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Message is the Argo CD health message, or the error of the last sync operation.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RouteStatus is the state of a hostname and path of an Application.
+type RouteStatus struct {
+	Host string `json:"host"`
+	Path string `json:"path"`
+	// URL is where the route is served, empty when it is not.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// ConflictsWith is the namespace/name of the Application already serving the route.
+	// +optional
+	ConflictsWith string `json:"conflictsWith,omitempty"`
 }
 
 // +kubebuilder:object:root=true
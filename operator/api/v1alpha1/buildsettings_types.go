@@ -0,0 +1,51 @@
+package v1alpha1
+
+// BuildSettings holds the platform-level locations and credentials used by
+// Application builds. The operator has defaults for each field (set by flags);
+// an Org and a Project can override any of them, the Project taking precedence.
+// Empty fields inherit from the level above.
+type BuildSettings struct {
+	// ImageRegistry is the registry and path prefix images are pushed to,
+	// e.g. ghcr.io/acme. The Application name is appended to it.
+	// +kubebuilder:validation:Optional
+	ImageRegistry string `json:"imageRegistry,omitempty"`
+
+	// GitOpsRepoURL is the repository the build pipeline commits the new image to.
+	// +kubebuilder:validation:Optional
+	GitOpsRepoURL string `json:"gitOpsRepoURL,omitempty"`
+
+	// ServiceAccountName is the service account the build PipelineRuns run as.
+	// +kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// RegistrySecretName is the docker config Secret used to push images.
+	// +kubebuilder:validation:Optional
+	RegistrySecretName string `json:"registrySecretName,omitempty"`
+
+	// GitCredentialsSecretName is the Secret with write access to the GitOps repo.
+	// +kubebuilder:validation:Optional
+	GitCredentialsSecretName string `json:"gitCredentialsSecretName,omitempty"`
+}
+
+// Merge returns a copy of s with every non-empty field of override applied on top.
+func (s BuildSettings) Merge(override *BuildSettings) BuildSettings {
+	if override == nil {
+		return s
+	}
+	if override.ImageRegistry != "" {
+		s.ImageRegistry = override.ImageRegistry
+	}
+	if override.GitOpsRepoURL != "" {
+		s.GitOpsRepoURL = override.GitOpsRepoURL
+	}
+	if override.ServiceAccountName != "" {
+		s.ServiceAccountName = override.ServiceAccountName
+	}
+	if override.RegistrySecretName != "" {
+		s.RegistrySecretName = override.RegistrySecretName
+	}
+	if override.GitCredentialsSecretName != "" {
+		s.GitCredentialsSecretName = override.GitCredentialsSecretName
+	}
+	return s
+}
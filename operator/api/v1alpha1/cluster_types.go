@@ -24,7 +24,10 @@ type ClusterSpec struct {
 	// +kubebuilder:validation:Required
 	OrgRef string `json:"orgRef"`
 
-	// +kubebuilder:validation:Enum=attached;remote
+	// Type is attached for the cluster the platform runs in, remote for a cluster
+	// provisioned elsewhere and reached through the kubeconfig Secret, or the type of a
+	// cluster provisioned by the operator: local for a kind cluster.
+	// +kubebuilder:validation:Enum=attached;remote;local
 	Type string `json:"type"`
 
 	// Region is mandatory for managed clouds.
@@ -34,6 +37,7 @@ type ClusterSpec struct {
 	NodePools []NodePool `json:"nodePools,omitempty"`
 
 	// When Type==attached, hold secret name that contains kubeconfig.
+	// Provisioned clusters have their kubeconfig written to it, <name>-kubeconfig when empty.
 	KubeconfigSecretName string `json:"kubeconfigSecretName,omitempty"`
 
 	// default is "default"
@@ -100,6 +104,76 @@ type ClusterStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 	// Endpoint is useful for CLI ‘kubeconfig’ command.
 	Endpoint string `json:"endpoint,omitempty"`
+
+	// NodePools reports the size of each node pool of the spec.
+	// +optional
+	NodePools []NodePoolStatus `json:"nodePools,omitempty"`
+
+	// LastHeartbeatTime is when a health check last reached the cluster. Ready turns
+	// Unknown once it is older than the heartbeat timeout of the operator.
+	// +optional
+	LastHeartbeatTime *metav1.Time `json:"lastHeartbeatTime,omitempty"`
+
+	// KubernetesVersion is the version of the kubelets, the oldest one when they differ.
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// Nodes is the number of nodes of the cluster, ReadyNodes the ones reporting Ready.
+	// +optional
+	Nodes int32 `json:"nodes,omitempty"`
+	// +optional
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+
+	// Allocatable is the CPU and memory the nodes can give to pods, Requested the part
+	// of it requested by the pods running on them.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+	// +optional
+	Requested corev1.ResourceList `json:"requested,omitempty"`
+
+	// Pressure lists the nodes reporting memory, disk or PID pressure.
+	// +optional
+	Pressure []NodePressure `json:"pressure,omitempty"`
+
+	// Components reports the health of the control plane components and cluster DNS
+	// running as pods in kube-system. Managed control planes are not visible.
+	// +optional
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
+// NodePressure is a node reporting resource pressure.
+type NodePressure struct {
+	Node string `json:"node"`
+	// Conditions are the pressure conditions it reports: MemoryPressure, DiskPressure
+	// or PIDPressure.
+	Conditions []string `json:"conditions"`
+}
+
+// ComponentHealth is the health of a cluster component.
+type ComponentHealth struct {
+	// Name is the component, such as kube-apiserver or dns.
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NodePoolStatus is the observed state of a node pool. Nodes belong to the pool named
+// by their vulkan.io/node-pool label.
+type NodePoolStatus struct {
+	Name string `json:"name"`
+
+	// Desired is the size the pool should have: spec desired when set, otherwise its
+	// current size brought within minSize and maxSize.
+	Desired int32 `json:"desired"`
+
+	// Nodes is the number of nodes in the pool, ReadyNodes the ones reporting Ready.
+	Nodes      int32 `json:"nodes"`
+	ReadyNodes int32 `json:"readyNodes"`
+
+	// Message explains why the pool is not at its desired size.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -1,10 +1,24 @@
 package v1alpha1
 
 const (
-	Unknown      string = "Unknown"
-	Ready        string = "Ready"
-	Provisioning string = "Provisioning"
-	Degraded     string = "Degraded"
-	Deleting     string = "Deleting"
-	Error        string = "Error"
+	Unknown         string = "Unknown"
+	Ready           string = "Ready"
+	Provisioning    string = "Provisioning"
+	Degraded        string = "Degraded"
+	Deleting        string = "Deleting"
+	Error           string = "Error"
+	Building        string = "Building"
+	BuildFailed     string = "BuildFailed"
+	InvalidSpec     string = "InvalidSpec"
+	Pinned          string = "Pinned"
+	RollbackFailed  string = "RollbackFailed"
+	RollingOut      string = "RollingOut"
+	RolloutAborted  string = "RolloutAborted"
+	RouteConflict   string = "RouteConflict"
+	JobFailed       string = "JobFailed"
+	PlacementFailed string = "PlacementFailed"
+	// ClusterUnreachable flags objects running on a cluster that missed its heartbeat.
+	ClusterUnreachable string = "ClusterUnreachable"
+	// SecretsUnavailable flags Applications whose managed secrets cannot be synced.
+	SecretsUnavailable string = "SecretsUnavailable"
 )
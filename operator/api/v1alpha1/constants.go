@@ -2,3 +2,109 @@ package v1alpha1
 
 const ClusterFinalizer = "clusters.vulkan.io/finalizer"
 const ProjectFinalizer = "projects.vulkan.io/finalizer"
+
+// PlacementFinalizer is set on Applications with spec.placement. The workload objects
+// rendered in remote clusters cannot be owned by the Application, so they are deleted
+// before the finalizer is removed.
+const PlacementFinalizer = "applications.vulkan.io/placement"
+
+// ArgoCDFinalizer is set on Applications deployed by the argocd backend. Their Argo CD
+// Applications live in the Argo CD namespace and cannot be owned by them, so they are
+// deleted before the finalizer is removed.
+const ArgoCDFinalizer = "applications.vulkan.io/argocd"
+
+// ApplicationLabel is set on every object the operator creates for an Application
+// (PipelineRuns, Deployments, Services, ...) so they can be found again by selector.
+const ApplicationLabel = "vulkan.io/application"
+
+// ApplicationNamespaceLabel is set next to ApplicationLabel on the objects created for an
+// Application outside of its namespace, such as its Argo CD Applications.
+const ApplicationNamespaceLabel = "vulkan.io/application-namespace"
+
+// NodePoolLabel is set on the nodes of a provisioned cluster to the name of the node
+// pool they belong to.
+const NodePoolLabel = "vulkan.io/node-pool"
+
+// ManagedByLabel and ManagedByValue mark objects owned by the operator.
+const ManagedByLabel = "app.kubernetes.io/managed-by"
+const ManagedByValue = "vulkan-operator"
+
+// BuildFingerprintAnnotation is set on every build PipelineRun to a hash of the
+// build-relevant fields of the Application spec it was created from.
+const BuildFingerprintAnnotation = "vulkan.io/build-fingerprint"
+
+// BuildStrategyAnnotation records on a build PipelineRun the build strategy that
+// created it, so its results are parsed by the same strategy.
+const BuildStrategyAnnotation = "vulkan.io/build-strategy"
+
+// RebuildRequestedAtAnnotation can be set on an Application (to any new value,
+// conventionally an RFC3339 timestamp) to force a build of an unchanged spec.
+// The value is copied onto the PipelineRun it triggered.
+const RebuildRequestedAtAnnotation = "vulkan.io/rebuild-requested-at"
+
+// RetryRequestedAtAnnotation can be set on an Application (to any new value) to retry
+// its latest build when that build failed or was cancelled. It is ignored when the
+// latest build succeeded. The value is copied onto every PipelineRun created after it.
+const RetryRequestedAtAnnotation = "vulkan.io/retry-requested-at"
+
+// CancelRequestedAtAnnotation can be set on an Application (to any new value) to cancel
+// its running build: the operator sets the PipelineRun's spec.status to Cancelled. The
+// value is copied onto the cancelled PipelineRun and every one created after it.
+const CancelRequestedAtAnnotation = "vulkan.io/cancel-requested-at"
+
+// RequestedByAnnotation records on an Application who made the latest rebuild, retry,
+// cancel, rollback or promote request, so the build history can name them.
+const RequestedByAnnotation = "vulkan.io/requested-by"
+
+// BuildTriggerAnnotation and BuildTriggeredByAnnotation record on a build PipelineRun
+// why it was started (one of the BuildTrigger values) and, for rebuilds, retries and
+// pushes, who requested it. BuildCancelledByAnnotation records who cancelled it.
+const BuildTriggerAnnotation = "vulkan.io/build-trigger"
+const BuildTriggeredByAnnotation = "vulkan.io/build-triggered-by"
+const BuildCancelledByAnnotation = "vulkan.io/build-cancelled-by"
+
+// RollbackToAnnotation can be set on an Application to roll it back to an earlier
+// deployment, named by its image reference, its commit SHA (or a prefix of at least
+// seven characters) or the PipelineRun that built it. The Application stays pinned to
+// that deployment until the annotation is removed.
+const RollbackToAnnotation = "vulkan.io/rollback-to"
+
+// PromoteRequestedAtAnnotation can be set on an Application (to any new value) to
+// promote a progressive rollout waiting at a paused step: a canary moves on to its next
+// step, a blue-green rollout switches traffic to the new image.
+const PromoteRequestedAtAnnotation = "vulkan.io/promote-requested-at"
+
+// SecretsVersionAnnotation is set on an Application by the platform API whenever its
+// managed secrets change. The operator then syncs them into the <app>-env Secret, and
+// copies the annotation onto the pod template so the pods are restarted with the new
+// values. Applications without it have no managed secrets.
+const SecretsVersionAnnotation = "vulkan.io/secrets-version"
+
+// RolloutTrackLabel is set on the pods of an Application to stable, canary or preview,
+// telling the pods of a progressive rollout apart from the stable ones.
+const RolloutTrackLabel = "vulkan.io/rollout-track"
+
+// SourceRevisionAnnotation and SourceRefAnnotation are set on an Application by the
+// git webhook receiver to the commit SHA last pushed and the branch or tag it was
+// pushed to. When the ref is the Application's build ref, the operator builds
+// exactly that commit; the revision is copied onto the PipelineRun it triggered.
+const SourceRevisionAnnotation = "vulkan.io/source-revision"
+const SourceRefAnnotation = "vulkan.io/source-ref"
+
+// SourcePushedByAnnotation is set next to SourceRevisionAnnotation to who pushed that
+// commit. It is kept apart from RequestedByAnnotation, which the API overwrites with
+// every rebuild, retry, cancel or rollback request.
+const SourcePushedByAnnotation = "vulkan.io/source-pushed-by"
+
+// OrgLabel and ProjectLabel are set by the operator on Applications to their org and
+// project refs, and on project namespaces, so the API looks an Application up without
+// listing every Application of the cluster.
+const (
+	OrgLabel     = "vulkan.io/org"
+	ProjectLabel = "vulkan.io/project"
+)
+
+// RepoLabel is set by the operator on Applications with a git webhook to the
+// RepoLabelValue of their repository, so the webhook receiver only lists the
+// Applications of the pushed repository.
+const RepoLabel = "vulkan.io/repo"
@@ -26,6 +26,11 @@ type OrgSpec struct {
 
 	// Quota defines the resource limits for the organization will be enforced by org controller
 	OrgQuota OrgQuota `json:"quota,omitempty"`
+
+	// BuildSettings overrides the operator's build defaults (registry, GitOps repo,
+	// service account, secrets) for every application in the organization.
+	// +kubebuilder:validation:Optional
+	BuildSettings *BuildSettings `json:"buildSettings,omitempty"`
 }
 
 type OrgQuota struct {
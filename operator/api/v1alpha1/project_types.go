@@ -45,6 +45,11 @@ type ProjectSpec struct {
 	// if not provided, a namespace will be created with the name of the project
 	// +kubebuilder:validation:Optional
 	ProjectNamespace string `json:"projectNamespace,omitempty"`
+
+	// BuildSettings overrides the org's and operator's build defaults for every
+	// application in the project.
+	// +kubebuilder:validation:Optional
+	BuildSettings *BuildSettings `json:"buildSettings,omitempty"`
 }
 
 // ProjectStatus defines the observed state of Project.
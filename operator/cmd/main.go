@@ -18,9 +18,11 @@ package main
 
 import (
 	"crypto/tls"
+	"database/sql"
 	"flag"
 	"os"
 	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -39,8 +41,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/clusterprovider"
 	"github.com/mofe64/vulkan/operator/internal/controller"
+	"github.com/mofe64/vulkan/operator/internal/secretstore"
 	"github.com/mofe64/vulkan/operator/internal/utils"
+	"github.com/mofe64/vulkan/operator/pkg/secretbox"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -66,6 +71,14 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
+	var buildDefaults platformv1alpha1.BuildSettings
+	var succeededRunsHistoryLimit, failedRunsHistoryLimit int
+	var databaseURL, secretsKey string
+	var kindInternalKubeconfig bool
+	var readyNodeThreshold int
+	var healthCheckInterval, heartbeatTimeout time.Duration
+	var healthCheckJitter float64
+	var previewDomain, ingressClassName, appsDomain, clusterIssuer, argoCDNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -83,6 +96,46 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&buildDefaults.ImageRegistry, "image-registry", controller.DefaultBuildSettings.ImageRegistry,
+		"The registry (and path prefix) application images are pushed to. Orgs and Projects may override it.")
+	flag.StringVar(&buildDefaults.GitOpsRepoURL, "gitops-repo-url", controller.DefaultBuildSettings.GitOpsRepoURL,
+		"The GitOps repository build pipelines commit new images to.")
+	flag.StringVar(&buildDefaults.ServiceAccountName, "build-service-account", controller.DefaultBuildSettings.ServiceAccountName,
+		"The service account build PipelineRuns run as.")
+	flag.StringVar(&buildDefaults.RegistrySecretName, "registry-secret", controller.DefaultBuildSettings.RegistrySecretName,
+		"The docker config Secret build PipelineRuns use to push images.")
+	flag.StringVar(&buildDefaults.GitCredentialsSecretName, "git-credentials-secret",
+		controller.DefaultBuildSettings.GitCredentialsSecretName,
+		"The Secret build PipelineRuns use to write to the GitOps repository.")
+	flag.IntVar(&succeededRunsHistoryLimit, "succeeded-builds-history-limit",
+		int(*controller.DefaultBuildRetention.SucceededRunsHistoryLimit),
+		"The number of succeeded build PipelineRuns kept per Application. Applications may override it.")
+	flag.IntVar(&failedRunsHistoryLimit, "failed-builds-history-limit",
+		int(*controller.DefaultBuildRetention.FailedRunsHistoryLimit),
+		"The number of failed or cancelled build PipelineRuns kept per Application. Applications may override it.")
+	flag.StringVar(&previewDomain, "preview-domain", "",
+		"The wildcard domain preview environments get their hostname under. Applications may override it.")
+	flag.StringVar(&ingressClassName, "ingress-class", "nginx", "The class of the Ingresses exposing Applications.")
+	flag.StringVar(&appsDomain, "apps-domain", "",
+		"The wildcard domain Applications get their default hostname under, as <app>-<namespace>.<domain>.")
+	flag.StringVar(&clusterIssuer, "cluster-issuer", "",
+		"The cert-manager ClusterIssuer certificates of Applications are requested from. TLS is disabled when empty.")
+	flag.StringVar(&argoCDNamespace, "argocd-namespace", "argocd",
+		"The namespace Argo CD runs in, where the Argo CD Applications of the argocd deploy backend are created.")
+	flag.BoolVar(&kindInternalKubeconfig, "kind-internal-kubeconfig", false,
+		"Address the kind clusters of the local cluster provider on their Docker network, for an operator running in a container on it.")
+	flag.IntVar(&readyNodeThreshold, "cluster-ready-node-threshold", controller.DefaultReadyNodeThreshold,
+		"The percentage of ready nodes under which a cluster is reported Degraded.")
+	flag.DurationVar(&healthCheckInterval, "cluster-health-check-interval", controller.DefaultHealthCheckInterval,
+		"How often clusters are health-checked.")
+	flag.Float64Var(&healthCheckJitter, "cluster-health-check-jitter", controller.DefaultHealthCheckJitter,
+		"The fraction of the health check interval checks are randomly delayed by, to spread them out.")
+	flag.DurationVar(&heartbeatTimeout, "cluster-heartbeat-timeout", controller.DefaultHeartbeatTimeout,
+		"How long a cluster may go without a successful health check before its Ready condition turns Unknown.")
+	flag.StringVar(&databaseURL, "database-url", os.Getenv("VULKAN_DATABASE_URL"),
+		"The platform database the managed secrets of Applications are read from. Defaults to $VULKAN_DATABASE_URL.")
+	flag.StringVar(&secretsKey, "secrets-key", os.Getenv("VULKAN_SECRETS_KEY"),
+		"The base64 encoded key managed secrets are encrypted with, shared with the API. Defaults to $VULKAN_SECRETS_KEY.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -206,7 +259,28 @@ func main() {
 
 	// build TargetClientFactory for cluster crds
 	targetClientFactory := utils.NewTargetClientFactory(mgr.GetClient())
-	// todo: external db connection pool
+	// the platform database backs the managed secrets of Applications and the project
+	// members bound into target clusters
+	var db *sql.DB
+	var secrets secretstore.Store
+	if databaseURL != "" {
+		db, err = utils.ConnectDB(databaseURL)
+		if err != nil {
+			setupLog.Error(err, "unable to connect to the platform database")
+			os.Exit(1)
+		}
+		defer db.Close()
+		if secretsKey != "" {
+			key, err := secretbox.ParseKey(secretsKey)
+			if err != nil {
+				setupLog.Error(err, "invalid secrets key")
+				os.Exit(1)
+			}
+			secrets = secretstore.NewSQL(db, key)
+		} else {
+			setupLog.Info("no secrets key set, managed secrets of Applications are not synced")
+		}
+	}
 
 	if err := (&controller.OrgReconciler{
 		Client: mgr.GetClient(),
@@ -222,16 +296,36 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Project")
 		os.Exit(1)
 	}
+	succeededLimit, failedLimit := int32(succeededRunsHistoryLimit), int32(failedRunsHistoryLimit)
 	if err := (&controller.ApplicationReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		BuildDefaults: buildDefaults,
+		BuildRetention: platformv1alpha1.BuildRetention{
+			SucceededRunsHistoryLimit: &succeededLimit,
+			FailedRunsHistoryLimit:    &failedLimit,
+		},
+		PreviewDomain:    previewDomain,
+		IngressClassName: ingressClassName,
+		AppsDomain:       appsDomain,
+		ClusterIssuer:    clusterIssuer,
+		ArgoCDNamespace:  argoCDNamespace,
+		Secrets:          secrets,
+		TargetFactory:    targetClientFactory,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Application")
 		os.Exit(1)
 	}
 	if err := (&controller.ClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		TargetFactory:       targetClientFactory,
+		APIReader:           mgr.GetAPIReader(),
+		Providers:           clusterprovider.NewRegistry(&clusterprovider.Local{Internal: kindInternalKubeconfig}),
+		ReadyNodeThreshold:  readyNodeThreshold,
+		HealthCheckInterval: healthCheckInterval,
+		HealthCheckJitter:   healthCheckJitter,
+		HeartbeatTimeout:    heartbeatTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
@@ -240,6 +334,7 @@ func main() {
 		Client:        mgr.GetClient(),
 		Scheme:        mgr.GetScheme(),
 		TargetFactory: targetClientFactory,
+		DB:            db,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ProjectClusterBinding")
 		os.Exit(1)
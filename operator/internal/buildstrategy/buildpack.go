@@ -0,0 +1,40 @@
+package buildstrategy
+
+import (
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// DefaultBuilderImage is the Cloud Native Buildpacks builder used when
+// Spec.Build.BuilderImage is empty.
+const DefaultBuilderImage = "paketobuildpacks/builder:base"
+
+// Buildpack builds the image without a Dockerfile using Cloud Native Buildpacks
+// (app-build-buildpack).
+type Buildpack struct{ standardResults }
+
+func (Buildpack) Name() string { return "buildpack" }
+
+func (Buildpack) Validate(app *platformv1alpha1.Application) error { return RequireSource(app) }
+
+func (Buildpack) PipelineRef(*platformv1alpha1.Application) string { return "app-build-buildpack" }
+
+func (Buildpack) Params(app *platformv1alpha1.Application) []tektonv1.Param {
+	builderImage := DefaultBuilderImage
+	if app.Spec.Build.BuilderImage != "" {
+		builderImage = app.Spec.Build.BuilderImage
+	}
+	literals, secretNames, _ := SplitBuildEnv(app.Spec.Build.Env)
+
+	return []tektonv1.Param{
+		StringParam("builder-image", builderImage),
+		StringParam("context-dir", ContextDir(app)),
+		StringParam("env-vars", JoinKeyValues(literals)),
+		StringParam("secret-env-names", secretNames),
+	}
+}
+
+func (Buildpack) Workspaces() []string { return allWorkspaces }
+
+func (Buildpack) BuildTask() string { return "buildpack-build-and-push" }
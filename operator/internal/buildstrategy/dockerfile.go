@@ -0,0 +1,45 @@
+package buildstrategy
+
+import (
+	"maps"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// Dockerfile builds the image from a Dockerfile with Kaniko (app-build-dockerfile).
+type Dockerfile struct{ standardResults }
+
+func (Dockerfile) Name() string { return "dockerfile" }
+
+func (Dockerfile) Validate(app *platformv1alpha1.Application) error { return RequireSource(app) }
+
+func (Dockerfile) PipelineRef(*platformv1alpha1.Application) string { return "app-build-dockerfile" }
+
+func (Dockerfile) Params(app *platformv1alpha1.Application) []tektonv1.Param {
+	// use either specified path or default to "./Dockerfile"
+	dockerfilePath := "./Dockerfile"
+	if app.Spec.Build.Dockerfile != "" {
+		dockerfilePath = app.Spec.Build.Dockerfile
+	}
+
+	// literal build env is passed as build args alongside the explicit ones,
+	// the explicit ones win on conflicts
+	literals, secretNames, _ := SplitBuildEnv(app.Spec.Build.Env)
+	buildArgs := map[string]string{}
+	maps.Copy(buildArgs, literals)
+	maps.Copy(buildArgs, app.Spec.Build.BuildArgs)
+
+	return []tektonv1.Param{
+		StringParam("dockerfile-path", dockerfilePath),
+		StringParam("context-dir", ContextDir(app)),
+		StringParam("build-args", JoinBuildArgs(buildArgs)),
+		StringParam("secret-env-names", secretNames),
+		StringParam("target", app.Spec.Build.Target),
+	}
+}
+
+func (Dockerfile) Workspaces() []string { return allWorkspaces }
+
+func (Dockerfile) BuildTask() string { return "kaniko-build-and-push" }
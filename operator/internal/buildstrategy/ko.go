@@ -0,0 +1,39 @@
+package buildstrategy
+
+import (
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// DefaultKoBaseImage is the base image ko builds on when Spec.Build.BuilderImage is empty.
+const DefaultKoBaseImage = "cgr.dev/chainguard/static:latest"
+
+// Ko builds Go services with ko (app-build-ko). Spec.Build.ContextDir is the
+// directory of the main package and Spec.Build.BuilderImage the base image.
+type Ko struct{ standardResults }
+
+func (Ko) Name() string { return "ko" }
+
+func (Ko) Validate(app *platformv1alpha1.Application) error { return RequireSource(app) }
+
+func (Ko) PipelineRef(*platformv1alpha1.Application) string { return "app-build-ko" }
+
+func (Ko) Params(app *platformv1alpha1.Application) []tektonv1.Param {
+	baseImage := DefaultKoBaseImage
+	if app.Spec.Build.BuilderImage != "" {
+		baseImage = app.Spec.Build.BuilderImage
+	}
+	literals, secretNames, _ := SplitBuildEnv(app.Spec.Build.Env)
+
+	return []tektonv1.Param{
+		StringParam("base-image", baseImage),
+		StringParam("context-dir", ContextDir(app)),
+		StringParam("env-vars", JoinKeyValues(literals)),
+		StringParam("secret-env-names", secretNames),
+	}
+}
+
+func (Ko) Workspaces() []string { return allWorkspaces }
+
+func (Ko) BuildTask() string { return "ko-build-and-push" }
@@ -0,0 +1,44 @@
+package buildstrategy
+
+import (
+	"errors"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// CustomPipeline runs the user-supplied Tekton Pipeline named in Spec.Build.Pipeline.
+// The pipeline gets the common params plus the build config params below, is bound
+// to the same workspaces as the built-in pipelines and must publish image-digest
+// (and optionally commit) like them.
+type CustomPipeline struct{ standardResults }
+
+func (CustomPipeline) Name() string { return "pipeline" }
+
+func (CustomPipeline) Validate(app *platformv1alpha1.Application) error {
+	if app.Spec.Build.Pipeline == "" {
+		return errors.New("spec.build.pipeline is required")
+	}
+	return RequireSource(app)
+}
+
+func (CustomPipeline) PipelineRef(app *platformv1alpha1.Application) string {
+	return app.Spec.Build.Pipeline
+}
+
+func (CustomPipeline) Params(app *platformv1alpha1.Application) []tektonv1.Param {
+	literals, secretNames, _ := SplitBuildEnv(app.Spec.Build.Env)
+	return []tektonv1.Param{
+		StringParam("context-dir", ContextDir(app)),
+		StringParam("build-args", JoinBuildArgs(app.Spec.Build.BuildArgs)),
+		StringParam("env-vars", JoinKeyValues(literals)),
+		StringParam("secret-env-names", secretNames),
+	}
+}
+
+func (CustomPipeline) Workspaces() []string { return allWorkspaces }
+
+// BuildTask is unknown for a user pipeline, Secret-backed build env is then
+// injected into every task of the run.
+func (CustomPipeline) BuildTask() string { return "" }
@@ -0,0 +1,36 @@
+package buildstrategy
+
+import (
+	"errors"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// PrebuiltImage deploys Spec.Image as is, no PipelineRun is created.
+type PrebuiltImage struct{}
+
+func (PrebuiltImage) Name() string { return "prebuilt" }
+
+func (PrebuiltImage) Validate(app *platformv1alpha1.Application) error {
+	if app.Spec.Image == nil || app.Spec.Image.Reference == "" {
+		return errors.New("spec.image.reference is required")
+	}
+	return nil
+}
+
+func (PrebuiltImage) PipelineRef(*platformv1alpha1.Application) string { return "" }
+
+func (PrebuiltImage) Params(*platformv1alpha1.Application) []tektonv1.Param { return nil }
+
+func (PrebuiltImage) Workspaces() []string { return nil }
+
+func (PrebuiltImage) BuildTask() string { return "" }
+
+func (PrebuiltImage) ParseResults(*tektonv1.PipelineRun) (string, string) { return "", "" }
+
+// Image implements Prebuilt.
+func (PrebuiltImage) Image(app *platformv1alpha1.Application) string {
+	return app.Spec.Image.Reference
+}
@@ -0,0 +1,238 @@
+// Package buildstrategy holds the build strategies an Application can select through
+// Spec.Build.Strategy. A strategy decides which Tekton Pipeline builds the image, the
+// strategy-specific params and workspaces it needs, and how the built image is read
+// back from the finished PipelineRun.
+package buildstrategy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// Workspaces declared by the app-build-* pipelines. A strategy lists the ones its
+// pipeline needs and the reconciler binds them.
+const (
+	SourceWorkspace         = "source-workspace"
+	DockerConfigWorkspace   = "docker-config"
+	GitCredentialsWorkspace = "git-credentials"
+)
+
+// Results published by the app-build-* pipelines.
+const (
+	ImageDigestResult = "image-digest"
+	CommitResult      = "commit"
+)
+
+// BuildStrategy renders the strategy-specific part of a build PipelineRun.
+// The reconciler adds the params common to every pipeline (repo-url, branch,
+// image-name, image-tag, gitops-repo-url, gitops-app-path, app-name).
+type BuildStrategy interface {
+	// Name is the value of Spec.Build.Strategy that selects the strategy.
+	Name() string
+
+	// Validate reports spec errors that make the Application unbuildable. They are
+	// terminal: the reconciler sets InvalidSpec instead of retrying.
+	Validate(app *platformv1alpha1.Application) error
+
+	// PipelineRef is the Tekton Pipeline to run. Strategies that do not build
+	// (see Prebuilt) return an empty string.
+	PipelineRef(app *platformv1alpha1.Application) string
+
+	// Params renders the strategy-specific pipeline params.
+	Params(app *platformv1alpha1.Application) []tektonv1.Param
+
+	// Workspaces lists the pipeline workspaces that must be bound.
+	Workspaces() []string
+
+	// BuildTask is the pipeline task that builds the image. Secret-backed build env
+	// is injected into its pod only.
+	BuildTask() string
+
+	// ParseResults returns the pushed image reference and the built commit from a
+	// finished PipelineRun; either is empty when unknown.
+	ParseResults(run *tektonv1.PipelineRun) (image, revision string)
+}
+
+// Prebuilt is implemented by strategies that deploy an existing image instead of
+// running a build.
+type Prebuilt interface {
+	Image(app *platformv1alpha1.Application) string
+}
+
+// Registry maps strategy names to their implementation.
+type Registry struct {
+	mu         sync.RWMutex
+	strategies map[string]BuildStrategy
+}
+
+// NewRegistry returns a Registry holding the given strategies.
+func NewRegistry(strategies ...BuildStrategy) *Registry {
+	r := &Registry{strategies: map[string]BuildStrategy{}}
+	for _, s := range strategies {
+		r.Register(s)
+	}
+	return r
+}
+
+// Default returns a Registry with the built-in strategies.
+func Default() *Registry {
+	return NewRegistry(Dockerfile{}, Buildpack{}, Ko{}, PrebuiltImage{}, CustomPipeline{})
+}
+
+// Register adds a strategy, replacing any strategy registered under the same name.
+func (r *Registry) Register(s BuildStrategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategies[s.Name()] = s
+}
+
+// Get returns the strategy registered under name.
+func (r *Registry) Get(name string) (BuildStrategy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.strategies[name]
+	return s, ok
+}
+
+// Names returns the registered strategy names, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.strategies))
+	for name := range r.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the strategy selected by the Application and validates the spec
+// against it. Both an unknown strategy and a validation failure are terminal errors.
+// An Application without a strategy but with spec.image uses the prebuilt strategy.
+func (r *Registry) Resolve(app *platformv1alpha1.Application) (BuildStrategy, error) {
+	name := app.Spec.Build.Strategy
+	if name == "" {
+		if app.Spec.Image == nil {
+			return nil, errors.New("spec.build.strategy is required unless spec.image is set")
+		}
+		name = PrebuiltImage{}.Name()
+	}
+	s, ok := r.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown build strategy %q, must be one of %s",
+			name, strings.Join(r.Names(), ", "))
+	}
+	if err := s.Validate(app); err != nil {
+		return nil, fmt.Errorf("invalid %s build: %w", s.Name(), err)
+	}
+	return s, nil
+}
+
+// RequireSource validates that an Application building from source has a repository.
+func RequireSource(app *platformv1alpha1.Application) error {
+	if app.Spec.RepoURL == "" {
+		return errors.New("spec.repoURL is required")
+	}
+	return nil
+}
+
+// StringParam is shorthand for a string-typed Tekton param.
+func StringParam(name, value string) tektonv1.Param {
+	return tektonv1.Param{Name: name, Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: value}}
+}
+
+// ContextDir returns the build context of the Application, defaulting to ".".
+func ContextDir(app *platformv1alpha1.Application) string {
+	if app.Spec.Build.ContextDir != "" {
+		return app.Spec.Build.ContextDir
+	}
+	return "."
+}
+
+// JoinKeyValues renders a map as the comma-separated KEY=VALUE list the app-build-*
+// pipelines expect, sorted so the params are stable across reconciles.
+func JoinKeyValues(kv map[string]string) string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// JoinBuildArgs renders a map as the KEY=VALUE lines of the build-args param, one
+// build argument per line, sorted so the params are stable across reconciles.
+func JoinBuildArgs(kv map[string]string) string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\n")
+}
+
+// SplitBuildEnv separates the build-time env of an Application into the literal
+// KEY=VALUE pairs that can travel as a pipeline param, the comma-separated names of
+// the Secret-backed variables, and the EnvVars that inject those Secret values into
+// the build pod.
+func SplitBuildEnv(env []platformv1alpha1.BuildEnvVar) (literals map[string]string, secretNames string, secretEnv []corev1.EnvVar) {
+	literals = map[string]string{}
+	var names []string
+	for _, e := range env {
+		if e.SecretKeyRef == nil {
+			literals[e.Name] = e.Value
+			continue
+		}
+		names = append(names, e.Name)
+		secretEnv = append(secretEnv, corev1.EnvVar{
+			Name:      e.Name,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: e.SecretKeyRef.DeepCopy()},
+		})
+	}
+	return literals, strings.Join(names, ","), secretEnv
+}
+
+// standardResults implements ParseResults for pipelines that publish the
+// image-digest and commit results and take the image-name / image-tag params.
+type standardResults struct{}
+
+func (standardResults) ParseResults(run *tektonv1.PipelineRun) (string, string) {
+	return ParseStandardResults(run)
+}
+
+// ParseStandardResults resolves the pushed image by digest from the image-digest
+// result, falling back to image-name:image-tag when the build could not report one.
+func ParseStandardResults(run *tektonv1.PipelineRun) (image, revision string) {
+	params := map[string]string{}
+	for _, p := range run.Spec.Params {
+		params[p.Name] = p.Value.StringVal
+	}
+	results := map[string]string{}
+	for _, res := range run.Status.Results {
+		results[res.Name] = strings.TrimSpace(res.Value.StringVal)
+	}
+
+	revision = results[CommitResult]
+	imageName := params["image-name"]
+	if imageName == "" {
+		return "", revision
+	}
+	if digest := results[ImageDigestResult]; strings.HasPrefix(digest, "sha256:") {
+		return imageName + "@" + digest, revision
+	}
+	if tag := params["image-tag"]; tag != "" {
+		return imageName + ":" + tag, revision
+	}
+	return "", revision
+}
+
+// allWorkspaces is the workspace set of the built-in pipelines.
+var allWorkspaces = []string{SourceWorkspace, DockerConfigWorkspace, GitCredentialsWorkspace}
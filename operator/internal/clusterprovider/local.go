@@ -0,0 +1,185 @@
+package clusterprovider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// LocalType is the cluster type provisioned by Local.
+const LocalType = "local"
+
+// Local provisions local clusters with kind (https://kind.sigs.k8s.io), one container
+// per node, for development and CI without any cloud. Node pools become kind workers
+// labelled with their pool; a cluster without node pools is a single node. kind
+// clusters cannot be resized once created.
+//
+// The kind binary and a Docker daemon must be available to the operator.
+type Local struct {
+	// Binary is the kind executable, kind on the PATH when empty.
+	Binary string
+
+	// Internal makes the kubeconfigs address the control plane on the Docker network
+	// of the nodes instead of a port on localhost, for an operator running in a
+	// container attached to that network.
+	Internal bool
+
+	mu sync.Mutex
+	// creating holds the kind create commands in flight by cluster name.
+	creating map[string]*creation
+}
+
+// creation is a kind create command running in the background.
+type creation struct {
+	done chan struct{}
+	err  error
+}
+
+func (l *Local) Name() string { return LocalType }
+
+// clusterName is the kind cluster of a Cluster CR.
+func clusterName(clu *platformv1alpha1.Cluster) string {
+	return "vulkan-" + clu.Name
+}
+
+// Create runs kind create cluster in the background and reports Ready once it has
+// finished. A failed creation is returned once and retried on the next call.
+func (l *Local) Create(ctx context.Context, clu *platformv1alpha1.Cluster) (Status, error) {
+	name := clusterName(clu)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if op, ok := l.creating[name]; ok {
+		select {
+		case <-op.done:
+			delete(l.creating, name)
+			if op.err != nil {
+				return Status{}, op.err
+			}
+		default:
+			return Status{Message: "Creating kind cluster " + name}, nil
+		}
+	}
+
+	exists, err := l.exists(ctx, name)
+	if err != nil {
+		return Status{}, err
+	}
+	if exists {
+		return Status{Ready: true, Message: "kind cluster " + name + " is running"}, nil
+	}
+
+	config, err := kindConfig(clu)
+	if err != nil {
+		return Status{}, err
+	}
+	op := &creation{done: make(chan struct{})}
+	if l.creating == nil {
+		l.creating = map[string]*creation{}
+	}
+	l.creating[name] = op
+	go func() {
+		defer close(op.done)
+		// the creation outlives the reconcile that started it
+		_, op.err = l.run(context.Background(), config, "create", "cluster", "--name", name, "--config", "-")
+	}()
+	return Status{Message: "Creating kind cluster " + name}, nil
+}
+
+func (l *Local) ScaleNodePool(context.Context, *platformv1alpha1.Cluster, string, int32) error {
+	return ErrScalingNotSupported
+}
+
+func (l *Local) Kubeconfig(ctx context.Context, clu *platformv1alpha1.Cluster) ([]byte, error) {
+	args := []string{"get", "kubeconfig", "--name", clusterName(clu)}
+	if l.Internal {
+		args = append(args, "--internal")
+	}
+	return l.run(ctx, nil, args...)
+}
+
+func (l *Local) Delete(ctx context.Context, clu *platformv1alpha1.Cluster) (bool, error) {
+	name := clusterName(clu)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if op, ok := l.creating[name]; ok {
+		// let the creation finish, kind cannot delete a cluster while creating it
+		select {
+		case <-op.done:
+			delete(l.creating, name)
+		default:
+			return false, nil
+		}
+	}
+	if _, err := l.run(ctx, nil, "delete", "cluster", "--name", name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// exists reports whether kind knows the cluster.
+func (l *Local) exists(ctx context.Context, name string) (bool, error) {
+	out, err := l.run(ctx, nil, "get", "clusters")
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(strings.Fields(string(out)), name), nil
+}
+
+// run runs kind with the arguments, feeding it stdin, and returns its standard output.
+func (l *Local) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	binary := l.Binary
+	if binary == "" {
+		binary = "kind"
+	}
+	cmd := exec.CommandContext(ctx, binary, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("kind %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// kindNode and kindCluster are the parts of the kind cluster config rendered by kindConfig.
+type kindNode struct {
+	Role   string            `json:"role"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type kindCluster struct {
+	Kind       string     `json:"kind"`
+	APIVersion string     `json:"apiVersion"`
+	Nodes      []kindNode `json:"nodes"`
+}
+
+// kindConfig renders the kind cluster config of a Cluster CR: a control plane and a
+// worker labelled with NodePoolLabel and the pool's labels for every node of each pool.
+func kindConfig(clu *platformv1alpha1.Cluster) ([]byte, error) {
+	config := kindCluster{
+		Kind:       "Cluster",
+		APIVersion: "kind.x-k8s.io/v1alpha4",
+		Nodes:      []kindNode{{Role: "control-plane"}},
+	}
+	for _, pool := range clu.Spec.NodePools {
+		labels := map[string]string{platformv1alpha1.NodePoolLabel: pool.Name}
+		for k, v := range pool.Labels {
+			labels[k] = v
+		}
+		for range PoolSize(pool) {
+			config.Nodes = append(config.Nodes, kindNode{Role: "worker", Labels: labels})
+		}
+	}
+	return yaml.Marshal(config)
+}
@@ -0,0 +1,104 @@
+// Package clusterprovider holds the providers that provision the clusters a Cluster CR
+// declares. The provider is selected by Spec.Type; types without one (attached and
+// remote) are provisioned outside of the platform and only health-checked.
+package clusterprovider
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// ErrScalingNotSupported is returned by providers that cannot resize the node pools of
+// a cluster they provisioned.
+var ErrScalingNotSupported = errors.New("the provider cannot scale node pools")
+
+// Status is the progress of a cluster being provisioned.
+type Status struct {
+	// Ready is set once the cluster is up and its kubeconfig can be read.
+	Ready bool
+	// Message describes the current provisioning step.
+	Message string
+}
+
+// ClusterProvider provisions clusters of one type. Every method is called on each
+// reconcile until it reports it is done, so they must be idempotent and should not
+// block for the duration of a long-running operation.
+type ClusterProvider interface {
+	// Name is the value of Spec.Type that selects the provider.
+	Name() string
+
+	// Create starts provisioning the cluster with its node pools, or reports on the
+	// provisioning in progress.
+	Create(ctx context.Context, clu *platformv1alpha1.Cluster) (Status, error)
+
+	// ScaleNodePool sets the number of nodes of a pool, or returns ErrScalingNotSupported.
+	ScaleNodePool(ctx context.Context, clu *platformv1alpha1.Cluster, pool string, size int32) error
+
+	// Kubeconfig returns a kubeconfig with admin access to the provisioned cluster.
+	Kubeconfig(ctx context.Context, clu *platformv1alpha1.Cluster) ([]byte, error)
+
+	// Delete tears the cluster down, reporting whether it is gone. Deleting a cluster
+	// that does not exist succeeds.
+	Delete(ctx context.Context, clu *platformv1alpha1.Cluster) (bool, error)
+}
+
+// Registry maps cluster types to their provider.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]ClusterProvider
+}
+
+// NewRegistry returns a Registry holding the given providers.
+func NewRegistry(providers ...ClusterProvider) *Registry {
+	r := &Registry{providers: map[string]ClusterProvider{}}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Default returns a Registry with the built-in providers.
+func Default() *Registry {
+	return NewRegistry(&Local{})
+}
+
+// Register adds a provider, replacing any provider registered for the same type.
+func (r *Registry) Register(p ClusterProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[p.Name()] = p
+}
+
+// Get returns the provider of a cluster type, false for types provisioned outside of
+// the platform.
+func (r *Registry) Get(clusterType string) (ClusterProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[clusterType]
+	return p, ok
+}
+
+// Names returns the cluster types with a provider, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PoolSize is the number of nodes a pool is provisioned with: Desired when set,
+// MinSize otherwise.
+func PoolSize(pool platformv1alpha1.NodePool) int32 {
+	if pool.Desired != nil {
+		return *pool.Desired
+	}
+	return pool.MinSize
+}
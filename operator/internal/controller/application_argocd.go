@@ -0,0 +1,337 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/utils"
+)
+
+// argoApplicationGVK is the Argo CD Application the argocd backend deploys through. It is
+// handled as unstructured so Argo CD is only required by Applications using it.
+var argoApplicationGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application"}
+
+// argoCDRequeue is how often an Application deployed by Argo CD is looked at again. The
+// Argo CD Applications are not watched, so their sync and health are only mirrored on
+// these requeues.
+const argoCDRequeue = 30 * time.Second
+
+const (
+	// defaultArgoCDNamespace is where Argo CD Applications are created unless
+	// ApplicationReconciler.ArgoCDNamespace is set.
+	defaultArgoCDNamespace = "argocd"
+
+	// argoCDResourcesFinalizer makes Argo CD delete the objects it synced along with the
+	// Argo CD Application.
+	argoCDResourcesFinalizer = "resources-finalizer.argocd.argoproj.io"
+
+	// inClusterServer is the Argo CD destination of the cluster Argo CD runs in.
+	inClusterServer = "https://kubernetes.default.svc"
+)
+
+// usesArgoCD reports whether the Application is deployed by the argocd backend.
+func usesArgoCD(app *platformv1alpha1.Application) bool {
+	return app.Spec.Deploy.Backend == platformv1alpha1.DeployBackendArgoCD
+}
+
+// argoCDNamespace is the namespace Argo CD Applications are created in.
+func (r *ApplicationReconciler) argoCDNamespace() string {
+	if r.ArgoCDNamespace != "" {
+		return r.ArgoCDNamespace
+	}
+	return defaultArgoCDNamespace
+}
+
+// argoDestination is a cluster an Argo CD Application syncs the Application to.
+type argoDestination struct {
+	// app is the name of the Argo CD Application.
+	app string
+	// cluster is the Cluster CR, empty when the Application has no spec.placement.
+	cluster string
+	// server or name identify the cluster to Argo CD.
+	server, name string
+}
+
+// argoDestinations are the clusters the Application is synced to: the one Argo CD runs
+// in, or each cluster selected by spec.placement. Remote clusters must be registered in
+// Argo CD under the name of their Cluster CR.
+func (r *ApplicationReconciler) argoDestinations(ctx context.Context, app *platformv1alpha1.Application) ([]argoDestination, error) {
+	base := app.Namespace + "-" + app.Name
+	if app.Spec.Placement == nil {
+		return []argoDestination{{app: hostLabel(base), server: inClusterServer}}, nil
+	}
+	clusters, err := r.placementClusters(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	destinations := make([]argoDestination, 0, len(clusters))
+	for _, clu := range clusters {
+		dest := argoDestination{app: hostLabel(base + "-" + clu.Name), cluster: clu.Name}
+		if isAttached(clu) {
+			dest.server = inClusterServer
+		} else {
+			dest.name = clu.Name
+		}
+		destinations = append(destinations, dest)
+	}
+	return destinations, nil
+}
+
+// argoApplicationLabels find the Argo CD Applications of an Application, which live in
+// another namespace.
+func argoApplicationLabels(app *platformv1alpha1.Application) map[string]string {
+	return map[string]string{
+		platformv1alpha1.ApplicationLabel:          app.Name,
+		platformv1alpha1.ApplicationNamespaceLabel: app.Namespace,
+	}
+}
+
+// reconcileArgoCD deploys an Application with the argocd backend: one Argo CD
+// Application per destination cluster syncs the manifests its builds commit to the GitOps
+// repository. Argo CD Applications of clusters no longer selected are deleted, and the
+// sync and health reported by the others are mirrored into the status.
+func (r *ApplicationReconciler) reconcileArgoCD(ctx context.Context, app *platformv1alpha1.Application) error {
+	logger := logf.FromContext(ctx)
+
+	if len(app.Status.ArgoCD) == 0 {
+		// the workload rendered by the direct backend before the switch to Argo CD
+		if err := r.removeWorkloadObjects(ctx, app); err != nil {
+			return err
+		}
+	}
+
+	settings, err := r.resolveBuildSettings(ctx, app)
+	if err != nil {
+		return err
+	}
+	destinations, err := r.argoDestinations(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]platformv1alpha1.ArgoCDStatus, 0, len(destinations))
+	for _, dest := range destinations {
+		status, err := r.reconcileArgoApplication(ctx, app, dest, settings.GitOpsRepoURL)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+
+	existing, err := r.listArgoApplications(ctx, app)
+	if err != nil {
+		return err
+	}
+	for i := range existing {
+		argo := &existing[i]
+		if slices.ContainsFunc(destinations, func(d argoDestination) bool { return d.app == argo.GetName() }) {
+			continue
+		}
+		if err := r.Delete(ctx, argo); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Deleted Argo CD Application of a cluster no longer selected", "ArgoApplication.Name", argo.GetName())
+	}
+	app.Status.ArgoCD = statuses
+
+	if app.Spec.Placement != nil && len(destinations) == 0 {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.PlacementFailed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "NoMatchingClusters",
+			Message:            "No cluster bound to project " + app.Spec.ProjectRef + " matches spec.placement",
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.PlacementFailed)
+	}
+	observeArgoCD(app)
+	return nil
+}
+
+// reconcileArgoApplication creates or updates the Argo CD Application syncing the GitOps
+// path of the Application to a destination, and returns the state Argo CD reports for it.
+func (r *ApplicationReconciler) reconcileArgoApplication(ctx context.Context, app *platformv1alpha1.Application, dest argoDestination, repoURL string) (platformv1alpha1.ArgoCDStatus, error) {
+	argo := &unstructured.Unstructured{}
+	argo.SetGroupVersionKind(argoApplicationGVK)
+	argo.SetName(dest.app)
+	argo.SetNamespace(r.argoCDNamespace())
+
+	project := app.Spec.Deploy.ArgoCD.Project
+	if project == "" {
+		project = "default"
+	}
+	destination := map[string]any{"namespace": app.Namespace}
+	if dest.server != "" {
+		destination["server"] = dest.server
+	} else {
+		destination["name"] = dest.name
+	}
+	spec := map[string]any{
+		"project": project,
+		"source": map[string]any{
+			"repoURL":        repoURL,
+			"targetRevision": "HEAD",
+			"path":           gitOpsAppPath(app),
+		},
+		"destination": destination,
+	}
+	if !app.Spec.Deploy.ArgoCD.ManualSync {
+		spec["syncPolicy"] = map[string]any{
+			"automated": map[string]any{"prune": true, "selfHeal": true},
+		}
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, argo, func() error {
+		labels := argoApplicationLabels(app)
+		labels[platformv1alpha1.ManagedByLabel] = platformv1alpha1.ManagedByValue
+		argo.SetLabels(labels)
+		if !utils.ContainsString(argo.GetFinalizers(), argoCDResourcesFinalizer) {
+			argo.SetFinalizers(append(argo.GetFinalizers(), argoCDResourcesFinalizer))
+		}
+		return unstructured.SetNestedMap(argo.Object, spec, "spec")
+	})
+	if err != nil {
+		return platformv1alpha1.ArgoCDStatus{}, err
+	}
+	if op != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("Argo CD Application reconciled", "ArgoApplication.Name", argo.GetName(), "operation", op, "cluster", dest.cluster)
+	}
+	return argoStatus(argo, dest.cluster), nil
+}
+
+// argoStatus reads the sync and health Argo CD reports on one of its Applications.
+func argoStatus(argo *unstructured.Unstructured, cluster string) platformv1alpha1.ArgoCDStatus {
+	status := platformv1alpha1.ArgoCDStatus{Name: argo.GetName(), Cluster: cluster}
+	status.Sync, _, _ = unstructured.NestedString(argo.Object, "status", "sync", "status")
+	status.Revision, _, _ = unstructured.NestedString(argo.Object, "status", "sync", "revision")
+	status.Health, _, _ = unstructured.NestedString(argo.Object, "status", "health", "status")
+	status.Message, _, _ = unstructured.NestedString(argo.Object, "status", "health", "message")
+	phase, _, _ := unstructured.NestedString(argo.Object, "status", "operationState", "phase")
+	if phase == "Failed" || phase == "Error" {
+		status.Message, _, _ = unstructured.NestedString(argo.Object, "status", "operationState", "message")
+	}
+	return status
+}
+
+// observeArgoCD sets the Ready condition and Health of the Application from the state of
+// its Argo CD Applications: Healthy once every one is synced and healthy, Error when one
+// is degraded or missing its objects, Progressing otherwise.
+func observeArgoCD(app *platformv1alpha1.Application) {
+	ready := metav1.Condition{
+		Type:               platformv1alpha1.Ready,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Synced",
+		Message:            "Argo CD has synced the latest manifests",
+		ObservedGeneration: app.GetGeneration(),
+	}
+	app.Status.Health = platformv1alpha1.HealthHealthy
+	if len(app.Status.ArgoCD) == 0 {
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "NoMatchingClusters", "No cluster matches spec.placement"
+		app.Status.Health = platformv1alpha1.HealthError
+	}
+	for _, status := range app.Status.ArgoCD {
+		if status.Sync == "Synced" && status.Health == "Healthy" {
+			continue
+		}
+		health := platformv1alpha1.HealthProgressing
+		if status.Health == "Degraded" || status.Health == "Missing" {
+			health = platformv1alpha1.HealthError
+		}
+		if app.Status.Health != platformv1alpha1.HealthError {
+			app.Status.Health = health
+		}
+		ready.Status = metav1.ConditionFalse
+		switch {
+		case status.Health == "" && status.Sync == "":
+			ready.Reason = "ArgoCDPending"
+			ready.Message = fmt.Sprintf("Waiting for Argo CD to report on %s", status.Name)
+		case status.Health != "Healthy" && status.Health != "":
+			ready.Reason = status.Health
+			ready.Message = fmt.Sprintf("Argo CD Application %s is %s", status.Name, status.Health)
+		default:
+			ready.Reason = "OutOfSync"
+			ready.Message = fmt.Sprintf("Argo CD Application %s is %s", status.Name, status.Sync)
+		}
+		if status.Cluster != "" {
+			ready.Message += " in cluster " + status.Cluster
+		}
+		if status.Message != "" {
+			ready.Message += ": " + status.Message
+		}
+	}
+	if deployedImage(app) == "" {
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "AwaitingBuild", "No image has been built yet"
+		app.Status.Health = platformv1alpha1.HealthProgressing
+	}
+	apimeta.SetStatusCondition(&app.Status.Conditions, ready)
+}
+
+// listArgoApplications lists the Argo CD Applications of an Application.
+func (r *ApplicationReconciler) listArgoApplications(ctx context.Context, app *platformv1alpha1.Application) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(argoApplicationGVK.GroupVersion().WithKind(argoApplicationGVK.Kind + "List"))
+	if err := r.List(ctx, list,
+		client.InNamespace(r.argoCDNamespace()),
+		client.MatchingLabels(argoApplicationLabels(app))); err != nil {
+		if apimeta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// reconcileArgoCDFinalizer keeps ArgoCDFinalizer on the Application while it uses the
+// argocd backend. Once it is switched back to the direct backend, its Argo CD
+// Applications are deleted, along with the objects they synced, and the finalizer
+// released. Like reconcilePlacementFinalizer, it must run before the status is touched.
+func (r *ApplicationReconciler) reconcileArgoCDFinalizer(ctx context.Context, app *platformv1alpha1.Application) error {
+	synced := utils.ContainsString(app.Finalizers, platformv1alpha1.ArgoCDFinalizer)
+	switch {
+	case usesArgoCD(app) && !synced:
+		app.Finalizers = append(app.Finalizers, platformv1alpha1.ArgoCDFinalizer)
+		return r.Update(ctx, app)
+	case !usesArgoCD(app) && synced:
+		if err := r.finalizeArgoCD(ctx, app); err != nil {
+			return err
+		}
+		app.Status.ArgoCD = nil
+	}
+	return nil
+}
+
+// finalizeArgoCD deletes the Argo CD Applications of the Application, then releases it.
+func (r *ApplicationReconciler) finalizeArgoCD(ctx context.Context, app *platformv1alpha1.Application) error {
+	existing, err := r.listArgoApplications(ctx, app)
+	if err != nil {
+		return err
+	}
+	for i := range existing {
+		if err := r.Delete(ctx, &existing[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	app.Finalizers = utils.RemoveString(app.Finalizers, platformv1alpha1.ArgoCDFinalizer)
+	return r.Update(ctx, app)
+}
+
+// argoCDRequeueAfter is argoCDRequeue for Applications deployed by Argo CD.
+func argoCDRequeueAfter(app *platformv1alpha1.Application) time.Duration {
+	if !usesArgoCD(app) {
+		return 0
+	}
+	return argoCDRequeue
+}
@@ -0,0 +1,348 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/buildstrategy"
+)
+
+// DefaultBuildSettings are the build settings used when neither the operator flags nor
+// the Org or Project of an Application set a value.
+var DefaultBuildSettings = platformv1alpha1.BuildSettings{
+	ImageRegistry:            "ghcr.io/mofe64",
+	GitOpsRepoURL:            "https://github.com/mofe64/vulcan-gitops-repo.git",
+	ServiceAccountName:       "tekton-sa",
+	RegistrySecretName:       "vulkan-docker-config-secret",
+	GitCredentialsSecretName: "vulkan-git-credentials-secret",
+}
+
+// gitOpsAppPath is the directory of the GitOps repository the builds of the Application
+// commit its manifests to, and the argocd backend syncs from.
+func gitOpsAppPath(app *platformv1alpha1.Application) string {
+	return "apps/" + app.Name
+}
+
+// resolveBuildSettings computes the effective build settings of an Application by layering,
+// from lowest to highest precedence, DefaultBuildSettings, the operator-level
+// r.BuildDefaults, the Org's and then the Project's BuildSettings. A missing Org or Project
+// is not an error, its level is simply skipped.
+func (r *ApplicationReconciler) resolveBuildSettings(ctx context.Context, app *platformv1alpha1.Application) (platformv1alpha1.BuildSettings, error) {
+	logger := logf.FromContext(ctx)
+	settings := DefaultBuildSettings.Merge(&r.BuildDefaults)
+
+	if app.Spec.OrgRef != "" {
+		var org platformv1alpha1.Org
+		err := r.Get(ctx, client.ObjectKey{Name: app.Spec.OrgRef}, &org)
+		switch {
+		case err == nil:
+			settings = settings.Merge(org.Spec.BuildSettings)
+		case errors.IsNotFound(err):
+			logger.V(1).Info("Org not found, skipping its build settings", "org", app.Spec.OrgRef)
+		default:
+			return settings, err
+		}
+	}
+
+	if app.Spec.ProjectRef != "" {
+		var proj platformv1alpha1.Project
+		err := r.Get(ctx, client.ObjectKey{Name: app.Spec.ProjectRef}, &proj)
+		switch {
+		case err == nil:
+			settings = settings.Merge(proj.Spec.BuildSettings)
+		case errors.IsNotFound(err):
+			logger.V(1).Info("Project not found, skipping its build settings", "project", app.Spec.ProjectRef)
+		default:
+			return settings, err
+		}
+	}
+	return settings, nil
+}
+
+// buildRunLabels are the labels stamped on every build PipelineRun of an Application.
+// listOwnedPipelineRuns selects on the same ApplicationLabel.
+func buildRunLabels(app *platformv1alpha1.Application) map[string]string {
+	labels := applicationLabels(app)
+	labels["app.kubernetes.io/component"] = "build"
+	return labels
+}
+
+// defaultBuildRef is the branch built when spec.build.ref is empty.
+const defaultBuildRef = "main"
+
+// buildRef returns the branch or tag the Application is built from.
+func buildRef(app *platformv1alpha1.Application) string {
+	if app.Spec.Build.Ref != "" {
+		return app.Spec.Build.Ref
+	}
+	return defaultBuildRef
+}
+
+// requestedRevision returns the commit recorded on the Application by the git webhook
+// receiver, or "" when none was recorded or it was pushed to a ref other than the one
+// the Application builds from (e.g. before spec.build.ref was changed).
+func requestedRevision(app *platformv1alpha1.Application) string {
+	if app.Annotations[platformv1alpha1.SourceRefAnnotation] != buildRef(app) {
+		return ""
+	}
+	return app.Annotations[platformv1alpha1.SourceRevisionAnnotation]
+}
+
+// reconcileLabels keeps the labels the API selects Applications by in line with the
+// spec: OrgLabel and ProjectLabel, and RepoLabel on Applications with a git webhook.
+// Refs that are not valid label values are left unlabelled rather than failing every
+// reconcile. Like reconcilePlacementFinalizer, it must run before the status is touched.
+func (r *ApplicationReconciler) reconcileLabels(ctx context.Context, app *platformv1alpha1.Application) error {
+	want := map[string]string{
+		platformv1alpha1.OrgLabel:     app.Spec.OrgRef,
+		platformv1alpha1.ProjectLabel: app.Spec.ProjectRef,
+		platformv1alpha1.RepoLabel:    "",
+	}
+	if app.Spec.Build.Webhook != nil && app.Spec.RepoURL != "" {
+		want[platformv1alpha1.RepoLabel] = platformv1alpha1.RepoLabelValue(app.Spec.RepoURL)
+	}
+
+	changed := false
+	for key, value := range want {
+		if len(validation.IsValidLabelValue(value)) > 0 {
+			value = ""
+		}
+		current, ok := app.Labels[key]
+		switch {
+		case value == "" && ok:
+			delete(app.Labels, key)
+		case value != "" && current != value:
+			if app.Labels == nil {
+				app.Labels = map[string]string{}
+			}
+			app.Labels[key] = value
+		default:
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r.Update(ctx, app)
+}
+
+// buildFingerprint hashes the fields of the Application spec that affect what gets
+// built, together with the effective build settings (so moving to another registry
+// rebuilds) and the commit requested by the git webhook (so a push rebuilds).
+// Runtime-only fields (env, autoscaling), the webhook configuration and the run
+// retention are left out so changing them does not rebuild the image.
+func buildFingerprint(app *platformv1alpha1.Application, settings platformv1alpha1.BuildSettings) string {
+	build := app.Spec.Build
+	build.Webhook = nil
+	build.Retention = nil
+	raw, _ := json.Marshal(struct {
+		RepoURL  string                         `json:"repoURL"`
+		Build    platformv1alpha1.BuildConfig   `json:"build"`
+		Settings platformv1alpha1.BuildSettings `json:"settings"`
+		Revision string                         `json:"revision,omitempty"`
+	}{
+		RepoURL:  app.Spec.RepoURL,
+		Build:    build,
+		Settings: settings,
+		Revision: requestedRevision(app),
+	})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// needsBuild decides whether a new PipelineRun must be created for the Application given
+// its most recent run (nil when there is none). A build is only started when the build
+// fingerprint differs from the one the latest run was created for, or when the user
+// asked for a rebuild through RebuildRequestedAtAnnotation or for a retry of a failed or
+// cancelled build through RetryRequestedAtAnnotation. A failed build of an unchanged
+// spec is not retried automatically. It returns the BuildTrigger value of the build to
+// start, "" for none, and a message explaining the decision.
+func needsBuild(app *platformv1alpha1.Application, latest *tektonv1.PipelineRun, fingerprint string) (string, string) {
+	if latest == nil {
+		return platformv1alpha1.BuildTriggerConfigChange, "no previous build"
+	}
+	if !latest.IsDone() {
+		return "", "build " + latest.Name + " is still running"
+	}
+	if latest.Annotations[platformv1alpha1.BuildFingerprintAnnotation] != fingerprint {
+		if sha := requestedRevision(app); sha != "" && sha != latest.Annotations[platformv1alpha1.SourceRevisionAnnotation] {
+			return platformv1alpha1.BuildTriggerPush, "commit " + sha + " was pushed"
+		}
+		return platformv1alpha1.BuildTriggerConfigChange, "build configuration changed"
+	}
+	requested := app.Annotations[platformv1alpha1.RebuildRequestedAtAnnotation]
+	if requested != "" && requested != latest.Annotations[platformv1alpha1.RebuildRequestedAtAnnotation] {
+		return platformv1alpha1.BuildTriggerRebuild, "rebuild requested at " + requested
+	}
+	retry := app.Annotations[platformv1alpha1.RetryRequestedAtAnnotation]
+	if retry != "" && retry != latest.Annotations[platformv1alpha1.RetryRequestedAtAnnotation] &&
+		buildOutcome(latest) != platformv1alpha1.BuildOutcomeSucceeded {
+		return platformv1alpha1.BuildTriggerRetry, "retry requested at " + retry
+	}
+	return "", "build " + latest.Name + " is up to date"
+}
+
+// markBuildTrigger records on a PipelineRun about to be created why it is started and,
+// unless it follows a spec change, who requested it: the pusher of the commit for a
+// push, the requester of the latest API operation otherwise.
+func markBuildTrigger(run *tektonv1.PipelineRun, app *platformv1alpha1.Application, trigger string) {
+	run.Annotations[platformv1alpha1.BuildTriggerAnnotation] = trigger
+	by := app.Annotations[platformv1alpha1.RequestedByAnnotation]
+	if trigger == platformv1alpha1.BuildTriggerPush {
+		by = app.Annotations[platformv1alpha1.SourcePushedByAnnotation]
+	}
+	if by != "" && trigger != platformv1alpha1.BuildTriggerConfigChange {
+		run.Annotations[platformv1alpha1.BuildTriggeredByAnnotation] = by
+	}
+}
+
+// cancelRequestedBuild sets the latest PipelineRun to Cancelled when a cancellation was
+// requested through CancelRequestedAtAnnotation and not yet applied to it. Runs copy the
+// annotation when they are created, so a past request never cancels a newer build.
+func (r *ApplicationReconciler) cancelRequestedBuild(ctx context.Context, app *platformv1alpha1.Application, latest *tektonv1.PipelineRun) error {
+	requested := app.Annotations[platformv1alpha1.CancelRequestedAtAnnotation]
+	if latest == nil || requested == "" || latest.IsDone() ||
+		requested == latest.Annotations[platformv1alpha1.CancelRequestedAtAnnotation] {
+		return nil
+	}
+
+	logf.FromContext(ctx).Info("Cancelling PipelineRun on request", "PipelineRun.Name", latest.Name, "requestedAt", requested)
+	patch := client.MergeFrom(latest.DeepCopy())
+	latest.Spec.Status = tektonv1.PipelineRunSpecStatusCancelled
+	if latest.Annotations == nil {
+		latest.Annotations = map[string]string{}
+	}
+	latest.Annotations[platformv1alpha1.CancelRequestedAtAnnotation] = requested
+	if by := app.Annotations[platformv1alpha1.RequestedByAnnotation]; by != "" {
+		latest.Annotations[platformv1alpha1.BuildCancelledByAnnotation] = by
+	}
+	return r.Patch(ctx, latest, patch)
+}
+
+// renderPipelineRun builds the PipelineRun for the Application: the params common to all
+// pipelines, the strategy-specific ones, the workspaces the strategy needs and, for
+// Secret-backed build env, a pod template injecting the values into the build task.
+func renderPipelineRun(
+	app *platformv1alpha1.Application,
+	settings platformv1alpha1.BuildSettings,
+	strategy buildstrategy.BuildStrategy,
+	fingerprint string,
+) *tektonv1.PipelineRun {
+	// Base image name (without tag)
+	baseImageName := fmt.Sprintf("%s/%s", strings.TrimSuffix(settings.ImageRegistry, "/"), app.Name)
+
+	// Use the application's build ref as the initial image tag, or the commit when
+	// a specific one was requested through the git webhook.
+	// This tag will be used *during* the build step of the pipeline.
+	// The GitOps update will use the digest for immutability.
+	revision := buildRef(app)
+	imageTag := revision
+	if sha := requestedRevision(app); sha != "" {
+		revision = sha
+		imageTag = sha
+	}
+
+	params := []tektonv1.Param{
+		buildstrategy.StringParam("repo-url", app.Spec.RepoURL),
+		buildstrategy.StringParam("branch", revision),
+		buildstrategy.StringParam("image-name", baseImageName),
+		buildstrategy.StringParam("image-tag", imageTag),
+		buildstrategy.StringParam("gitops-repo-url", settings.GitOpsRepoURL),
+		buildstrategy.StringParam("gitops-app-path", gitOpsAppPath(app)),
+		buildstrategy.StringParam("app-name", app.Name),
+	}
+	params = append(params, strategy.Params(app)...)
+
+	var workspaces []tektonv1.WorkspaceBinding
+	for _, name := range strategy.Workspaces() {
+		switch name {
+		case buildstrategy.SourceWorkspace:
+			workspaces = append(workspaces, tektonv1.WorkspaceBinding{
+				Name: name,
+				VolumeClaimTemplate: &corev1.PersistentVolumeClaim{
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{
+							corev1.ReadWriteOnce,
+						},
+						Resources: corev1.VolumeResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceStorage: resource.MustParse("1Gi"),
+							},
+						},
+					},
+				},
+			})
+		case buildstrategy.DockerConfigWorkspace:
+			// secret for registry push access
+			workspaces = append(workspaces, tektonv1.WorkspaceBinding{
+				Name:   name,
+				Secret: &corev1.SecretVolumeSource{SecretName: settings.RegistrySecretName},
+			})
+		case buildstrategy.GitCredentialsWorkspace:
+			// secret for GitOps repo write access
+			workspaces = append(workspaces, tektonv1.WorkspaceBinding{
+				Name:   name,
+				Secret: &corev1.SecretVolumeSource{SecretName: settings.GitCredentialsSecretName},
+			})
+		}
+	}
+
+	run := &tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-build-", app.Name),
+			Namespace:    app.Namespace,
+			Labels:       buildRunLabels(app),
+			Annotations: map[string]string{
+				platformv1alpha1.BuildFingerprintAnnotation: fingerprint,
+				platformv1alpha1.BuildStrategyAnnotation:    strategy.Name(),
+			},
+		},
+		Spec: tektonv1.PipelineRunSpec{
+			PipelineRef: &tektonv1.PipelineRef{Name: strategy.PipelineRef(app)},
+			Params:      params,
+			Workspaces:  workspaces,
+			TaskRunTemplate: tektonv1.PipelineTaskRunTemplate{
+				ServiceAccountName: settings.ServiceAccountName,
+			},
+		},
+	}
+
+	if _, _, secretEnv := buildstrategy.SplitBuildEnv(app.Spec.Build.Env); len(secretEnv) > 0 {
+		podTemplate := &pod.PodTemplate{Env: secretEnv}
+		if task := strategy.BuildTask(); task != "" {
+			run.Spec.TaskRunSpecs = []tektonv1.PipelineTaskRunSpec{{PipelineTaskName: task, PodTemplate: podTemplate}}
+		} else {
+			run.Spec.TaskRunTemplate.PodTemplate = podTemplate
+		}
+	}
+
+	for _, key := range []string{
+		platformv1alpha1.RebuildRequestedAtAnnotation,
+		platformv1alpha1.RetryRequestedAtAnnotation,
+		platformv1alpha1.CancelRequestedAtAnnotation,
+	} {
+		if requested := app.Annotations[key]; requested != "" {
+			run.Annotations[key] = requested
+		}
+	}
+	if sha := requestedRevision(app); sha != "" {
+		run.Annotations[platformv1alpha1.SourceRevisionAnnotation] = sha
+	}
+	return run
+}
@@ -2,12 +2,15 @@ package controller
 
 import (
 	"context"
-	"fmt"
-	"reflect"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -18,27 +21,91 @@ import (
 	// argov1alpha1 "github.com/argoproj/argo-cd/v3.0.9/pkg/apis/application/v1alpha1"
 
 	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/buildstrategy"
+	"github.com/mofe64/vulkan/operator/internal/imageref"
+	"github.com/mofe64/vulkan/operator/internal/secretstore"
+	"github.com/mofe64/vulkan/operator/internal/utils"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
-	"knative.dev/pkg/apis"
 )
 
 // ApplicationReconciler reconciles a Application object
 type ApplicationReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// BuildDefaults are the operator-level build settings (from flags). Empty fields
+	// fall back to DefaultBuildSettings; Orgs and Projects may override them.
+	BuildDefaults platformv1alpha1.BuildSettings
+
+	// Strategies resolves Spec.Build.Strategy, buildstrategy.Default() when nil.
+	Strategies *buildstrategy.Registry
+
+	// ImageResolver pins prebuilt images to digests, an HTTP registry client when nil.
+	ImageResolver imageref.Resolver
+
+	// BuildRetention is the operator-level history limit for build PipelineRuns (from
+	// flags). Unset limits fall back to DefaultBuildRetention; Applications may override it.
+	BuildRetention platformv1alpha1.BuildRetention
+
+	// PreviewDomain is the wildcard domain preview environments get their hostname under
+	// (from flags), unless their parent sets spec.previews.domain.
+	PreviewDomain string
+
+	// IngressClassName is the class of the Ingresses exposing Applications, nginx when empty.
+	IngressClassName string
+
+	// AppsDomain is the wildcard domain Applications get their default hostname under
+	// (from flags), as <app>-<namespace>.<domain>. No default hostname when empty.
+	AppsDomain string
+
+	// ClusterIssuer is the cert-manager ClusterIssuer the certificates of Applications are
+	// requested from (from flags). Applications are served over plain HTTP when empty.
+	ClusterIssuer string
+
+	// Secrets reads the platform-managed secrets of Applications. When nil (no database
+	// configured) they are not synced: Applications with managed secrets keep the ones
+	// last synced and get SecretsUnavailable.
+	Secrets secretstore.Store
+
+	// ArgoCDNamespace is where the Argo CD Applications of the argocd backend are created
+	// (from flags), argocd when empty.
+	ArgoCDNamespace string
+
+	// TargetFactory builds clients for the remote clusters selected by spec.placement.
+	TargetFactory utils.TargetClientFactory
+
+	// cluster is set on the copies of the reconciler rendering the workload in one of the
+	// clusters of spec.placement. remote is set when it is not the control plane, whose
+	// client is then controlPlane.
+	cluster      string
+	remote       bool
+	controlPlane client.Client
 }
 
 // +kubebuilder:rbac:groups=platform.platform.io,resources=applications,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=platform.platform.io,resources=applications/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=platform.platform.io,resources=applications/finalizers,verbs=update
+// +kubebuilder:rbac:groups=platform.platform.io,resources=orgs;projects,verbs=get;list;watch
+// +kubebuilder:rbac:groups=platform.platform.io,resources=clusters;projectclusterbindings,verbs=get;list;watch
 
 // +kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // add permissions for PVC creation by controller
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 
+// permissions for the workload rendered from the built image
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=resourcequotas;limitranges,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch;create;update;patch;delete
+
 func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := logf.FromContext(ctx)
 	logger.Info("Reconciling Application", "namespace", req.Namespace, "name", req.Name)
@@ -58,223 +125,216 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	// define PipelineRun name and other variables
-	// pipelineRunName := fmt.Sprintf("%s-build-%s", application.Name, time.Now().Format("20060102150405"))
-	// Base image name (without tag)
-	baseImageName := fmt.Sprintf("ghcr.io/mofe64/%s", application.Name)
-
-	// Use the application's build ref as the initial image tag
-	// This tag will be used *during* the build step of the pipeline.
-	// The GitOps update will use the digest for immutability.
-	imageTag := application.Spec.Build.Ref
-
-	var buildParams []tektonv1.Param
-	var pipelineRef string
-
-	buildParams = []tektonv1.Param{
-		{Name: "repo-url", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: application.Spec.RepoURL}},
-		{Name: "branch", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: application.Spec.Build.Ref}},
-		{Name: "image-name", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: baseImageName}},
-		{Name: "image-tag", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: imageTag}},
-		{Name: "gitops-repo-url", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: "https://github.com/mofe64/vulcan-gitops-repo.git"}},
-		{Name: "gitops-app-path", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: fmt.Sprintf("apps/%s", application.Name)}},
-		{Name: "app-name", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: application.Name}},
-	}
-
-	switch application.Spec.Build.Strategy {
-	case "dockerfile":
-		// for dockerfile strategy, use either specified path or default to "./Dockerfile"
-		dockerfilePath := "./Dockerfile" // Default path
-		if application.Spec.Build.Dockerfile != "" {
-			dockerfilePath = application.Spec.Build.Dockerfile
+	// workloads placed in remote clusters and Argo CD Applications are deleted before
+	// the Application is released
+	if !application.DeletionTimestamp.IsZero() {
+		if utils.ContainsString(application.Finalizers, platformv1alpha1.PlacementFinalizer) {
+			if err := r.finalizePlacement(ctx, application); err != nil {
+				logger.Error(err, "Failed to remove Application from its clusters")
+				return ctrl.Result{}, err
+			}
 		}
-
-		buildParams = append(buildParams, tektonv1.Param{
-			Name:  "dockerfile-path",
-			Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: dockerfilePath},
-		})
-		// Add context-dir and build-args if your custom resource supports them
-		// For example:
-		// if application.Spec.Build.ContextDir != "" {
-		// 	buildParams = append(buildParams, tektonv1beta1.Param{
-		// 		Name:  "context-dir",
-		// 		Value: tektonv1beta1.ParamValue{Type: tektonv1beta1.ParamTypeString, StringVal: application.Spec.Build.ContextDir},
-		// 	})
-		// }
-		// if application.Spec.Build.BuildArgs != "" {
-		// 	buildParams = append(buildParams, tektonv1beta1.Param{
-		// 		Name:  "build-args",
-		// 		Value: tektonv1beta1.ParamValue{Type: tektonv1beta1.ParamTypeString, StringVal: application.Spec.Build.BuildArgs},
-		// 	})
-		// }
-
-		// use dockerfile-specific pipeline
-		pipelineRef = "app-build-dockerfile"
-
-	case "buildpack":
-		// for buildpack strategy, add buildpack-specific parameters
-		buildParams = append(buildParams, tektonv1.Param{
-			Name:  "builder-image",
-			Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: "paketobuildpacks/builder:base"}, // Default buildpack builder
-		})
-		// Add env-vars
-		// For example:
-		// if application.Spec.Build.EnvVars != "" {
-		// 	buildParams = append(buildParams, tektonv1beta1.Param{
-		// 		Name:  "env-vars",
-		// 		Value: tektonv1beta1.ParamValue{Type: tektonv1beta1.ParamTypeString, StringVal: application.Spec.Build.EnvVars},
-		// 	})
-		// }
-
-		// use buildpack-specific pipeline
-		pipelineRef = "app-build-buildpack"
-
-	default:
-		return ctrl.Result{}, fmt.Errorf("invalid build strategy: %s", application.Spec.Build.Strategy)
+		if utils.ContainsString(application.Finalizers, platformv1alpha1.ArgoCDFinalizer) {
+			if err := r.finalizeArgoCD(ctx, application); err != nil {
+				logger.Error(err, "Failed to delete Argo CD Applications of Application")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.reconcilePlacementFinalizer(ctx, application); err != nil {
+		logger.Error(err, "Failed to update placement finalizer of Application")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileArgoCDFinalizer(ctx, application); err != nil {
+		logger.Error(err, "Failed to update Argo CD finalizer of Application")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileLabels(ctx, application); err != nil {
+		logger.Error(err, "Failed to update labels of Application")
+		return ctrl.Result{}, err
 	}
 
-	// define the desired PipelineRun
-	desiredPipelineRun := &tektonv1.PipelineRun{
-		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: fmt.Sprintf("%s-build-", application.Name), // Better for multiple runs
-			Namespace:    application.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/managed-by": "vulkan-operator",
-				"app":                          application.Name,
-			},
-		},
-		Spec: tektonv1.PipelineRunSpec{
-			PipelineRef: &tektonv1.PipelineRef{
-				Name: pipelineRef,
-			},
-			Params: buildParams,
-			Workspaces: []tektonv1.WorkspaceBinding{
-				{
-					Name: "source-workspace",
-					VolumeClaimTemplate: &corev1.PersistentVolumeClaim{
-						Spec: corev1.PersistentVolumeClaimSpec{
-							// Define your PVC spec suitable for Tekton
-							// e.g., StorageClassName and resources
-							AccessModes: []corev1.PersistentVolumeAccessMode{
-								corev1.ReadWriteOnce,
-							},
-							Resources: corev1.VolumeResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceStorage: resource.MustParse("1Gi"),
-								},
-							},
-						},
-					},
-				},
-				// You might need a workspace for Docker config or Git credentials
-				{
-					Name:   "docker-config",
-					Secret: &corev1.SecretVolumeSource{SecretName: "vulkan-docker-config-secret"}, // Your secret for GHCR access
-
-				},
-				{
-					Name:   "git-credentials",
-					Secret: &corev1.SecretVolumeSource{SecretName: "vulkan-git-credentials-secret"}, // Your secret for GitOps repo write access
-
-				},
-			},
-			TaskRunTemplate: tektonv1.PipelineTaskRunTemplate{
-				ServiceAccountName: "tekton-sa",
-			},
-		},
+	// previews are tied to their parent and deleted once expired
+	host, deleted, err := r.reconcilePreview(ctx, application)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile preview Application")
+		return ctrl.Result{}, err
+	}
+	if deleted {
+		return ctrl.Result{}, nil
 	}
 
-	// Set the Application as the owner of the PipelineRun.
-	// This ensures that when the Application is deleted, the PipelineRun is also garbage collected.
-	if err := ctrl.SetControllerReference(application, desiredPipelineRun, r.Scheme); err != nil {
-		logger.Error(err, "Failed to set owner reference for PipelineRun")
+	// resolve the build strategy; an unknown strategy or an invalid build config cannot be
+	// fixed by retrying, so it is surfaced as a condition and the request is not requeued
+	strategy, specErr := r.strategies().Resolve(application)
+	_, prebuilt := strategy.(buildstrategy.Prebuilt)
+
+	// observe the builds owned by this Application so status reflects their outcome
+	ownedRuns, err := r.listOwnedPipelineRuns(ctx, application)
+	if err != nil {
+		logger.Error(err, "Failed to list PipelineRuns owned by Application")
 		return ctrl.Result{}, err
 	}
+	r.observeBuilds(application, ownedRuns, prebuilt)
 
-	// Check if a build is already in progress or if spec has changed.
-	// For continuous builds on spec changes, it's often simpler to just
-	// *always* create a new PipelineRun if the spec is different from the last *successful* one.
-	// This simplified logic checks for an *existing* PipelineRun linked to this Application
-	// that matches the current desired parameters.
-
-	// A more robust reconciliation for builds typically involves:
-	// 1. Finding the latest PipelineRun for this Application.
-	// 2. Checking its status (Succeeded, Failed, Running).
-	// 3. If Succeeded AND Application.Spec matches the PipelineRun's params, do nothing.
-	// 4. If Failed OR Application.Spec has changed, create a NEW PipelineRun.
-	// 5. If Running, do nothing (wait for it to complete).
-
-	// For simplicity, let's implement a pattern that triggers a new build if:
-	// - No existing PipelineRun for this Application
-	// - OR the latest PipelineRun for this Application has different build-related parameters
-	// - OR the latest PipelineRun for this Application has failed or cancelled
-
-	// List PipelineRuns owned by this Application
-	existingRuns := &tektonv1.PipelineRunList{}
-	listOpts := []client.ListOption{
-		client.InNamespace(application.Namespace),
-		client.MatchingLabels(map[string]string{"vulkan.io/application": application.Name}),
+	// Only build when the build-relevant spec changed since the latest run or a rebuild
+	// was requested explicitly; ownedRuns is sorted newest first.
+	var latestRun *tektonv1.PipelineRun
+	if len(ownedRuns) > 0 {
+		latestRun = &ownedRuns[0]
+	}
+	if err := r.cancelRequestedBuild(ctx, application, latestRun); err != nil {
+		logger.Error(err, "Failed to cancel PipelineRun")
+		return ctrl.Result{}, err
 	}
-	if err := r.List(ctx, existingRuns, listOpts...); err != nil {
-		logger.Error(err, "Failed to list PipelineRuns for Application")
+	// builds are already recorded in the history, drop the runs beyond the retention limits
+	if err := r.pruneBuilds(ctx, application, ownedRuns); err != nil {
+		logger.Error(err, "Failed to prune old PipelineRuns")
 		return ctrl.Result{}, err
 	}
 
-	var latestRun *tektonv1.PipelineRun
-	for i := range existingRuns.Items {
-		run := &existingRuns.Items[i]
-		if latestRun == nil || run.CreationTimestamp.After(latestRun.CreationTimestamp.Time) {
-			latestRun = run
+	reason := "InvalidBuildConfig"
+	if specErr == nil && application.Spec.Placement == nil && !usesArgoCD(application) {
+		// pods the project quota cannot admit would never start, reject the spec instead;
+		// placed Applications are checked against the quota of each of their clusters, and
+		// the pods synced by Argo CD are not rendered from the spec
+		if err := r.checkQuota(ctx, application); isQuotaExceeded(err) {
+			specErr, reason = err, "QuotaExceeded"
+		} else if err != nil {
+			logger.Error(err, "Failed to check Application against the project quota")
+			return ctrl.Result{}, err
 		}
 	}
-
-	shouldCreateNewRun := true
-	if latestRun != nil {
-		// Check if the latest run is still running or succeeded and matches current spec
-		isFinished := latestRun.Status.CompletionTime != nil
-		isSucceeded := false
-		successCondition := latestRun.Status.GetCondition(apis.ConditionSucceeded)
-		if isFinished && successCondition != nil && successCondition.IsTrue() {
-			isSucceeded = true
+	var resolveErr error
+	if specErr != nil {
+		apimeta.SetStatusCondition(&application.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.InvalidSpec,
+			Status:             metav1.ConditionTrue,
+			Reason:             reason,
+			Message:            specErr.Error(),
+			ObservedGeneration: application.GetGeneration(),
+		})
+	} else {
+		apimeta.RemoveStatusCondition(&application.Status.Conditions, platformv1alpha1.InvalidSpec)
+		if prebuilt {
+			resolveErr = r.resolvePrebuiltImage(ctx, application, strategy.(buildstrategy.Prebuilt).Image(application))
 		}
+	}
 
-		// Compare current desired parameters with the latest PipelineRun's parameters
-		// This requires iterating and comparing all relevant parameters
-		currentParamsMap := make(map[string]string)
-		for _, p := range buildParams {
-			currentParamsMap[p.Name] = p.Value.StringVal
+	// roll out the last built image (if any), or the one a rollback pinned, before
+	// deciding whether a new build is needed
+	observeRollback(application)
+	switch {
+	case usesArgoCD(application):
+		if err := r.reconcileArgoCD(ctx, application); err != nil {
+			logger.Error(err, "Failed to reconcile Argo CD Applications of Application")
+			return ctrl.Result{}, err
 		}
-
-		latestRunParamsMap := make(map[string]string)
-		for _, p := range latestRun.Spec.Params {
-			latestRunParamsMap[p.Name] = p.Value.StringVal
+	case application.Spec.Placement != nil:
+		if err := r.reconcilePlacement(ctx, application, host); err != nil {
+			logger.Error(err, "Failed to reconcile Application in its clusters")
+			return ctrl.Result{}, err
 		}
+	default:
+		if !isQuotaExceeded(specErr) {
+			if err := r.reconcileWorkload(ctx, application); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if err := r.reconcileRoutes(ctx, application, host); err != nil {
+			logger.Error(err, "Failed to reconcile routes for Application")
+			return ctrl.Result{}, err
+		}
+		if err := r.observeWorkload(ctx, application); err != nil {
+			logger.Error(err, "Failed to observe Application workload")
+			return ctrl.Result{}, err
+		}
+	}
 
-		paramsMatch := reflect.DeepEqual(currentParamsMap, latestRunParamsMap)
+	if err := utils.UpdateApplicationStatusWithRetry(ctx, r.Client, application); err != nil {
+		logger.Error(err, "Failed to update Application status")
+		return ctrl.Result{}, err
+	}
+	// a progressive rollout in flight is re-checked for pauses and degraded pods, a
+	// preview is deleted once it expires, conflicting routes are checked again and the
+	// workload in remote clusters and the sync of Argo CD are looked at again
+	result := ctrl.Result{RequeueAfter: soonest(rolloutRequeueAfter(application), previewRequeueAfter(application),
+		routeRequeueAfter(application), placementRequeueAfter(application), argoCDRequeueAfter(application))}
+
+	if specErr != nil {
+		logger.Info("Application spec is invalid, not building", "reason", specErr.Error())
+		return result, nil
+	}
+	if resolveErr != nil {
+		logger.Error(resolveErr, "Failed to resolve prebuilt image")
+		return ctrl.Result{}, resolveErr
+	}
+	if strategy.PipelineRef(application) == "" {
+		logger.Info("Build strategy does not build, nothing else to do", "strategy", strategy.Name())
+		return result, nil
+	}
 
-		if !isFinished || (isSucceeded && paramsMatch) {
-			// If a run is still active, or if the latest successful run matches parameters,
-			// we don't need to create a new one.
-			logger.Info("Existing PipelineRun is active, succeeded and matches, or still running. Skipping new run creation.",
-				"PipelineRun.Name", latestRun.Name, "Succeeded", isSucceeded, "Finished", isFinished, "ParamsMatch", paramsMatch)
-			shouldCreateNewRun = false
-		}
+	// resolve where to push the image and which credentials to build with
+	settings, err := r.resolveBuildSettings(ctx, application)
+	if err != nil {
+		logger.Error(err, "Failed to resolve build settings for Application")
+		return ctrl.Result{}, err
 	}
 
-	if shouldCreateNewRun {
-		logger.Info("Creating new Tekton PipelineRun", "PipelineRun.GenerateName", desiredPipelineRun.GenerateName)
+	// define the desired PipelineRun
+	fingerprint := buildFingerprint(application, settings)
+	desiredPipelineRun := renderPipelineRun(application, settings, strategy, fingerprint)
+
+	// Set the Application as the owner of the PipelineRun.
+	// This ensures that when the Application is deleted, the PipelineRun is also garbage collected.
+	if err := ctrl.SetControllerReference(application, desiredPipelineRun, r.Scheme); err != nil {
+		logger.Error(err, "Failed to set owner reference for PipelineRun")
+		return ctrl.Result{}, err
+	}
+
+	trigger, why := needsBuild(application, latestRun, fingerprint)
+	if trigger == "" {
+		logger.Info("Skipping new PipelineRun creation", "reason", why)
+	}
+
+	if trigger != "" {
+		markBuildTrigger(desiredPipelineRun, application, trigger)
+		logger.Info("Creating new Tekton PipelineRun", "PipelineRun.GenerateName", desiredPipelineRun.GenerateName, "strategy", strategy.Name(), "trigger", trigger)
 		err = r.Create(ctx, desiredPipelineRun)
 		if err != nil {
 			logger.Error(err, "Failed to create new PipelineRun", "PipelineRun.GenerateName", desiredPipelineRun.GenerateName)
 			return ctrl.Result{}, err
 		}
-		logger.Info("New PipelineRun created successfully", "PipelineRun.Name", desiredPipelineRun.Name)
-		// Requeue immediately to observe the newly created PipelineRun
-		return ctrl.Result{Requeue: true}, nil
+		// the PipelineRun is owned by the Application, its status changes trigger the next reconcile
+		logger.Info("New PipelineRun created successfully", "PipelineRun.Name", desiredPipelineRun.Name, "reason", why)
+		return result, nil
 	}
 
 	logger.Info("Reconciliation finished successfully! No new PipelineRun needed.")
-	return ctrl.Result{}, nil
+	return result, nil
+}
+
+// soonest returns the shortest of the non-zero durations, zero if there are none.
+func soonest(durations ...time.Duration) time.Duration {
+	var d time.Duration
+	for _, candidate := range durations {
+		if candidate > 0 && (d == 0 || candidate < d) {
+			d = candidate
+		}
+	}
+	return d
+}
+
+// defaultStrategies backs ApplicationReconciler.Strategies when it is not set.
+var defaultStrategies = buildstrategy.Default()
+
+// strategies returns the build strategy registry, the built-in one unless overridden.
+func (r *ApplicationReconciler) strategies() *buildstrategy.Registry {
+	if r.Strategies != nil {
+		return r.Strategies
+	}
+	return defaultStrategies
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -283,10 +343,20 @@ func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := tektonv1beta1.AddToScheme(mgr.GetScheme()); err != nil {
 		return err
 	}
+	if err := tektonv1.AddToScheme(mgr.GetScheme()); err != nil {
+		return err
+	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&platformv1alpha1.Application{}).
 		Owns(&tektonv1.PipelineRun{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.Secret{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&batchv1.Job{}).
+		Owns(&batchv1.CronJob{}).
+		Owns(&networkingv1.Ingress{}).
 		Named("application").
 		Complete(r)
 }
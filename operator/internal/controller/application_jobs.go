@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// Kinds of Jobs run for an Application, part of their name.
+const (
+	jobKindRelease   = "release"
+	jobKindPreDeploy = "predeploy"
+)
+
+// jobTTLAfterFinished is how long finished Jobs are kept; their outcome stays in
+// ApplicationStatus.Job.
+const jobTTLAfterFinished int32 = 24 * 60 * 60
+
+// applicationType is spec.type, web when unset.
+func applicationType(app *platformv1alpha1.Application) platformv1alpha1.ApplicationType {
+	if app.Spec.Type == "" {
+		return platformv1alpha1.ApplicationTypeWeb
+	}
+	return app.Spec.Type
+}
+
+// runsDeployment reports whether the Application runs as a Deployment: web and worker
+// Applications do, cron and job Applications run their image as Jobs.
+func runsDeployment(app *platformv1alpha1.Application) bool {
+	switch applicationType(app) {
+	case platformv1alpha1.ApplicationTypeCron, platformv1alpha1.ApplicationTypeJob:
+		return false
+	}
+	return true
+}
+
+// jobName is <app>-<kind>-<hash of the image>, so every image gets its own Job.
+func jobName(app *platformv1alpha1.Application, kind, image string) string {
+	sum := sha256.Sum256([]byte(image))
+	return hostLabel(app.Name + "-" + kind + "-" + hex.EncodeToString(sum[:4]))
+}
+
+// jobPodLabels label the pods of Jobs. They leave out ApplicationLabel so the pods are
+// never selected by the Service or the Deployments of the Application; the API server
+// finds them for logs by the app.kubernetes.io/name label they share with its other pods.
+func jobPodLabels(app *platformv1alpha1.Application, kind string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":        app.Name,
+		"app.kubernetes.io/component":   kind,
+		platformv1alpha1.ManagedByLabel: platformv1alpha1.ManagedByValue,
+	}
+}
+
+// reconcileCronWorkload runs the image of a cron Application on spec.schedule.
+func (r *ApplicationReconciler) reconcileCronWorkload(ctx context.Context, app *platformv1alpha1.Application) error {
+	image := deployedImage(app)
+	cron := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace},
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cron, func() error {
+		cron.Labels = applicationLabels(app)
+		cron.Spec.Schedule = app.Spec.Schedule
+		// a run still going when the next one is due is not doubled up
+		cron.Spec.ConcurrencyPolicy = batchv1.ForbidConcurrent
+		cron.Spec.JobTemplate.Labels = applicationLabels(app)
+		r.renderJobSpec(app, &cron.Spec.JobTemplate.Spec, jobKindRelease, image, nil)
+		return r.setOwner(app, cron)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("CronJob reconciled", "CronJob.Name", cron.Name, "operation", op, "image", image)
+	}
+	recordDeployment(app)
+	app.Status.Job = nil
+	apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.JobFailed)
+	return nil
+}
+
+// reconcileJobWorkload runs the image of a job Application once: a new image gets a new
+// Job, a finished one is not run again.
+func (r *ApplicationReconciler) reconcileJobWorkload(ctx context.Context, app *platformv1alpha1.Application) error {
+	if err := r.runJob(ctx, app, jobKindRelease, deployedImage(app), nil); err != nil {
+		return err
+	}
+	recordDeployment(app)
+	return nil
+}
+
+// reconcilePreDeploy runs the pre-deploy hook with the image about to be rolled out and
+// reports whether it may be rolled out. current is the image the stable Deployment runs.
+// Rollbacks redeploy an image that already went through the hook and skip it. Previews
+// skip it as well: they run with the parent's environment, so a migration of their
+// branch would run against the parent's database.
+func (r *ApplicationReconciler) reconcilePreDeploy(ctx context.Context, app *platformv1alpha1.Application, current string) (bool, error) {
+	hook := app.Spec.PreDeploy
+	if hook == nil || app.Spec.Preview != nil {
+		app.Status.Job = nil
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.JobFailed)
+		return true, nil
+	}
+	target := deployedImage(app)
+	if app.Status.Pinned != nil || current == target {
+		return true, nil
+	}
+	if err := r.runJob(ctx, app, jobKindPreDeploy, target, hook); err != nil {
+		return false, err
+	}
+	return app.Status.Job.Phase == platformv1alpha1.JobPhaseSucceeded, nil
+}
+
+// runJob makes sure a Job of the given kind ran the image and records its state in
+// Status.Job, with the JobFailed condition when it failed. The Job is only created
+// once per image: once it finished, its outcome is taken from the status. A Job still
+// running for a previous image is deleted, its image will not be rolled out anyway.
+// Placed Applications run the Job in one cluster only, the others wait for its outcome.
+func (r *ApplicationReconciler) runJob(ctx context.Context, app *platformv1alpha1.Application, kind, image string, hook *platformv1alpha1.PreDeployHook) error {
+	logger := logf.FromContext(ctx)
+	status := app.Status.Job
+	if status != nil && status.Image == image && (status.Phase != platformv1alpha1.JobPhaseRunning || status.Cluster != r.cluster) {
+		return nil
+	}
+	if status != nil && status.Image != image && status.Phase == platformv1alpha1.JobPhaseRunning {
+		stale := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: status.Name, Namespace: app.Namespace}}
+		if err := r.Delete(ctx, stale, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Deleted Job of a superseded image", "Job.Name", status.Name, "image", status.Image)
+	}
+
+	job := &batchv1.Job{}
+	name := jobName(app, kind, image)
+	err := r.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: name}, job)
+	if errors.IsNotFound(err) {
+		job = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace, Labels: applicationLabels(app)},
+		}
+		r.renderJobSpec(app, &job.Spec, kind, image, hook)
+		ttl := jobTTLAfterFinished
+		job.Spec.TTLSecondsAfterFinished = &ttl
+		if err := r.setOwner(app, job); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return err
+		}
+		logger.Info("Created Job", "Job.Name", name, "kind", kind, "image", image)
+	} else if err != nil {
+		return err
+	}
+
+	app.Status.Job = jobStatusFor(job, image)
+	app.Status.Job.Cluster = r.cluster
+	if app.Status.Job.Phase == platformv1alpha1.JobPhaseFailed {
+		message := "Job " + name + " failed"
+		if app.Status.Job.Message != "" {
+			message += ": " + app.Status.Job.Message
+		}
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.JobFailed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "JobFailed",
+			Message:            message,
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.JobFailed)
+	}
+	return nil
+}
+
+// jobStatusFor summarises a Job running the image.
+func jobStatusFor(job *batchv1.Job, image string) *platformv1alpha1.JobStatus {
+	status := &platformv1alpha1.JobStatus{Name: job.Name, Image: image, Phase: platformv1alpha1.JobPhaseRunning}
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			status.Phase = platformv1alpha1.JobPhaseSucceeded
+			status.CompletionTime = job.Status.CompletionTime
+		case batchv1.JobFailed:
+			status.Phase = platformv1alpha1.JobPhaseFailed
+			status.Message = cond.Message
+			status.CompletionTime = &cond.LastTransitionTime
+		}
+	}
+	return status
+}
+
+// renderJobSpec writes the pod template of a Job running the image: the application
+// container without ports and probes, run once. The pre-deploy hook replaces its command.
+func (r *ApplicationReconciler) renderJobSpec(app *platformv1alpha1.Application, spec *batchv1.JobSpec, kind, image string, hook *platformv1alpha1.PreDeployHook) {
+	backoffLimit := int32(0)
+	if app.Spec.Job.BackoffLimit != nil {
+		backoffLimit = *app.Spec.Job.BackoffLimit
+	}
+	spec.BackoffLimit = &backoffLimit
+	spec.ActiveDeadlineSeconds = app.Spec.Job.ActiveDeadlineSeconds
+
+	spec.Template.Labels = jobPodLabels(app, kind)
+	spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	spec.Template.Spec.ImagePullSecrets = imagePullSecrets(app)
+	r.renderAppContainer(app, &spec.Template.Spec, image)
+	r.renderSecretsVersion(app, &spec.Template)
+	for i := range spec.Template.Spec.Containers {
+		container := &spec.Template.Spec.Containers[i]
+		if container.Name != appContainerName {
+			continue
+		}
+		container.Ports = nil
+		container.LivenessProbe = nil
+		container.ReadinessProbe = nil
+		container.StartupProbe = nil
+		if hook != nil {
+			container.Command = hook.Command
+			container.Args = hook.Args
+		}
+	}
+}
+
+// removeDeploymentWorkload deletes the Deployment, Service and HorizontalPodAutoscaler
+// left from when the Application ran as a web or worker Application.
+func (r *ApplicationReconciler) removeDeploymentWorkload(ctx context.Context, app *platformv1alpha1.Application) error {
+	app.Status.Rollout = nil
+	apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RollingOut)
+	apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RolloutAborted)
+	if err := r.removeRolloutCandidate(ctx, app); err != nil {
+		return err
+	}
+	objs := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}},
+		&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}},
+	}
+	for _, obj := range objs {
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeCronJob deletes the CronJob left from when the Application was a cron Application.
+func (r *ApplicationReconciler) removeCronJob(ctx context.Context, app *platformv1alpha1.Application) error {
+	cron := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	if err := r.Delete(ctx, cron); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// observeJobWorkload sets the Ready condition and Health of cron and job Applications:
+// from the CronJob for cron Applications, from the Job of the latest image for job ones.
+func (r *ApplicationReconciler) observeJobWorkload(ctx context.Context, app *platformv1alpha1.Application) error {
+	status, reason, message := metav1.ConditionFalse, "", ""
+	app.Status.Health = platformv1alpha1.HealthProgressing
+
+	if applicationType(app) == platformv1alpha1.ApplicationTypeCron {
+		var cron batchv1.CronJob
+		if err := r.Get(ctx, client.ObjectKeyFromObject(app), &cron); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			reason, message = "CronJobMissing", "CronJob has not been created yet"
+		} else {
+			status, reason, message = metav1.ConditionTrue, "Scheduled", "Runs the latest image on schedule "+cron.Spec.Schedule
+			app.Status.Health = platformv1alpha1.HealthHealthy
+		}
+	} else {
+		job := app.Status.Job
+		switch {
+		case job == nil || job.Image != deployedImage(app):
+			reason, message = "JobPending", "Job has not been created yet"
+		case job.Phase == platformv1alpha1.JobPhaseSucceeded:
+			status, reason, message = metav1.ConditionTrue, "JobSucceeded", "Job "+job.Name+" ran the latest image"
+			app.Status.Health = platformv1alpha1.HealthHealthy
+		case job.Phase == platformv1alpha1.JobPhaseFailed:
+			reason, message = "JobFailed", "Job "+job.Name+" failed"
+			app.Status.Health = platformv1alpha1.HealthError
+		default:
+			reason, message = "JobRunning", "Job "+job.Name+" is running"
+		}
+	}
+
+	apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.Ready,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.GetGeneration(),
+	})
+	return nil
+}
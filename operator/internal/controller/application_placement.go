@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/utils"
+)
+
+// placementRequeue is how often an Application with spec.placement is looked at again.
+// The objects in remote clusters are not watched, so changes to them are only noticed
+// on these requeues.
+const placementRequeue = time.Minute
+
+// clusterTypeAttached is the Cluster type of the control plane cluster itself.
+const clusterTypeAttached = "attached"
+
+// setOwner makes the Application the controller of an object rendered for it. Objects
+// in remote clusters cannot reference it; they are deleted through PlacementFinalizer.
+func (r *ApplicationReconciler) setOwner(app *platformv1alpha1.Application, obj client.Object) error {
+	if r.remote {
+		return nil
+	}
+	return ctrl.SetControllerReference(app, obj, r.Scheme)
+}
+
+// controlPlaneClient reads the objects that only exist on the control plane, such as
+// the other Applications, whatever cluster the workload is rendered in.
+func (r *ApplicationReconciler) controlPlaneClient() client.Client {
+	if r.controlPlane != nil {
+		return r.controlPlane
+	}
+	return r.Client
+}
+
+// forCluster returns a copy of the reconciler rendering the workload in the cluster.
+// Attached clusters are the control plane itself.
+func (r *ApplicationReconciler) forCluster(ctx context.Context, clu *platformv1alpha1.Cluster) (*ApplicationReconciler, error) {
+	target := *r
+	target.cluster = clu.Name
+	if clu.Spec.Type == clusterTypeAttached {
+		return &target, nil
+	}
+	if r.TargetFactory == nil {
+		return nil, fmt.Errorf("no client factory configured for remote cluster %s", clu.Name)
+	}
+	c, err := r.TargetFactory.ClientFor(ctx, clu)
+	if err != nil {
+		return nil, err
+	}
+	target.Client = c
+	target.controlPlane = r.Client
+	target.remote = true
+	return &target, nil
+}
+
+// placementClusters are the clusters selected by spec.placement among the ones the
+// Application's project is bound to, sorted by name.
+func (r *ApplicationReconciler) placementClusters(ctx context.Context, app *platformv1alpha1.Application) ([]platformv1alpha1.Cluster, error) {
+	placement := app.Spec.Placement
+	selector := labels.Everything()
+	if placement.ClusterSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(placement.ClusterSelector); err != nil {
+			return nil, err
+		}
+	}
+
+	var bindings platformv1alpha1.ProjectClusterBindingList
+	if err := r.List(ctx, &bindings); err != nil {
+		return nil, err
+	}
+	var clusters []platformv1alpha1.Cluster
+	seen := map[string]bool{}
+	for _, binding := range bindings.Items {
+		name := binding.Spec.ClusterRef
+		if binding.Spec.ProjectRef != app.Spec.ProjectRef || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if len(placement.Clusters) > 0 && !slices.Contains(placement.Clusters, name) {
+			continue
+		}
+		var clu platformv1alpha1.Cluster
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, &clu); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if clu.DeletionTimestamp != nil {
+			continue
+		}
+		if len(placement.Regions) > 0 && !slices.Contains(placement.Regions, clu.Spec.Region) {
+			continue
+		}
+		if !selector.Matches(labels.Set(clu.Labels)) {
+			continue
+		}
+		clusters = append(clusters, clu)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters, nil
+}
+
+// reconcilePlacement renders the workload of an Application with spec.placement in each
+// selected cluster and reports its health there in Status.Clusters. A cluster that
+// cannot be reached, or whose project quota cannot admit the Application, is reported
+// as such without holding back the others. Clusters no longer selected are cleaned up.
+//
+// The Application is Ready once it is ready in every selected cluster.
+func (r *ApplicationReconciler) reconcilePlacement(ctx context.Context, app *platformv1alpha1.Application, previewHost string) error {
+	logger := logf.FromContext(ctx)
+
+	clusters, err := r.placementClusters(ctx, app)
+	if err != nil {
+		return err
+	}
+	if len(app.Status.Clusters) == 0 && !slices.ContainsFunc(clusters, isAttached) {
+		// the workload rendered next to the CR before the Application was placed
+		if err := r.removeWorkloadObjects(ctx, app); err != nil {
+			return err
+		}
+	}
+
+	statuses := make([]platformv1alpha1.PlacementStatus, 0, len(clusters))
+	var unreachable []string
+	for i := range clusters {
+		clu := &clusters[i]
+		status := platformv1alpha1.PlacementStatus{Cluster: clu.Name, Region: clu.Spec.Region}
+		if down, msg := clusterUnreachable(clu); down {
+			// not worth waiting on the connection timeouts of a cluster known to be gone
+			status.Health, status.Reason, status.Message = platformv1alpha1.HealthError, "ClusterUnreachable", msg
+			statuses = append(statuses, status)
+			unreachable = append(unreachable, clu.Name)
+			continue
+		}
+		err := r.reconcileInCluster(ctx, app, clu, previewHost)
+		switch {
+		case isQuotaExceeded(err):
+			status.Health, status.Reason, status.Message = platformv1alpha1.HealthError, "QuotaExceeded", err.Error()
+		case err != nil:
+			logger.Error(err, "Failed to reconcile Application in cluster", "cluster", clu.Name)
+			status.Health, status.Reason, status.Message = platformv1alpha1.HealthError, "ClusterError", err.Error()
+		default:
+			status.Health = app.Status.Health
+			if ready := apimeta.FindStatusCondition(app.Status.Conditions, platformv1alpha1.Ready); ready != nil {
+				status.Reason, status.Message = ready.Reason, ready.Message
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, previous := range app.Status.Clusters {
+		if slices.ContainsFunc(statuses, func(s platformv1alpha1.PlacementStatus) bool { return s.Cluster == previous.Cluster }) {
+			continue
+		}
+		if err := r.removeFromCluster(ctx, app, previous.Cluster, false); err != nil {
+			return err
+		}
+		logger.Info("Removed Application from cluster no longer selected", "cluster", previous.Cluster)
+	}
+	app.Status.Clusters = statuses
+
+	if len(clusters) == 0 {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.PlacementFailed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "NoMatchingClusters",
+			Message:            "No cluster bound to project " + app.Spec.ProjectRef + " matches spec.placement",
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.PlacementFailed)
+	}
+	if len(unreachable) > 0 {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.ClusterUnreachable,
+			Status:             metav1.ConditionTrue,
+			Reason:             "HeartbeatMissed",
+			Message:            "Clusters missed their heartbeat: " + strings.Join(unreachable, ", "),
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.ClusterUnreachable)
+	}
+	observePlacement(app)
+	return nil
+}
+
+// reconcileInCluster renders the workload and routes of the Application in one cluster
+// and observes them there.
+func (r *ApplicationReconciler) reconcileInCluster(ctx context.Context, app *platformv1alpha1.Application, clu *platformv1alpha1.Cluster, previewHost string) error {
+	target, err := r.forCluster(ctx, clu)
+	if err != nil {
+		return err
+	}
+	if err := target.checkQuota(ctx, app); err != nil {
+		return err
+	}
+	if err := target.reconcileWorkload(ctx, app); err != nil {
+		return err
+	}
+	if err := target.reconcileRoutes(ctx, app, previewHost); err != nil {
+		return err
+	}
+	return target.observeWorkload(ctx, app)
+}
+
+// observePlacement sets the Ready condition and Health of the Application from its
+// state in each cluster: Error when it fails in one, Healthy once healthy in all.
+func observePlacement(app *platformv1alpha1.Application) {
+	ready := metav1.Condition{
+		Type:               platformv1alpha1.Ready,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Available",
+		Message:            fmt.Sprintf("Application is running the latest image in %d clusters", len(app.Status.Clusters)),
+		ObservedGeneration: app.GetGeneration(),
+	}
+	app.Status.Health = platformv1alpha1.HealthHealthy
+	if len(app.Status.Clusters) == 0 {
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "NoMatchingClusters", "No cluster matches spec.placement"
+		app.Status.Health = platformv1alpha1.HealthError
+	}
+	for _, status := range app.Status.Clusters {
+		if status.Health == platformv1alpha1.HealthHealthy {
+			continue
+		}
+		if app.Status.Health != platformv1alpha1.HealthError {
+			app.Status.Health = status.Health
+		}
+		ready.Status, ready.Reason = metav1.ConditionFalse, "ClusterNotReady"
+		ready.Message = "Not ready in cluster " + status.Cluster + ": " + status.Message
+	}
+	if deployedImage(app) == "" {
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "AwaitingBuild", "No image has been built yet"
+		app.Status.Health = platformv1alpha1.HealthProgressing
+	}
+	apimeta.SetStatusCondition(&app.Status.Conditions, ready)
+}
+
+// reconcilePlacementFinalizer keeps PlacementFinalizer on the Application while it has
+// spec.placement and the direct backend renders its workload. Once the placement is
+// removed, or Argo CD takes over, the workload is deleted from the clusters it was
+// placed in and the finalizer released. It must run before the status is touched, as updates reload the Application.
+func (r *ApplicationReconciler) reconcilePlacementFinalizer(ctx context.Context, app *platformv1alpha1.Application) error {
+	placed := utils.ContainsString(app.Finalizers, platformv1alpha1.PlacementFinalizer)
+	rendered := app.Spec.Placement != nil && !usesArgoCD(app)
+	switch {
+	case rendered && !placed:
+		app.Finalizers = append(app.Finalizers, platformv1alpha1.PlacementFinalizer)
+		return r.Update(ctx, app)
+	case !rendered && placed:
+		if err := r.finalizePlacement(ctx, app); err != nil {
+			return err
+		}
+		app.Status.Clusters = nil
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.ClusterUnreachable)
+	}
+	return nil
+}
+
+// finalizePlacement deletes the workload of the Application from the clusters it was
+// placed in, then releases it. Objects in attached clusters are owned by the
+// Application and left to the garbage collector, or to be rendered again.
+func (r *ApplicationReconciler) finalizePlacement(ctx context.Context, app *platformv1alpha1.Application) error {
+	for _, status := range app.Status.Clusters {
+		if err := r.removeFromCluster(ctx, app, status.Cluster, true); err != nil {
+			return err
+		}
+	}
+	app.Finalizers = utils.RemoveString(app.Finalizers, platformv1alpha1.PlacementFinalizer)
+	return r.Update(ctx, app)
+}
+
+// removeFromCluster deletes the workload of the Application from a cluster it is no
+// longer placed in. A cluster whose CR is gone cannot be reached and is skipped.
+func (r *ApplicationReconciler) removeFromCluster(ctx context.Context, app *platformv1alpha1.Application, name string, skipAttached bool) error {
+	var clu platformv1alpha1.Cluster
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, &clu); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if skipAttached && isAttached(clu) {
+		return nil
+	}
+	target, err := r.forCluster(ctx, &clu)
+	if err != nil {
+		return err
+	}
+	return target.removeWorkloadObjects(ctx, app)
+}
+
+// removeWorkloadObjects deletes every object rendered for the Application in the
+// cluster of the reconciler.
+func (r *ApplicationReconciler) removeWorkloadObjects(ctx context.Context, app *platformv1alpha1.Application) error {
+	if err := r.removeDeploymentWorkload(ctx, app); err != nil {
+		return err
+	}
+	if err := r.removeCronJob(ctx, app); err != nil {
+		return err
+	}
+	objs := []client.Object{
+		&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: managedSecretName(app), Namespace: app.Namespace}},
+	}
+	for _, obj := range objs {
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return client.IgnoreNotFound(r.DeleteAllOf(ctx, &batchv1.Job{},
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels(applicationSelectorLabels(app)),
+		client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+func isAttached(clu platformv1alpha1.Cluster) bool {
+	return clu.Spec.Type == clusterTypeAttached
+}
+
+// placementRequeueAfter is placementRequeue for Applications with spec.placement whose
+// workload is rendered by the operator.
+func placementRequeueAfter(app *platformv1alpha1.Application) time.Duration {
+	if app.Spec.Placement == nil || usesArgoCD(app) {
+		return 0
+	}
+	return placementRequeue
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// defaultPreviewTTL is PreviewConfig.TTL when unset.
+const defaultPreviewTTL = 72 * time.Hour
+
+// reconcilePreview keeps a preview environment tied to its parent: it is adopted by the
+// parent so it is garbage collected with it, and deleted once its TTL has passed or the
+// parent is gone. It returns the hostname the preview is exposed on, and whether the
+// preview was deleted. Applications that are not previews are left alone.
+func (r *ApplicationReconciler) reconcilePreview(ctx context.Context, app *platformv1alpha1.Application) (string, bool, error) {
+	preview := app.Spec.Preview
+	if preview == nil {
+		app.Status.ExpiresAt = nil
+		return "", false, nil
+	}
+	logger := logf.FromContext(ctx)
+
+	parent := &platformv1alpha1.Application{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: preview.Parent}, parent); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", false, err
+		}
+		logger.Info("Parent of preview Application is gone, deleting preview", "parent", preview.Parent)
+		return "", true, client.IgnoreNotFound(r.Delete(ctx, app))
+	}
+
+	if !metav1.IsControlledBy(app, parent) {
+		if err := ctrl.SetControllerReference(parent, app, r.Scheme); err != nil {
+			return "", false, err
+		}
+		if err := r.Update(ctx, app); err != nil {
+			return "", false, err
+		}
+	}
+
+	ttl := defaultPreviewTTL
+	if parent.Spec.Previews != nil && parent.Spec.Previews.TTL != nil {
+		ttl = parent.Spec.Previews.TTL.Duration
+	}
+	if preview.TTL != nil {
+		ttl = preview.TTL.Duration
+	}
+	expiresAt := metav1.NewTime(app.CreationTimestamp.Add(ttl))
+	app.Status.ExpiresAt = &expiresAt
+	if !time.Now().Before(expiresAt.Time) {
+		logger.Info("Preview Application expired, deleting it", "expiresAt", expiresAt)
+		return "", true, client.IgnoreNotFound(r.Delete(ctx, app))
+	}
+
+	host := preview.Host
+	if host == "" {
+		domain := r.PreviewDomain
+		if parent.Spec.Previews != nil && parent.Spec.Previews.Domain != "" {
+			domain = parent.Spec.Previews.Domain
+		}
+		if domain != "" {
+			host = app.Name + "." + domain
+		}
+	}
+	return host, false, nil
+}
+
+// previewRequeueAfter is when a preview must be reconciled again to be deleted on time.
+// Zero for Applications that are not previews.
+func previewRequeueAfter(app *platformv1alpha1.Application) time.Duration {
+	if app.Status.ExpiresAt == nil {
+		return 0
+	}
+	return max(time.Until(app.Status.ExpiresAt.Time), time.Second)
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// errQuotaExceeded rejects an Application whose minimum replicas do not fit in the
+// remaining ResourceQuota of its project; their pods would be refused by the apiserver.
+var errQuotaExceeded = errors.New("minimum replicas exceed the project quota")
+
+// appPort is the container port the application listens on.
+func appPort(app *platformv1alpha1.Application) int32 {
+	if app.Spec.Port > 0 {
+		return app.Spec.Port
+	}
+	return defaultAppPort
+}
+
+// renderResources maps spec.resources onto the container requirements.
+func renderResources(app *platformv1alpha1.Application) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: computeResourceList(app.Spec.Resources.Requests),
+		Limits:   computeResourceList(app.Spec.Resources.Limits),
+	}
+}
+
+func computeResourceList(amounts platformv1alpha1.ComputeResources) corev1.ResourceList {
+	var list corev1.ResourceList
+	set := func(name corev1.ResourceName, q *resource.Quantity) {
+		if q == nil {
+			return
+		}
+		if list == nil {
+			list = corev1.ResourceList{}
+		}
+		list[name] = q.DeepCopy()
+	}
+	set(corev1.ResourceCPU, amounts.CPU)
+	set(corev1.ResourceMemory, amounts.Memory)
+	return list
+}
+
+// checkQuota rejects the Application when its minimum replicas need more than is left in
+// any ResourceQuota of its namespace. The pods already running for the Application are
+// counted as available, since they are replaced rather than added to.
+func (r *ApplicationReconciler) checkQuota(ctx context.Context, app *platformv1alpha1.Application) error {
+	var quotas corev1.ResourceQuotaList
+	if err := r.List(ctx, &quotas, client.InNamespace(app.Namespace)); err != nil {
+		return err
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	perPod, err := r.podResources(ctx, app)
+	if err != nil {
+		return err
+	}
+	needed := quotaUsage(perPod, int64(minReplicas(app)))
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(app.Namespace),
+		client.MatchingLabels(applicationSelectorLabels(app))); err != nil {
+		return err
+	}
+	own := corev1.ResourceList{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			addResources(own, quotaUsage(container.Resources, 1))
+		}
+	}
+
+	var exceeded []string
+	for _, quota := range quotas.Items {
+		names := make([]string, 0, len(quota.Spec.Hard))
+		for name := range quota.Spec.Hard {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			name := corev1.ResourceName(n)
+			need, ok := needed[name]
+			if !ok || need.IsZero() {
+				continue
+			}
+			remaining := quota.Spec.Hard[name].DeepCopy()
+			if used, ok := quota.Status.Used[name]; ok {
+				remaining.Sub(used)
+			}
+			if mine, ok := own[name]; ok {
+				remaining.Add(mine)
+			}
+			if need.Cmp(remaining) > 0 {
+				exceeded = append(exceeded, fmt.Sprintf("%s: %s needed, %s left in ResourceQuota %s",
+					n, need.String(), remaining.String(), quota.Name))
+			}
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("%w: %s", errQuotaExceeded, strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// podResources are the requirements of the application container as admitted: unset
+// values are filled from the Container defaults of the namespace's LimitRanges, and
+// requests default to limits like the apiserver does.
+func (r *ApplicationReconciler) podResources(ctx context.Context, app *platformv1alpha1.Application) (corev1.ResourceRequirements, error) {
+	res := renderResources(app)
+	var limitRanges corev1.LimitRangeList
+	if err := r.List(ctx, &limitRanges, client.InNamespace(app.Namespace)); err != nil {
+		return res, err
+	}
+	fill := func(list *corev1.ResourceList, defaults corev1.ResourceList) {
+		for name, q := range defaults {
+			if *list == nil {
+				*list = corev1.ResourceList{}
+			}
+			if _, ok := (*list)[name]; !ok {
+				(*list)[name] = q.DeepCopy()
+			}
+		}
+	}
+	for _, lr := range limitRanges.Items {
+		for _, item := range lr.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			fill(&res.Limits, item.Default)
+			fill(&res.Requests, item.DefaultRequest)
+		}
+	}
+	fill(&res.Requests, res.Limits)
+	return res, nil
+}
+
+// quotaUsage is what the given number of containers with these requirements count
+// against a ResourceQuota, requests under both their plain and their requests. name.
+func quotaUsage(res corev1.ResourceRequirements, replicas int64) corev1.ResourceList {
+	usage := corev1.ResourceList{}
+	scale := func(q resource.Quantity) resource.Quantity {
+		total := resource.NewMilliQuantity(q.MilliValue()*replicas, q.Format)
+		return *total
+	}
+	for name, q := range res.Requests {
+		usage[name] = scale(q)
+		usage[corev1.ResourceName("requests."+string(name))] = scale(q)
+	}
+	for name, q := range res.Limits {
+		usage[corev1.ResourceName("limits."+string(name))] = scale(q)
+	}
+	return usage
+}
+
+func addResources(total, add corev1.ResourceList) {
+	for name, q := range add {
+		sum := total[name]
+		sum.Add(q)
+		total[name] = sum
+	}
+}
+
+// isQuotaExceeded reports whether err rejected the Application for the project quota.
+func isQuotaExceeded(err error) bool {
+	return errors.Is(err, errQuotaExceeded)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// DefaultBuildRetention is used when neither the operator flags nor the Application set
+// a history limit.
+var DefaultBuildRetention = platformv1alpha1.BuildRetention{
+	SucceededRunsHistoryLimit: int32Ptr(5),
+	FailedRunsHistoryLimit:    int32Ptr(3),
+}
+
+func int32Ptr(v int32) *int32 { return &v }
+
+// buildRetention computes the effective history limits of an Application by layering
+// DefaultBuildRetention, the operator-level r.BuildRetention and spec.build.retention.
+func (r *ApplicationReconciler) buildRetention(app *platformv1alpha1.Application) (succeeded, failed int) {
+	retention := DefaultBuildRetention.Merge(&r.BuildRetention).Merge(app.Spec.Build.Retention)
+	return int(*retention.SucceededRunsHistoryLimit), int(*retention.FailedRunsHistoryLimit)
+}
+
+// pruneBuilds deletes the finished PipelineRuns of the Application beyond its history
+// limits, together with the PVCs Tekton created for their volume claim templates. runs
+// must be sorted newest first. Running builds and the latest run are never pruned: the
+// latest run carries the fingerprint new builds are compared against.
+func (r *ApplicationReconciler) pruneBuilds(ctx context.Context, app *platformv1alpha1.Application, runs []tektonv1.PipelineRun) error {
+	logger := logf.FromContext(ctx)
+	succeededLimit, failedLimit := r.buildRetention(app)
+
+	succeeded, failed := 0, 0
+	for i := range runs {
+		run := &runs[i]
+		if !run.IsDone() {
+			continue
+		}
+
+		var keep bool
+		if buildOutcome(run) == platformv1alpha1.BuildOutcomeSucceeded {
+			succeeded++
+			keep = succeeded <= succeededLimit
+		} else {
+			failed++
+			keep = failed <= failedLimit
+		}
+		if keep || i == 0 {
+			continue
+		}
+
+		logger.Info("Pruning PipelineRun beyond the retention limits", "PipelineRun.Name", run.Name)
+		if err := r.deleteRunClaims(ctx, run); err != nil {
+			return err
+		}
+		if err := r.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRunClaims deletes the PVCs owned by a PipelineRun. Tekton makes the run the owner
+// of the claims it creates from volume claim templates, so garbage collection would get
+// to them too, but deleting them here frees the quota right away.
+func (r *ApplicationReconciler) deleteRunClaims(ctx context.Context, run *tektonv1.PipelineRun) error {
+	var claims corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &claims, client.InNamespace(run.Namespace)); err != nil {
+		return err
+	}
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if !ownedByUID(claim, run) {
+			continue
+		}
+		if err := r.Delete(ctx, claim); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedByUID reports whether obj has an owner reference to owner.
+func ownedByUID(obj, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// maxDeploymentHistory bounds ApplicationStatus.Deployments.
+const maxDeploymentHistory = 10
+
+// deployedImage returns the image the workload runs: the deployment the Application is
+// pinned to by a rollback, otherwise the latest built image.
+func deployedImage(app *platformv1alpha1.Application) string {
+	if app.Status.Pinned != nil {
+		return app.Status.Pinned.Image
+	}
+	return app.Status.Image
+}
+
+// observeRollback pins the Application to the deployment named by RollbackToAnnotation,
+// or unpins it once the annotation is removed. A target that is not in the history sets
+// RollbackFailed and leaves what is deployed untouched. The argocd backend deploys what
+// the GitOps repository holds, so it is never pinned; it is rolled back by reverting
+// the repository instead.
+func observeRollback(app *platformv1alpha1.Application) {
+	target := app.Annotations[platformv1alpha1.RollbackToAnnotation]
+	if target == "" || usesArgoCD(app) {
+		app.Status.Pinned = nil
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.Pinned)
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RollbackFailed)
+	}
+	if target == "" {
+		return
+	}
+	if usesArgoCD(app) {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.RollbackFailed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Unsupported",
+			Message:            "Applications deployed by Argo CD are rolled back by reverting the GitOps repository",
+			ObservedGeneration: app.GetGeneration(),
+		})
+		return
+	}
+
+	// once pinned, the history entry may age out, so the pin is kept as long as the
+	// target does not change
+	if app.Status.Pinned != nil && app.Status.Pinned.MatchesRollbackTarget(target) {
+		return
+	}
+
+	record, ok := app.Status.FindRollbackTarget(target)
+	if !ok {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.RollbackFailed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "TargetNotFound",
+			Message:            "No earlier deployment or successful build matches " + target,
+			ObservedGeneration: app.GetGeneration(),
+		})
+		return
+	}
+
+	record.Rollback = true
+	record.DeployedAt = metav1.Now()
+	app.Status.Pinned = &record
+	apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RollbackFailed)
+	apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.Pinned,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RolledBack",
+		Message:            "Rolled back to " + record.Image + ", new builds are not deployed until the rollback is cleared",
+		ObservedGeneration: app.GetGeneration(),
+	})
+}
+
+// recordDeployment prepends the image the workload was just rolled out with to the
+// deployment history, unless it is already the newest entry.
+func recordDeployment(app *platformv1alpha1.Application) {
+	image := deployedImage(app)
+	if image == "" || (len(app.Status.Deployments) > 0 && app.Status.Deployments[0].Image == image) {
+		return
+	}
+
+	var record platformv1alpha1.DeploymentRecord
+	if app.Status.Pinned != nil {
+		record = *app.Status.Pinned
+	} else {
+		record = platformv1alpha1.DeploymentRecord{Image: image, Revision: app.Status.Revision}
+		for _, build := range app.Status.Builds {
+			if build.Image == image {
+				record.PipelineRun = build.PipelineRun
+				break
+			}
+		}
+	}
+	record.DeployedAt = metav1.Now()
+
+	app.Status.Deployments = append([]platformv1alpha1.DeploymentRecord{record}, app.Status.Deployments...)
+	if len(app.Status.Deployments) > maxDeploymentHistory {
+		app.Status.Deployments = app.Status.Deployments[:maxDeploymentHistory]
+	}
+}
@@ -0,0 +1,449 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// Values of RolloutTrackLabel.
+const (
+	trackStable  = "stable"
+	trackCanary  = "canary"
+	trackPreview = "preview"
+)
+
+const (
+	// defaultMaxRestarts is RolloutStrategy.MaxRestarts when unset.
+	defaultMaxRestarts int32 = 3
+	// defaultReadinessTimeout is RolloutStrategy.ReadinessTimeout when unset.
+	defaultReadinessTimeout = 5 * time.Minute
+	// rolloutAnalysisInterval is how often a rollout in flight is re-checked. Pods are
+	// not watched, so restarts of ready pods are only noticed on these requeues.
+	rolloutAnalysisInterval = 15 * time.Second
+)
+
+// rolloutTrack is the track of the second Deployment used by the Application's rollout strategy.
+func rolloutTrack(rollout *platformv1alpha1.RolloutStrategy) string {
+	if rollout.Canary != nil {
+		return trackCanary
+	}
+	return trackPreview
+}
+
+// rolloutStrategyName is the strategy reported in RolloutStatus.Strategy.
+func rolloutStrategyName(rollout *platformv1alpha1.RolloutStrategy) string {
+	if rollout.Canary != nil {
+		return platformv1alpha1.RolloutStrategyCanary
+	}
+	return platformv1alpha1.RolloutStrategyBlueGreen
+}
+
+// rolloutInFlight reports whether a progressive rollout has started and not yet ended.
+func rolloutInFlight(status *platformv1alpha1.RolloutStatus) bool {
+	if status == nil {
+		return false
+	}
+	switch status.Phase {
+	case platformv1alpha1.RolloutPhaseProgressing, platformv1alpha1.RolloutPhasePaused, platformv1alpha1.RolloutPhasePromoted:
+		return true
+	}
+	return false
+}
+
+// serviceSelector selects the pods the Application's Service sends traffic to. Canary
+// pods share the Service with the stable ones, so traffic is split by replica count.
+// A blue-green preview is kept out of it until promoted, and then receives all the
+// traffic until the stable Deployment runs the new image.
+func serviceSelector(app *platformv1alpha1.Application) map[string]string {
+	selector := applicationSelectorLabels(app)
+	status := app.Status.Rollout
+	if status == nil || status.Strategy != platformv1alpha1.RolloutStrategyBlueGreen {
+		return selector
+	}
+	switch status.Phase {
+	case platformv1alpha1.RolloutPhaseProgressing, platformv1alpha1.RolloutPhasePaused:
+		selector[platformv1alpha1.RolloutTrackLabel] = trackStable
+	case platformv1alpha1.RolloutPhasePromoted:
+		selector[platformv1alpha1.RolloutTrackLabel] = trackPreview
+	}
+	return selector
+}
+
+// rolloutRequeueAfter is when a rollout in flight must be looked at again, at the latest
+// when the current pause elapses. Zero when no rollout is in flight.
+func rolloutRequeueAfter(app *platformv1alpha1.Application) time.Duration {
+	status := app.Status.Rollout
+	if !rolloutInFlight(status) {
+		return 0
+	}
+	after := rolloutAnalysisInterval
+	if status.Phase == platformv1alpha1.RolloutPhasePaused && status.StepStartedAt != nil {
+		if pause := canaryPause(app, status.Step); pause > 0 {
+			remaining := time.Until(status.StepStartedAt.Add(pause))
+			if remaining > 0 && remaining < after {
+				after = remaining
+			}
+		}
+	}
+	return after
+}
+
+// canaryPause is the pause of a canary step, zero when it waits for a promotion.
+func canaryPause(app *platformv1alpha1.Application, step int32) time.Duration {
+	rollout := app.Spec.Rollout
+	if rollout == nil || rollout.Canary == nil || int(step) >= len(rollout.Canary.Steps) {
+		return 0
+	}
+	if pause := rollout.Canary.Steps[step].Pause; pause != nil {
+		return pause.Duration
+	}
+	return 0
+}
+
+// reconcileRollout drives the progressive rollout of the Application's image and returns
+// the image the stable Deployment must run: the previous one while the new image is being
+// tried out next to it, the new one once promoted.
+//
+// The first deployment, rollbacks and Applications without spec.rollout are rolled out
+// at once. An aborted rollout keeps the previous image until a new one is deployed.
+func (r *ApplicationReconciler) reconcileRollout(ctx context.Context, app *platformv1alpha1.Application) (string, error) {
+	target := deployedImage(app)
+	rollout := app.Spec.Rollout
+
+	var stable appsv1.Deployment
+	current := ""
+	if err := r.Get(ctx, client.ObjectKeyFromObject(app), &stable); err == nil {
+		current = appContainerImage(&stable.Spec.Template.Spec)
+	} else if !errors.IsNotFound(err) {
+		return "", err
+	}
+
+	if rollout == nil || current == "" || app.Status.Pinned != nil {
+		app.Status.Rollout = nil
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RollingOut)
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RolloutAborted)
+		return target, r.removeRolloutCandidate(ctx, app)
+	}
+
+	status := app.Status.Rollout
+	if current == target {
+		if status != nil && status.Image == target && status.Phase == platformv1alpha1.RolloutPhasePromoted {
+			// keep the new pods serving until the stable Deployment has caught up
+			if ready, _, _ := deploymentReady(&stable); !ready {
+				return target, nil
+			}
+			status.Phase = platformv1alpha1.RolloutPhaseSucceeded
+			status.Message = "Rolled out " + target
+			setRollingOut(app)
+		}
+		if status != nil && status.Image != target {
+			// an aborted image was replaced by the one already running
+			apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RolloutAborted)
+		}
+		return target, r.removeRolloutCandidate(ctx, app)
+	}
+
+	strategy := rolloutStrategyName(rollout)
+	if status == nil || status.Image != target || status.Strategy != strategy || status.Phase == platformv1alpha1.RolloutPhaseSucceeded {
+		now := metav1.Now()
+		status = &platformv1alpha1.RolloutStatus{
+			Strategy:          strategy,
+			Phase:             platformv1alpha1.RolloutPhaseProgressing,
+			Image:             target,
+			StableImage:       current,
+			StepStartedAt:     &now,
+			ObservedPromotion: app.Annotations[platformv1alpha1.PromoteRequestedAtAnnotation],
+		}
+		if rollout.Canary != nil {
+			status.Weight = rollout.Canary.Steps[0].Weight
+		}
+		app.Status.Rollout = status
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RolloutAborted)
+		logf.FromContext(ctx).Info("Starting progressive rollout", "strategy", strategy, "image", target, "stableImage", current)
+	}
+
+	switch status.Phase {
+	case platformv1alpha1.RolloutPhaseAborted:
+		return current, r.removeRolloutCandidate(ctx, app)
+	case platformv1alpha1.RolloutPhasePromoted:
+		return target, nil
+	}
+
+	track := rolloutTrack(rollout)
+	candidate := &appsv1.Deployment{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: app.Name + "-" + track}, candidate)
+	if errors.IsNotFound(err) {
+		candidate = nil
+	} else if err != nil {
+		return "", err
+	}
+
+	ready := false
+	if candidate != nil {
+		reason, err := r.analyzeRollout(ctx, app, candidate, track)
+		if err != nil {
+			return "", err
+		}
+		if reason != "" {
+			abortRollout(ctx, app, reason)
+			return current, r.removeRolloutCandidate(ctx, app)
+		}
+		ready, _, _ = deploymentReady(candidate)
+	}
+	promoted := advanceRollout(app, ready)
+	setRollingOut(app)
+	if promoted {
+		return target, nil
+	}
+
+	stableReplicas := int32(1)
+	if stable.Spec.Replicas != nil {
+		stableReplicas = *stable.Spec.Replicas
+	}
+	if err := r.reconcileRolloutCandidate(ctx, app, track, candidateReplicas(app, stableReplicas)); err != nil {
+		return "", err
+	}
+	if track == trackPreview && applicationType(app) == platformv1alpha1.ApplicationTypeWeb {
+		if err := r.reconcilePreviewService(ctx, app); err != nil {
+			return "", err
+		}
+	}
+	return current, nil
+}
+
+// advanceRollout moves the rollout on once the new pods of the current step are ready:
+// past an elapsed pause, or on a promotion request. It reports whether the new image has
+// been promoted to the stable Deployment.
+func advanceRollout(app *platformv1alpha1.Application, ready bool) bool {
+	status := app.Status.Rollout
+	promote := app.Annotations[platformv1alpha1.PromoteRequestedAtAnnotation]
+	promoteRequested := promote != "" && promote != status.ObservedPromotion
+
+	if !ready {
+		status.Phase = platformv1alpha1.RolloutPhaseProgressing
+		status.Message = "Waiting for the new pods to become ready"
+		return false
+	}
+
+	advance := promoteRequested
+	if promoteRequested {
+		status.ObservedPromotion = promote
+	}
+
+	canary := app.Spec.Rollout.Canary
+	if canary == nil {
+		if !advance {
+			status.Phase = platformv1alpha1.RolloutPhasePaused
+			status.Message = "Preview is ready, awaiting promotion"
+			return false
+		}
+		status.Phase = platformv1alpha1.RolloutPhasePromoted
+		status.Message = "Switching traffic to " + status.Image
+		return true
+	}
+
+	if pause := canaryPause(app, status.Step); pause > 0 && status.StepStartedAt != nil && time.Since(status.StepStartedAt.Time) >= pause {
+		advance = true
+	}
+	if !advance {
+		status.Phase = platformv1alpha1.RolloutPhasePaused
+		if pause := canaryPause(app, status.Step); pause > 0 {
+			status.Message = fmt.Sprintf("Paused at step %d (%d%%) for %s", status.Step+1, status.Weight, pause)
+		} else {
+			status.Message = fmt.Sprintf("Paused at step %d (%d%%), awaiting promotion", status.Step+1, status.Weight)
+		}
+		return false
+	}
+
+	now := metav1.Now()
+	status.Step++
+	status.StepStartedAt = &now
+	if int(status.Step) >= len(canary.Steps) {
+		status.Phase = platformv1alpha1.RolloutPhasePromoted
+		status.Weight = 100
+		status.Message = "Rolling " + status.Image + " out to the stable Deployment"
+		return true
+	}
+	status.Phase = platformv1alpha1.RolloutPhaseProgressing
+	status.Weight = canary.Steps[status.Step].Weight
+	status.Message = "Waiting for the new pods to become ready"
+	return false
+}
+
+// analyzeRollout returns why the new pods are considered degraded: a container that
+// restarted more often than allowed, or pods not ready within the readiness timeout of
+// the current step. Empty when they are healthy.
+func (r *ApplicationReconciler) analyzeRollout(ctx context.Context, app *platformv1alpha1.Application, candidate *appsv1.Deployment, track string) (string, error) {
+	rollout := app.Spec.Rollout
+	maxRestarts := defaultMaxRestarts
+	if rollout.MaxRestarts != nil {
+		maxRestarts = *rollout.MaxRestarts
+	}
+	timeout := defaultReadinessTimeout
+	if rollout.ReadinessTimeout != nil {
+		timeout = rollout.ReadinessTimeout.Duration
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods,
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels{platformv1alpha1.ApplicationLabel: app.Name, platformv1alpha1.RolloutTrackLabel: track},
+	); err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.RestartCount > maxRestarts {
+				return fmt.Sprintf("Container %s of pod %s restarted %d times", cs.Name, pod.Name, cs.RestartCount), nil
+			}
+		}
+	}
+
+	if ready, reason, message := deploymentReady(candidate); !ready {
+		if reason == "ProgressDeadlineExceeded" {
+			return "New pods did not progress: " + message, nil
+		}
+		started := app.Status.Rollout.StepStartedAt
+		if started != nil && time.Since(started.Time) > timeout {
+			return fmt.Sprintf("New pods were not ready within %s", timeout), nil
+		}
+	}
+	return "", nil
+}
+
+// abortRollout records why the rollout was aborted; the caller removes the new pods.
+func abortRollout(ctx context.Context, app *platformv1alpha1.Application, reason string) {
+	status := app.Status.Rollout
+	status.Phase = platformv1alpha1.RolloutPhaseAborted
+	status.Message = reason
+	logf.FromContext(ctx).Info("Aborting progressive rollout", "image", status.Image, "reason", reason)
+
+	setRollingOut(app)
+	apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.RolloutAborted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NewPodsDegraded",
+		Message:            "Rollout of " + status.Image + " aborted: " + reason,
+		ObservedGeneration: app.GetGeneration(),
+	})
+}
+
+// setRollingOut mirrors the rollout phase into the RollingOut condition.
+func setRollingOut(app *platformv1alpha1.Application) {
+	status := app.Status.Rollout
+	cond := metav1.Condition{
+		Type:               platformv1alpha1.RollingOut,
+		Status:             metav1.ConditionFalse,
+		Reason:             status.Phase,
+		Message:            status.Message,
+		ObservedGeneration: app.GetGeneration(),
+	}
+	if rolloutInFlight(status) {
+		cond.Status = metav1.ConditionTrue
+	}
+	apimeta.SetStatusCondition(&app.Status.Conditions, cond)
+}
+
+// candidateReplicas sizes the Deployment of the new image. A blue-green preview matches
+// the stable Deployment; a canary gets the share of all replicas given by its weight,
+// as the Service splits traffic evenly across the pods of both.
+func candidateReplicas(app *platformv1alpha1.Application, stableReplicas int32) int32 {
+	status := app.Status.Rollout
+	if app.Spec.Rollout.Canary == nil {
+		return max(stableReplicas, 1)
+	}
+	weight := status.Weight
+	replicas := (stableReplicas*weight + (100 - weight) - 1) / (100 - weight)
+	return max(replicas, 1)
+}
+
+// reconcileRolloutCandidate renders the Deployment running the new image next to the
+// stable one. Its pods carry the track label so they can be selected on their own.
+func (r *ApplicationReconciler) reconcileRolloutCandidate(ctx context.Context, app *platformv1alpha1.Application, track string, replicas int32) error {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-" + track, Namespace: app.Namespace},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, deploy, func() error {
+		labels := applicationLabels(app)
+		labels[platformv1alpha1.RolloutTrackLabel] = track
+		selector := applicationSelectorLabels(app)
+		selector[platformv1alpha1.RolloutTrackLabel] = track
+
+		deploy.Labels = labels
+		deploy.Spec.Replicas = &replicas
+		if deploy.Spec.Selector == nil {
+			deploy.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+		}
+		deploy.Spec.Template.Labels = labels
+		deploy.Spec.Template.Spec.ImagePullSecrets = imagePullSecrets(app)
+		r.renderAppContainer(app, &deploy.Spec.Template.Spec, app.Status.Rollout.Image)
+		r.renderSecretsVersion(app, &deploy.Spec.Template)
+
+		return r.setOwner(app, deploy)
+	})
+	return err
+}
+
+// reconcilePreviewService exposes the blue-green preview pods on their own, so the new
+// image can be checked before it is promoted.
+func (r *ApplicationReconciler) reconcilePreviewService(ctx context.Context, app *platformv1alpha1.Application) error {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-" + trackPreview, Namespace: app.Namespace},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		selector := applicationSelectorLabels(app)
+		selector[platformv1alpha1.RolloutTrackLabel] = trackPreview
+
+		svc.Labels = applicationLabels(app)
+		svc.Spec.Selector = selector
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       "http",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       80,
+			TargetPort: intstr.FromString("http"),
+		}}
+		return r.setOwner(app, svc)
+	})
+	return err
+}
+
+// removeRolloutCandidate deletes the canary or preview Deployment and the preview
+// Service once no rollout needs them.
+func (r *ApplicationReconciler) removeRolloutCandidate(ctx context.Context, app *platformv1alpha1.Application) error {
+	objs := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-" + trackCanary, Namespace: app.Namespace}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-" + trackPreview, Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-" + trackPreview, Namespace: app.Namespace}},
+	}
+	for _, obj := range objs {
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// appContainerImage returns the image of the application container of a pod spec.
+func appContainerImage(podSpec *corev1.PodSpec) string {
+	for _, container := range podSpec.Containers {
+		if container.Name == appContainerName {
+			return container.Image
+		}
+	}
+	return ""
+}
@@ -0,0 +1,354 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+const (
+	// defaultIngressClassName backs ApplicationReconciler.IngressClassName when empty,
+	// matching the ingress-nginx controller shipped with the chart.
+	defaultIngressClassName = "nginx"
+	// routeConflictRequeue is how often an Application with conflicting routes checks
+	// whether the other Application released them.
+	routeConflictRequeue = time.Minute
+	// maxHostLabelLength is the longest DNS label, the first label of default hostnames.
+	maxHostLabelLength = 63
+)
+
+// certificateGVK is the cert-manager Certificate, handled as unstructured so the operator
+// does not depend on cert-manager unless TLS is enabled.
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// route is a hostname and path of an Application, and the container port serving it
+// (zero for the application port).
+type route struct {
+	host string
+	path string
+	port int32
+}
+
+func (rt route) key() string {
+	return rt.host + rt.path
+}
+
+// defaultHost is the hostname every Application gets under the platform wildcard domain,
+// <app>-<namespace>.<domain>, so Applications of different projects never share one.
+// Previews get the hostname computed by reconcilePreview instead.
+func (r *ApplicationReconciler) defaultHost(app *platformv1alpha1.Application, previewHost string) string {
+	if app.Spec.Preview != nil {
+		return previewHost
+	}
+	if r.AppsDomain == "" {
+		return ""
+	}
+	return hostLabel(app.Name+"-"+app.Namespace) + "." + r.AppsDomain
+}
+
+// hostLabel shortens names too long for a DNS label, suffixing them with a hash of the name.
+func hostLabel(name string) string {
+	if len(name) <= maxHostLabelLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:6]
+	return strings.TrimRight(name[:maxHostLabelLength-len(hash)-1], "-") + "-" + hash
+}
+
+// specRoutes are the routes of spec.routes, with defaults applied.
+func specRoutes(app *platformv1alpha1.Application) []route {
+	var routes []route
+	for _, rt := range app.Spec.Routes {
+		path := rt.Path
+		if path == "" {
+			path = "/"
+		}
+		port := rt.Port
+		if port == appPort(app) {
+			port = 0
+		}
+		routes = append(routes, route{host: strings.ToLower(rt.Host), path: path, port: port})
+	}
+	return routes
+}
+
+// reconcileRoutes exposes the Application on its default hostname and its routes through
+// an Ingress, with a cert-manager Certificate when a ClusterIssuer is configured. Routes
+// already served by another Application are left out and reported through the
+// RouteConflict condition. Nothing is exposed until the Application has been deployed,
+// and only web Applications are exposed.
+func (r *ApplicationReconciler) reconcileRoutes(ctx context.Context, app *platformv1alpha1.Application, previewHost string) error {
+	var routes []route
+	if deployedImage(app) != "" && applicationType(app) == platformv1alpha1.ApplicationTypeWeb {
+		if host := r.defaultHost(app, previewHost); host != "" {
+			routes = append(routes, route{host: host, path: "/"})
+		}
+		routes = append(routes, specRoutes(app)...)
+	}
+
+	claims, err := r.routeClaims(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	var served []route
+	var statuses []platformv1alpha1.RouteStatus
+	var conflicts []string
+	seen := map[string]bool{}
+	for _, rt := range routes {
+		if seen[rt.key()] {
+			continue
+		}
+		seen[rt.key()] = true
+		status := platformv1alpha1.RouteStatus{Host: rt.host, Path: rt.path}
+		if owner, ok := claims.ownerOf(rt); ok {
+			status.ConflictsWith = owner
+			conflicts = append(conflicts, fmt.Sprintf("%s%s is served by %s", rt.host, rt.path, owner))
+		} else {
+			served = append(served, rt)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if len(conflicts) > 0 {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.RouteConflict,
+			Status:             metav1.ConditionTrue,
+			Reason:             "RouteClaimed",
+			Message:            strings.Join(conflicts, "; "),
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.RouteConflict)
+	}
+
+	tls := r.ClusterIssuer != "" && len(served) > 0
+	if err := r.reconcileCertificate(ctx, app, served); err != nil {
+		return err
+	}
+	if err := r.reconcileIngress(ctx, app, served, tls); err != nil {
+		return err
+	}
+
+	scheme := "http://"
+	if tls {
+		scheme = "https://"
+	}
+	app.Status.URL = ""
+	for i := range statuses {
+		if statuses[i].ConflictsWith != "" {
+			continue
+		}
+		statuses[i].URL = scheme + statuses[i].Host + strings.TrimSuffix(statuses[i].Path, "/")
+		if app.Status.URL == "" {
+			app.Status.URL = statuses[i].URL
+		}
+	}
+	app.Status.Routes = statuses
+	return nil
+}
+
+// routeClaims are the hostnames and paths claimed by the other Applications.
+type routeClaims struct {
+	// hosts are default hostnames, reserved for their Application on every path
+	hosts map[string]string
+	// routes are the routes of Applications created before the one reconciled
+	routes map[string]string
+}
+
+func (c routeClaims) ownerOf(rt route) (string, bool) {
+	if owner, ok := c.hosts[rt.host]; ok {
+		return owner, true
+	}
+	owner, ok := c.routes[rt.key()]
+	return owner, ok
+}
+
+// routeClaims collects the routes claimed by the Applications of every project. The oldest
+// Application keeps a contested route, so adding a route never takes one from a running
+// Application.
+func (r *ApplicationReconciler) routeClaims(ctx context.Context, app *platformv1alpha1.Application) (routeClaims, error) {
+	claims := routeClaims{hosts: map[string]string{}, routes: map[string]string{}}
+	var apps platformv1alpha1.ApplicationList
+	if err := r.controlPlaneClient().List(ctx, &apps); err != nil {
+		return claims, err
+	}
+	sort.Slice(apps.Items, func(i, j int) bool { return claimsBefore(&apps.Items[i], &apps.Items[j]) })
+
+	for i := range apps.Items {
+		other := &apps.Items[i]
+		if (other.Namespace == app.Namespace && other.Name == app.Name) || other.DeletionTimestamp != nil {
+			continue
+		}
+		owner := other.Namespace + "/" + other.Name
+		if other.Spec.Preview == nil {
+			if host := r.defaultHost(other, ""); host != "" {
+				claims.hosts[host] = owner
+			}
+		}
+		if !claimsBefore(other, app) {
+			continue
+		}
+		for _, rt := range specRoutes(other) {
+			if _, claimed := claims.routes[rt.key()]; !claimed {
+				claims.routes[rt.key()] = owner
+			}
+		}
+	}
+	return claims, nil
+}
+
+// claimsBefore orders Applications by creation, then by namespace and name.
+func claimsBefore(a, b *platformv1alpha1.Application) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// routeRequeueAfter is when an Application with conflicting routes checks them again.
+func routeRequeueAfter(app *platformv1alpha1.Application) time.Duration {
+	if apimeta.IsStatusConditionTrue(app.Status.Conditions, platformv1alpha1.RouteConflict) {
+		return routeConflictRequeue
+	}
+	return 0
+}
+
+// tlsSecretName is the Secret cert-manager stores the certificate of an Application in.
+func tlsSecretName(app *platformv1alpha1.Application) string {
+	return app.Name + "-tls"
+}
+
+// reconcileCertificate requests a certificate covering the served hostnames from the
+// configured ClusterIssuer. Without a ClusterIssuer TLS is disabled and cert-manager is
+// not required.
+func (r *ApplicationReconciler) reconcileCertificate(ctx context.Context, app *platformv1alpha1.Application, served []route) error {
+	if r.ClusterIssuer == "" {
+		return nil
+	}
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(certificateGVK)
+	cert.SetName(tlsSecretName(app))
+	cert.SetNamespace(app.Namespace)
+
+	if len(served) == 0 {
+		if err := r.Delete(ctx, cert); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	var dnsNames []any
+	seen := map[string]bool{}
+	for _, rt := range served {
+		if !seen[rt.host] {
+			seen[rt.host] = true
+			dnsNames = append(dnsNames, rt.host)
+		}
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cert, func() error {
+		cert.SetLabels(applicationLabels(app))
+		if err := unstructured.SetNestedField(cert.Object, tlsSecretName(app), "spec", "secretName"); err != nil {
+			return err
+		}
+		if err := unstructured.SetNestedSlice(cert.Object, dnsNames, "spec", "dnsNames"); err != nil {
+			return err
+		}
+		if err := unstructured.SetNestedMap(cert.Object, map[string]any{
+			"kind": "ClusterIssuer",
+			"name": r.ClusterIssuer,
+		}, "spec", "issuerRef"); err != nil {
+			return err
+		}
+		return r.setOwner(app, cert)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("Certificate reconciled", "Certificate.Name", cert.GetName(), "operation", op, "dnsNames", dnsNames)
+	}
+	return nil
+}
+
+// reconcileIngress serves the routes through the Application's Service, and removes the
+// Ingress once there are none.
+func (r *ApplicationReconciler) reconcileIngress(ctx context.Context, app *platformv1alpha1.Application, served []route, tls bool) error {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace},
+	}
+
+	if len(served) == 0 {
+		if err := r.Delete(ctx, ingress); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, func() error {
+		className := r.IngressClassName
+		if className == "" {
+			className = defaultIngressClassName
+		}
+		pathType := networkingv1.PathTypePrefix
+
+		// one rule per host, in the order the hosts were first routed
+		var rules []networkingv1.IngressRule
+		var hosts []string
+		index := map[string]int{}
+		for _, rt := range served {
+			i, ok := index[rt.host]
+			if !ok {
+				i = len(rules)
+				index[rt.host] = i
+				hosts = append(hosts, rt.host)
+				rules = append(rules, networkingv1.IngressRule{
+					Host:             rt.host,
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{}},
+				})
+			}
+			port := networkingv1.ServiceBackendPort{Name: "http"}
+			if rt.port != 0 {
+				port = networkingv1.ServiceBackendPort{Number: rt.port}
+			}
+			rules[i].HTTP.Paths = append(rules[i].HTTP.Paths, networkingv1.HTTPIngressPath{
+				Path:     rt.path,
+				PathType: &pathType,
+				Backend: networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{Name: app.Name, Port: port},
+				},
+			})
+		}
+
+		ingress.Labels = applicationLabels(app)
+		ingress.Spec = networkingv1.IngressSpec{
+			IngressClassName: &className,
+			Rules:            rules,
+		}
+		if tls {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: hosts, SecretName: tlsSecretName(app)}}
+		}
+		return r.setOwner(app, ingress)
+	})
+	return err
+}
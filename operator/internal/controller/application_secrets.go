@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// managedSecretName is the Secret the platform-managed secrets of an Application are
+// synced into.
+func managedSecretName(app *platformv1alpha1.Application) string {
+	return app.Name + "-env"
+}
+
+// managesSecrets reports whether the Application's pods get its platform-managed
+// secrets, that is whether the API has written some.
+func (r *ApplicationReconciler) managesSecrets(app *platformv1alpha1.Application) bool {
+	return app.Annotations[platformv1alpha1.SecretsVersionAnnotation] != ""
+}
+
+// reconcileManagedSecret syncs the platform-managed secrets of the Application from the
+// secret store into its <app>-env Secret, and removes that Secret once there are none.
+// Previews get the secrets of their parent. Values are never logged.
+//
+// Without a secret store the Secret cannot be synced but is not removed either, the
+// pods keep the values last synced and SecretsUnavailable is set.
+func (r *ApplicationReconciler) reconcileManagedSecret(ctx context.Context, app *platformv1alpha1.Application) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: managedSecretName(app), Namespace: app.Namespace},
+	}
+
+	if !r.managesSecrets(app) {
+		apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.SecretsUnavailable)
+		if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	if r.Secrets == nil {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.SecretsUnavailable,
+			Status:             metav1.ConditionTrue,
+			Reason:             "NoSecretStore",
+			Message:            "No secret store is configured, the managed secrets are not synced into " + secret.Name,
+			ObservedGeneration: app.GetGeneration(),
+		})
+		return nil
+	}
+	apimeta.RemoveStatusCondition(&app.Status.Conditions, platformv1alpha1.SecretsUnavailable)
+
+	owner := app.Name
+	if app.Spec.Preview != nil {
+		owner = app.Spec.Preview.Parent
+	}
+	values, err := r.Secrets.Values(ctx, app.Namespace, owner)
+	if err != nil {
+		return err
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Labels = applicationLabels(app)
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = values
+		return r.setOwner(app, secret)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("Managed secrets synced", "Secret.Name", secret.Name, "operation", op, "keys", len(values))
+	}
+	return nil
+}
+
+// renderEnv maps the Application's env and envFrom onto the container, adding the
+// managed secrets last so they override the Secrets and ConfigMaps of spec.envFrom.
+func (r *ApplicationReconciler) renderEnv(app *platformv1alpha1.Application, container *corev1.Container) {
+	var env []corev1.EnvVar
+	for _, e := range app.Spec.Env {
+		v := corev1.EnvVar{Name: e.Name, Value: e.Value}
+		if e.ValueFrom != nil {
+			v.ValueFrom = &corev1.EnvVarSource{
+				SecretKeyRef:    e.ValueFrom.SecretKeyRef,
+				ConfigMapKeyRef: e.ValueFrom.ConfigMapKeyRef,
+			}
+		}
+		env = append(env, v)
+	}
+
+	var envFrom []corev1.EnvFromSource
+	for _, e := range app.Spec.EnvFrom {
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			Prefix:       e.Prefix,
+			SecretRef:    e.SecretRef,
+			ConfigMapRef: e.ConfigMapRef,
+		})
+	}
+	if r.managesSecrets(app) {
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: managedSecretName(app)},
+			},
+		})
+	}
+
+	container.Env = env
+	container.EnvFrom = envFrom
+}
+
+// renderSecretsVersion stamps the version of the managed secrets on the pod template, so
+// pods are restarted to pick up new values; environment variables are only read at start.
+func (r *ApplicationReconciler) renderSecretsVersion(app *platformv1alpha1.Application, template *corev1.PodTemplateSpec) {
+	if !r.managesSecrets(app) {
+		delete(template.Annotations, platformv1alpha1.SecretsVersionAnnotation)
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[platformv1alpha1.SecretsVersionAnnotation] = app.Annotations[platformv1alpha1.SecretsVersionAnnotation]
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/buildstrategy"
+)
+
+// maxBuildHistory bounds ApplicationStatus.Builds.
+const maxBuildHistory = 10
+
+// listOwnedPipelineRuns returns the build PipelineRuns controlled by the Application, newest first.
+func (r *ApplicationReconciler) listOwnedPipelineRuns(ctx context.Context, app *platformv1alpha1.Application) ([]tektonv1.PipelineRun, error) {
+	var runs tektonv1.PipelineRunList
+	if err := r.List(ctx, &runs,
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels{platformv1alpha1.ApplicationLabel: app.Name},
+	); err != nil {
+		return nil, err
+	}
+
+	owned := make([]tektonv1.PipelineRun, 0, len(runs.Items))
+	for _, run := range runs.Items {
+		if metav1.IsControlledBy(&run, app) {
+			owned = append(owned, run)
+		}
+	}
+	sort.SliceStable(owned, func(i, j int) bool {
+		return owned[j].CreationTimestamp.Before(&owned[i].CreationTimestamp)
+	})
+	return owned, nil
+}
+
+// observeBuilds folds the state of the Application's PipelineRuns into its status:
+// the build history, the Building / BuildFailed conditions and, for the newest
+// successful run, the image digest and commit that should be rolled out. A prebuilt
+// image keeps the digest resolvePrebuiltImage pinned, earlier builds stay in the history.
+// runs must be sorted newest first.
+func (r *ApplicationReconciler) observeBuilds(app *platformv1alpha1.Application, runs []tektonv1.PipelineRun, prebuilt bool) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		upsertBuildRecord(&app.Status, r.buildRecordFor(&runs[i]))
+	}
+
+	var latestFinished *tektonv1.PipelineRun
+	var latestSucceeded *tektonv1.PipelineRun
+	running := 0
+	for i := range runs {
+		run := &runs[i]
+		if !run.IsDone() {
+			running++
+			continue
+		}
+		if latestFinished == nil {
+			latestFinished = run
+		}
+		if latestSucceeded == nil && buildOutcome(run) == platformv1alpha1.BuildOutcomeSucceeded {
+			latestSucceeded = run
+		}
+	}
+
+	if latestSucceeded != nil && !prebuilt {
+		record := r.buildRecordFor(latestSucceeded)
+		if record.Image != "" {
+			app.Status.Image = record.Image
+			app.Status.SourceImage = ""
+		}
+		if record.Revision != "" {
+			app.Status.Revision = record.Revision
+		}
+	}
+
+	if running > 0 {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Building,
+			Status:             metav1.ConditionTrue,
+			Reason:             "PipelineRunning",
+			Message:            "PipelineRun " + runs[0].Name + " is running",
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Building,
+			Status:             metav1.ConditionFalse,
+			Reason:             "Idle",
+			Message:            "No build in progress",
+			ObservedGeneration: app.GetGeneration(),
+		})
+	}
+
+	if latestFinished == nil {
+		return
+	}
+	if outcome := buildOutcome(latestFinished); outcome == platformv1alpha1.BuildOutcomeSucceeded {
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.BuildFailed,
+			Status:             metav1.ConditionFalse,
+			Reason:             "BuildSucceeded",
+			Message:            "PipelineRun " + latestFinished.Name + " succeeded",
+			ObservedGeneration: app.GetGeneration(),
+		})
+	} else {
+		message := "PipelineRun " + latestFinished.Name + " " + strings.ToLower(outcome)
+		if cond := latestFinished.Status.GetCondition(apis.ConditionSucceeded); cond != nil && cond.Message != "" {
+			message += ": " + cond.Message
+		}
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.BuildFailed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Build" + outcome,
+			Message:            message,
+			ObservedGeneration: app.GetGeneration(),
+		})
+	}
+}
+
+// observeWorkload sets the Ready condition and Health from the rendered Deployment, or
+// from the CronJob or Job of cron and job Applications.
+func (r *ApplicationReconciler) observeWorkload(ctx context.Context, app *platformv1alpha1.Application) error {
+	if deployedImage(app) == "" {
+		app.Status.Health = platformv1alpha1.HealthProgressing
+		apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Ready,
+			Status:             metav1.ConditionFalse,
+			Reason:             "AwaitingBuild",
+			Message:            "No image has been built yet",
+			ObservedGeneration: app.GetGeneration(),
+		})
+		return nil
+	}
+	if !runsDeployment(app) {
+		return r.observeJobWorkload(ctx, app)
+	}
+
+	var deploy appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(app), &deploy); err != nil {
+		if errors.IsNotFound(err) {
+			app.Status.Health = platformv1alpha1.HealthProgressing
+			apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+				Type:               platformv1alpha1.Ready,
+				Status:             metav1.ConditionFalse,
+				Reason:             "DeploymentMissing",
+				Message:            "Deployment has not been created yet",
+				ObservedGeneration: app.GetGeneration(),
+			})
+			return nil
+		}
+		return err
+	}
+
+	ready, reason, message := deploymentReady(&deploy)
+	status := metav1.ConditionFalse
+	app.Status.Health = platformv1alpha1.HealthProgressing
+	if ready {
+		status = metav1.ConditionTrue
+		app.Status.Health = platformv1alpha1.HealthHealthy
+	} else if reason == "ProgressDeadlineExceeded" {
+		app.Status.Health = platformv1alpha1.HealthError
+	}
+	apimeta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.Ready,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.GetGeneration(),
+	})
+	return nil
+}
+
+// deploymentReady reports whether the Deployment has fully rolled out its current template.
+func deploymentReady(deploy *appsv1.Deployment) (bool, string, string) {
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return false, "ProgressDeadlineExceeded", cond.Message
+		}
+	}
+	if deploy.Status.ObservedGeneration < deploy.Generation {
+		return false, "RolloutPending", "Deployment spec update has not been observed yet"
+	}
+	desired := int32(1)
+	if deploy.Spec.Replicas != nil {
+		desired = *deploy.Spec.Replicas
+	}
+	if deploy.Status.UpdatedReplicas < desired || deploy.Status.AvailableReplicas < desired {
+		return false, "RolloutInProgress", "Waiting for new replicas to become available"
+	}
+	return true, "Available", "Application is running the latest image"
+}
+
+// buildOutcome maps the Succeeded condition of a PipelineRun to a BuildRecord outcome.
+func buildOutcome(run *tektonv1.PipelineRun) string {
+	cond := run.Status.GetCondition(apis.ConditionSucceeded)
+	if cond == nil || cond.Status == corev1.ConditionUnknown {
+		return platformv1alpha1.BuildOutcomeRunning
+	}
+	if cond.Status == corev1.ConditionTrue {
+		return platformv1alpha1.BuildOutcomeSucceeded
+	}
+	switch cond.Reason {
+	case tektonv1.PipelineRunReasonCancelled.String(), tektonv1.PipelineRunReasonCancelledRunningFinally.String():
+		return platformv1alpha1.BuildOutcomeCancelled
+	}
+	return platformv1alpha1.BuildOutcomeFailed
+}
+
+// buildRecordFor summarises a PipelineRun as a history entry. The pushed image and
+// commit are parsed by the strategy that created the run.
+func (r *ApplicationReconciler) buildRecordFor(run *tektonv1.PipelineRun) platformv1alpha1.BuildRecord {
+	record := platformv1alpha1.BuildRecord{
+		PipelineRun:    run.Name,
+		Outcome:        buildOutcome(run),
+		Trigger:        run.Annotations[platformv1alpha1.BuildTriggerAnnotation],
+		TriggeredBy:    run.Annotations[platformv1alpha1.BuildTriggeredByAnnotation],
+		CancelledBy:    run.Annotations[platformv1alpha1.BuildCancelledByAnnotation],
+		StartTime:      run.Status.StartTime,
+		CompletionTime: run.Status.CompletionTime,
+	}
+	if record.StartTime == nil {
+		record.StartTime = &run.CreationTimestamp
+	}
+
+	image, revision := buildstrategy.ParseStandardResults(run)
+	if strategy, ok := r.strategies().Get(run.Annotations[platformv1alpha1.BuildStrategyAnnotation]); ok {
+		image, revision = strategy.ParseResults(run)
+	}
+	record.Revision = revision
+	if record.Outcome == platformv1alpha1.BuildOutcomeSucceeded {
+		record.Image = image
+	}
+	if record.Outcome == platformv1alpha1.BuildOutcomeFailed || record.Outcome == platformv1alpha1.BuildOutcomeCancelled {
+		if cond := run.Status.GetCondition(apis.ConditionSucceeded); cond != nil {
+			record.Message = cond.Message
+		}
+	}
+	return record
+}
+
+// upsertBuildRecord inserts or refreshes the history entry for a PipelineRun and keeps
+// the list sorted newest first and bounded to maxBuildHistory entries. Entries whose
+// PipelineRun has since been deleted are kept until they age out.
+func upsertBuildRecord(status *platformv1alpha1.ApplicationStatus, record platformv1alpha1.BuildRecord) {
+	found := false
+	for i := range status.Builds {
+		if status.Builds[i].PipelineRun == record.PipelineRun {
+			status.Builds[i] = record
+			found = true
+			break
+		}
+	}
+	if !found {
+		status.Builds = append(status.Builds, record)
+	}
+
+	sort.SliceStable(status.Builds, func(i, j int) bool {
+		a, b := status.Builds[i].StartTime, status.Builds[j].StartTime
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return b.Before(a)
+	})
+	if len(status.Builds) > maxBuildHistory {
+		status.Builds = status.Builds[:maxBuildHistory]
+	}
+}
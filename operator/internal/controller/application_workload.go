@@ -0,0 +1,332 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+const (
+	// appContainerName is the name of the application container in the rendered pod template.
+	appContainerName = "app"
+	// defaultAppPort is the container port the application listens on unless spec.port is set.
+	defaultAppPort int32 = 8080
+	// defaultCPUUtilization is the average CPU utilisation the HPA scales on.
+	defaultCPUUtilization int32 = 80
+)
+
+// applicationSelectorLabels are the immutable labels used by the Deployment selector
+// and the Service selector.
+func applicationSelectorLabels(app *platformv1alpha1.Application) map[string]string {
+	return map[string]string{
+		platformv1alpha1.ApplicationLabel: app.Name,
+	}
+}
+
+// stableSelectorLabels select the pods of the stable Deployment. Deployments created
+// before progressive rollouts select on the application label alone.
+func stableSelectorLabels(app *platformv1alpha1.Application) map[string]string {
+	labels := applicationSelectorLabels(app)
+	labels[platformv1alpha1.RolloutTrackLabel] = trackStable
+	return labels
+}
+
+// applicationLabels are the labels stamped on every workload object of an Application.
+func applicationLabels(app *platformv1alpha1.Application) map[string]string {
+	labels := applicationSelectorLabels(app)
+	labels["app.kubernetes.io/name"] = app.Name
+	labels[platformv1alpha1.ManagedByLabel] = platformv1alpha1.ManagedByValue
+	return labels
+}
+
+// reconcileWorkload renders the workload that runs the last image built for the
+// Application, or the one it is pinned to by a rollback, after syncing the
+// platform-managed secrets its pods read: a Deployment with a HorizontalPodAutoscaler,
+// and a Service for web Applications, a CronJob for cron Applications or a Job for job
+// Applications. Objects left from another spec.type are deleted.
+// Applications live in their project namespace, so the workload objects are created next
+// to the CR and owned by it.
+//
+// Nothing is rendered until a build has produced an image (Status.Image is empty).
+// When a new build lands the image changes and the Deployment is rolled, at once or
+// through the progressive rollout configured in spec.rollout, once the pre-deploy hook
+// succeeded with it.
+func (r *ApplicationReconciler) reconcileWorkload(ctx context.Context, app *platformv1alpha1.Application) error {
+	logger := logf.FromContext(ctx)
+
+	if deployedImage(app) == "" {
+		logger.Info("No image built yet for Application, skipping workload", "application", app.Name)
+		return nil
+	}
+
+	if err := r.reconcileManagedSecret(ctx, app); err != nil {
+		logger.Error(err, "Failed to sync managed secrets for Application")
+		return err
+	}
+
+	if applicationType(app) != platformv1alpha1.ApplicationTypeCron {
+		if err := r.removeCronJob(ctx, app); err != nil {
+			logger.Error(err, "Failed to delete CronJob of Application")
+			return err
+		}
+	}
+	if !runsDeployment(app) {
+		if err := r.removeDeploymentWorkload(ctx, app); err != nil {
+			logger.Error(err, "Failed to delete Deployment of Application")
+			return err
+		}
+	}
+	switch applicationType(app) {
+	case platformv1alpha1.ApplicationTypeCron:
+		if err := r.reconcileCronWorkload(ctx, app); err != nil {
+			logger.Error(err, "Failed to reconcile CronJob for Application")
+			return err
+		}
+		return nil
+	case platformv1alpha1.ApplicationTypeJob:
+		if err := r.reconcileJobWorkload(ctx, app); err != nil {
+			logger.Error(err, "Failed to reconcile Job for Application")
+			return err
+		}
+		return nil
+	}
+
+	current, err := r.stableImage(ctx, app)
+	if err != nil {
+		logger.Error(err, "Failed to get Deployment of Application")
+		return err
+	}
+	allowed, err := r.reconcilePreDeploy(ctx, app, current)
+	if err != nil {
+		logger.Error(err, "Failed to run pre-deploy hook for Application")
+		return err
+	}
+	image := current
+	if allowed {
+		if image, err = r.reconcileRollout(ctx, app); err != nil {
+			logger.Error(err, "Failed to reconcile rollout for Application")
+			return err
+		}
+	} else if current == "" {
+		logger.Info("Waiting for the pre-deploy hook before the first deployment", "application", app.Name)
+		return nil
+	}
+	if err := r.reconcileDeployment(ctx, app, image); err != nil {
+		logger.Error(err, "Failed to reconcile Deployment for Application")
+		return err
+	}
+	if image == deployedImage(app) {
+		recordDeployment(app)
+	}
+	if applicationType(app) == platformv1alpha1.ApplicationTypeWeb {
+		err = r.reconcileService(ctx, app)
+	} else {
+		err = r.removeService(ctx, app)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to reconcile Service for Application")
+		return err
+	}
+	if err := r.reconcileHPA(ctx, app); err != nil {
+		logger.Error(err, "Failed to reconcile HorizontalPodAutoscaler for Application")
+		return err
+	}
+	return nil
+}
+
+// stableImage is the image the stable Deployment runs, empty before the first deployment.
+func (r *ApplicationReconciler) stableImage(ctx context.Context, app *platformv1alpha1.Application) (string, error) {
+	var stable appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(app), &stable); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return appContainerImage(&stable.Spec.Template.Spec), nil
+}
+
+func (r *ApplicationReconciler) reconcileDeployment(ctx context.Context, app *platformv1alpha1.Application, image string) error {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace},
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, deploy, func() error {
+		podLabels := applicationLabels(app)
+		podLabels[platformv1alpha1.RolloutTrackLabel] = trackStable
+
+		deploy.Labels = applicationLabels(app)
+
+		// the HPA owns the replica count once the Deployment exists, so only seed
+		// it on creation; without an HPA the Deployment runs the minimum.
+		if deploy.Spec.Replicas == nil || !autoscaled(app) {
+			replicas := minReplicas(app)
+			deploy.Spec.Replicas = &replicas
+		}
+		// the selector is immutable, only set it on creation.
+		if deploy.Spec.Selector == nil {
+			deploy.Spec.Selector = &metav1.LabelSelector{MatchLabels: stableSelectorLabels(app)}
+		}
+
+		deploy.Spec.Template.Labels = podLabels
+		deploy.Spec.Template.Spec.ImagePullSecrets = imagePullSecrets(app)
+		r.renderAppContainer(app, &deploy.Spec.Template.Spec, image)
+		r.renderSecretsVersion(app, &deploy.Spec.Template)
+
+		return r.setOwner(app, deploy)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("Deployment reconciled", "Deployment.Name", deploy.Name, "operation", op, "image", image)
+	}
+	return nil
+}
+
+func (r *ApplicationReconciler) reconcileService(ctx context.Context, app *platformv1alpha1.Application) error {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		svc.Labels = applicationLabels(app)
+		svc.Spec.Selector = serviceSelector(app)
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       "http",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       80,
+			TargetPort: intstr.FromString("http"),
+		}}
+		// routes to other container ports are served under the same number
+		for _, port := range routePorts(app) {
+			svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{
+				Name:       fmt.Sprintf("port-%d", port),
+				Protocol:   corev1.ProtocolTCP,
+				Port:       port,
+				TargetPort: intstr.FromInt32(port),
+			})
+		}
+		return r.setOwner(app, svc)
+	})
+	return err
+}
+
+// removeService deletes the Service of a worker Application, which is not reachable.
+func (r *ApplicationReconciler) removeService(ctx context.Context, app *platformv1alpha1.Application) error {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	if err := r.Delete(ctx, svc); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *ApplicationReconciler) reconcileHPA(ctx context.Context, app *platformv1alpha1.Application) error {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace},
+	}
+
+	// without an upper bound there is nothing to autoscale, drop any HPA left from a previous spec
+	if !autoscaled(app) {
+		if err := r.Delete(ctx, hpa); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, hpa, func() error {
+		minReplicas := minReplicas(app)
+		maxReplicas := max(app.Spec.Autoscaling.Max, minReplicas)
+		utilization := defaultCPUUtilization
+
+		hpa.Labels = applicationLabels(app)
+		hpa.Spec = autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       app.Name,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: maxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name: corev1.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{
+						Type:               autoscalingv2.UtilizationMetricType,
+						AverageUtilization: &utilization,
+					},
+				},
+			}},
+		}
+		return r.setOwner(app, hpa)
+	})
+	return err
+}
+
+// renderAppContainer writes the application container into the pod spec from the Application
+// spec and the given image. The container is updated in place so fields defaulted by the
+// apiserver are preserved and an unchanged Application does not cause a Deployment update.
+func (r *ApplicationReconciler) renderAppContainer(app *platformv1alpha1.Application, podSpec *corev1.PodSpec, image string) {
+	idx := -1
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == appContainerName {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		podSpec.Containers = append(podSpec.Containers, corev1.Container{Name: appContainerName})
+		idx = len(podSpec.Containers) - 1
+	}
+	container := &podSpec.Containers[idx]
+
+	container.Image = image
+	container.Command = app.Spec.Command
+	container.Args = app.Spec.Args
+	r.renderEnv(app, container)
+	container.Ports = []corev1.ContainerPort{{
+		Name:          "http",
+		ContainerPort: appPort(app),
+		Protocol:      corev1.ProtocolTCP,
+	}}
+	container.Resources = renderResources(app)
+	container.LivenessProbe = app.Spec.LivenessProbe
+	container.ReadinessProbe = app.Spec.ReadinessProbe
+	container.StartupProbe = app.Spec.StartupProbe
+}
+
+// routePorts are the distinct container ports of spec.routes other than the application
+// port, in ascending order.
+func routePorts(app *platformv1alpha1.Application) []int32 {
+	var ports []int32
+	for _, rt := range specRoutes(app) {
+		if rt.port != 0 && !slices.Contains(ports, rt.port) {
+			ports = append(ports, rt.port)
+		}
+	}
+	slices.Sort(ports)
+	return ports
+}
+
+// autoscaled reports whether the Application gets a HorizontalPodAutoscaler.
+func autoscaled(app *platformv1alpha1.Application) bool {
+	return app.Spec.Autoscaling.Max > 0
+}
+
+// minReplicas returns the lower autoscaling bound, never less than one replica.
+func minReplicas(app *platformv1alpha1.Application) int32 {
+	return max(app.Spec.Autoscaling.Min, 1)
+}
@@ -6,15 +6,19 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/clusterprovider"
 	"github.com/mofe64/vulkan/operator/internal/metrics"
 	"github.com/mofe64/vulkan/operator/internal/utils"
 )
@@ -24,17 +28,46 @@ type ClusterReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	TargetFactory utils.TargetClientFactory
+
+	// APIReader reads the pods of attached clusters straight from the API server
+	// (mgr.GetAPIReader()), so health checks do not start a cluster-wide Pod informer
+	// in the manager cache. Client is used when nil.
+	APIReader client.Reader
+
+	// Providers provision the clusters of the types they are registered for,
+	// clusterprovider.Default() when nil.
+	Providers *clusterprovider.Registry
+
+	// ReadyNodeThreshold is the percentage of ready nodes under which a cluster is
+	// Degraded (from flags), DefaultReadyNodeThreshold when zero.
+	ReadyNodeThreshold int
+
+	// HealthCheckInterval is how often a cluster is health-checked, stretched by up to
+	// HealthCheckJitter of itself. A cluster not reached for HeartbeatTimeout turns
+	// Ready=Unknown. Zero values fall back to the Default* constants.
+	HealthCheckInterval time.Duration
+	HealthCheckJitter   float64
+	HeartbeatTimeout    time.Duration
 }
 
 // +kubebuilder:rbac:groups=platform.platform.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=platform.platform.io,resources=clusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=platform.platform.io,resources=clusters/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+
 func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 	log.Info("Reconciling Cluster", "name", req.Name, "namespace", req.Namespace)
 
 	var clu platformv1alpha1.Cluster
 	if err := r.Get(ctx, req.NamespacedName, &clu); err != nil {
+		if errors.IsNotFound(err) {
+			// the cluster is gone, so is any use for the client built for it
+			if evicter, ok := r.TargetFactory.(utils.TargetClientEvicter); ok {
+				evicter.Evict(req.Name)
+			}
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
@@ -45,14 +78,28 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		if utils.ContainsString(clu.ObjectMeta.Finalizers, platformv1alpha1.ClusterFinalizer) {
 			log.Info("Finalizing Cluster", "name", req.Name)
 
+			// provisioned clusters are torn down before the CR is released
+			if provider, ok := r.providers().Get(clu.Spec.Type); ok {
+				done, err := r.deprovision(ctx, &clu, provider)
+				if err != nil {
+					log.Error(err, "Error deleting provisioned cluster")
+					return ctrl.Result{}, err
+				}
+				if !done {
+					return ctrl.Result{RequeueAfter: provisioningRequeue}, nil
+				}
+			}
+
 			// remove the finalizer
 			clu.ObjectMeta.Finalizers = utils.RemoveString(clu.ObjectMeta.Finalizers, platformv1alpha1.ClusterFinalizer)
 			if err := r.Update(ctx, &clu); err != nil {
 				return ctrl.Result{}, err
 			}
 
-			// decrement the cluster metrics
-			metrics.DecClusters(clu.Spec.OrgRef)
+			// the cluster no longer counts against the org
+			if err := r.countClusters(ctx, clu.Spec.OrgRef); err != nil {
+				return ctrl.Result{}, err
+			}
 		}
 		return ctrl.Result{}, nil
 	}
@@ -155,6 +202,40 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	}
 
+	// provision the cluster first when its type has a provider
+	if provider, ok := r.providers().Get(clu.Spec.Type); ok {
+		ready, err := r.reconcileProvisioning(ctx, &clu, provider)
+		if err != nil {
+			log.Error(err, "Cluster provisioning failed")
+			apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+				Type:               platformv1alpha1.Ready,
+				Status:             metav1.ConditionFalse,
+				Reason:             "ProvisioningFailed",
+				Message:            "Provisioning failed: " + err.Error(),
+				ObservedGeneration: clu.GetGeneration(),
+			})
+			apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+				Type:               platformv1alpha1.Error,
+				Status:             metav1.ConditionTrue,
+				Reason:             "ProvisioningFailed",
+				Message:            err.Error(),
+				ObservedGeneration: clu.GetGeneration(),
+			})
+			if err := utils.UpdateClusterStatusWithRetry(ctx, r.Client, &clu); err != nil {
+				log.Error(err, "Error updating cluster status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: time.Minute * 2}, nil
+		}
+		if !ready {
+			if err := utils.UpdateClusterStatusWithRetry(ctx, r.Client, &clu); err != nil {
+				log.Error(err, "Error updating cluster status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: provisioningRequeue}, nil
+		}
+	}
+
 	// check kubeconfig secret exists
 	var secret corev1.Secret
 	err = r.Get(ctx, types.NamespacedName{
@@ -191,8 +272,17 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	// check the cluster health
 	isHealthy, msg, err := r.checkClusterHealth(ctx, &clu)
-	if err != nil || !isHealthy {
-		log.Error(err, "Cluster health check failed", "message", msg)
+	if err != nil {
+		log.Error(err, "Cluster could not be reached", "message", msg)
+		r.reportUnreachable(&clu, msg+": "+err.Error())
+		if err := utils.UpdateClusterStatusWithRetry(ctx, r.Client, &clu); err != nil {
+			log.Error(err, "Error updating cluster status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.healthCheckRequeue()}, nil
+	}
+	if !isHealthy {
+		log.Info("Cluster health check failed", "message", msg)
 		apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
 			Type:               platformv1alpha1.Ready,
 			Status:             metav1.ConditionFalse,
@@ -212,19 +302,35 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			log.Error(err, "Error updating cluster status")
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{RequeueAfter: time.Minute * 2}, nil
+		return ctrl.Result{RequeueAfter: r.healthCheckRequeue()}, nil
 	}
 
-	// update the metrics
-	metrics.IncClusters(clu.Spec.OrgRef)
-
 	// add the finalizer
 	if !utils.ContainsString(clu.ObjectMeta.Finalizers, platformv1alpha1.ClusterFinalizer) {
 		clu.ObjectMeta.Finalizers = append(clu.ObjectMeta.Finalizers, platformv1alpha1.ClusterFinalizer)
+		// the update reloads the object, keep the status observed so far
+		status := clu.Status
 		if err := r.Update(ctx, &clu); err != nil {
 			log.Error(err, "Error updating cluster")
 			return ctrl.Result{}, err
 		}
+		clu.Status = status
+	}
+
+	// update the metrics
+	if err := r.countClusters(ctx, clu.Spec.OrgRef); err != nil {
+		log.Error(err, "Error counting clusters")
+		return ctrl.Result{}, err
+	}
+
+	// bring the nodes in line with the declared node pools
+	tgtClient, err := r.targetClient(ctx, &clu)
+	if err == nil {
+		err = r.reconcileNodePools(ctx, &clu, tgtClient)
+	}
+	if err != nil {
+		log.Error(err, "Error reconciling node pools")
+		return ctrl.Result{}, err
 	}
 
 	// set the cluster ready condition to true
@@ -232,9 +338,21 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		Type:               platformv1alpha1.Ready,
 		Status:             metav1.ConditionTrue,
 		Reason:             "Reconciled",
-		Message:            "Cluster is healthy",
+		Message:            fmt.Sprintf("Cluster is healthy, %d of %d nodes are ready", clu.Status.ReadyNodes, clu.Status.Nodes),
 		ObservedGeneration: clu.GetGeneration(),
 	})
+	// a usable cluster may still run short of ready nodes or components
+	degraded := metav1.Condition{
+		Type:               platformv1alpha1.Degraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Healthy",
+		Message:            "All components are healthy",
+		ObservedGeneration: clu.GetGeneration(),
+	}
+	if reason, msg := clusterDegraded(&clu, r.readyNodeThreshold()); reason != "" {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, reason, msg
+	}
+	apimeta.SetStatusCondition(&clu.Status.Conditions, degraded)
 	// clear the Error flag if it was set previously
 	apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
 		Type:               platformv1alpha1.Error,
@@ -251,18 +369,53 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	log.Info("Cluster reconciled", "id", clu.Name, "phase", clu.Status.Conditions)
-	return ctrl.Result{}, nil
+	// healthy clusters are checked again periodically, nothing else would notice them fail
+	return ctrl.Result{RequeueAfter: r.healthCheckRequeue()}, nil
 }
 
-func (r *ClusterReconciler) checkClusterHealth(ctx context.Context, clu *platformv1alpha1.Cluster) (bool, string, error) {
+// countClusters sets the cluster gauge of the org to the number of its clusters holding
+// the finalizer and not being deleted. Recounting keeps it right across the periodic
+// health checks and operator restarts.
+func (r *ClusterReconciler) countClusters(ctx context.Context, org string) error {
+	var clusters platformv1alpha1.ClusterList
+	if err := r.List(ctx, &clusters); err != nil {
+		return err
+	}
+	count := 0
+	for _, clu := range clusters.Items {
+		if clu.Spec.OrgRef == org && clu.DeletionTimestamp.IsZero() &&
+			utils.ContainsString(clu.Finalizers, platformv1alpha1.ClusterFinalizer) {
+			count++
+		}
+	}
+	metrics.SetClusters(org, count)
+	return nil
+}
 
-	var tgtClient client.Client
-	var err error
-	if clu.Spec.Type != "attached" {
-		tgtClient, err = r.TargetFactory.ClientFor(ctx, clu)
-	} else {
-		tgtClient = r.Client
+// targetClient returns a client for the cluster: the control plane's own for attached
+// clusters, one built from the kubeconfig Secret for the others.
+func (r *ClusterReconciler) targetClient(ctx context.Context, clu *platformv1alpha1.Cluster) (client.Client, error) {
+	if clu.Spec.Type == clusterTypeAttached {
+		return r.Client, nil
+	}
+	if r.TargetFactory == nil {
+		return nil, fmt.Errorf("no client factory configured for cluster %s", clu.Name)
 	}
+	return r.TargetFactory.ClientFor(ctx, clu)
+}
+
+// podReader returns the reader the health check lists the pods of the cluster with.
+// The clients of remote clusters are not cached; the one of attached clusters is.
+func (r *ClusterReconciler) podReader(clu *platformv1alpha1.Cluster, tgtClient client.Client) client.Reader {
+	if clu.Spec.Type == clusterTypeAttached && r.APIReader != nil {
+		return r.APIReader
+	}
+	return tgtClient
+}
+
+func (r *ClusterReconciler) checkClusterHealth(ctx context.Context, clu *platformv1alpha1.Cluster) (bool, string, error) {
+
+	tgtClient, err := r.targetClient(ctx, clu)
 
 	log := logf.FromContext(ctx)
 	if err != nil {
@@ -275,21 +428,33 @@ func (r *ClusterReconciler) checkClusterHealth(ctx context.Context, clu *platfor
 	}
 
 	log.Info("Health check", "clusterType", clu.Spec.Type, "nodeCount", len(nodes.Items))
+	// the cluster answered, whatever the state of its nodes
+	now := metav1.Now()
+	clu.Status.LastHeartbeatTime = &now
 
 	if len(nodes.Items) == 0 {
 		log.Info("No nodes found in cluster")
 		return false, "No nodes found in cluster", nil
 	}
-	for _, node := range nodes.Items {
-		for _, condition := range node.Status.Conditions {
-			if condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue {
-				log.Info("Node not ready", "nodeName", node.Name, "condition", condition)
-				return false, "Node " + node.Name + " is not ready", nil
-			}
+
+	// a cluster is only unusable once none of its nodes is ready; fewer ready nodes
+	// than the threshold make it Degraded
+	observeNodes(clu, nodes.Items)
+	if clu.Status.ReadyNodes == 0 {
+		for _, node := range nodes.Items {
+			log.Info("Node not ready", "nodeName", node.Name)
 		}
+		return false, fmt.Sprintf("None of the %d nodes is ready, node %s is not ready", len(nodes.Items), nodes.Items[0].Name), nil
+	}
+	pods := r.podReader(clu, tgtClient)
+	if err := observeCapacity(ctx, pods, clu, nodes.Items); err != nil {
+		return false, "unable to list pods", err
+	}
+	if err := observeComponents(ctx, pods, clu); err != nil {
+		return false, "unable to list kube-system pods", err
 	}
 
-	log.Info("Cluster is healthy", "nodes", len(nodes.Items))
+	log.Info("Cluster is healthy", "nodes", len(nodes.Items), "readyNodes", clu.Status.ReadyNodes)
 
 	return true, "Cluster is healthy", nil
 
@@ -298,7 +463,10 @@ func (r *ClusterReconciler) checkClusterHealth(ctx context.Context, clu *platfor
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&platformv1alpha1.Cluster{}).
+		// every health check writes the status; the next one is paced by RequeueAfter,
+		// not by the update events of those writes
+		For(&platformv1alpha1.Cluster{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.Secret{}).
 		Named("cluster").
 		Complete(r)
 }
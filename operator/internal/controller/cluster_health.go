@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// DefaultReadyNodeThreshold is the percentage of ready nodes under which a cluster is
+// Degraded when ClusterReconciler.ReadyNodeThreshold is not set.
+const DefaultReadyNodeThreshold = 80
+
+// dnsComponent is the name cluster DNS is reported under in Status.Components.
+const dnsComponent = "dns"
+
+// podListPageSize is how many pods observeCapacity reads per request, so large
+// clusters are listed in pages rather than in one response.
+const podListPageSize = 500
+
+// pressureConditions are the node conditions reported in Status.Pressure.
+var pressureConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+}
+
+// readyNodeThreshold returns the percentage of ready nodes under which a cluster is Degraded.
+func (r *ClusterReconciler) readyNodeThreshold() int {
+	if r.ReadyNodeThreshold <= 0 {
+		return DefaultReadyNodeThreshold
+	}
+	return r.ReadyNodeThreshold
+}
+
+// observeNodes records the node counts, the Kubernetes version, the allocatable
+// resources and the pressure conditions of the nodes in the cluster status.
+func observeNodes(clu *platformv1alpha1.Cluster, nodes []corev1.Node) {
+	clu.Status.Nodes = int32(len(nodes))
+	clu.Status.ReadyNodes = 0
+	clu.Status.KubernetesVersion = ""
+	clu.Status.Pressure = nil
+	allocatable := corev1.ResourceList{}
+
+	var oldest *version.Version
+	for i := range nodes {
+		node := &nodes[i]
+		if isNodeReady(node) {
+			clu.Status.ReadyNodes++
+		}
+		addResources(allocatable, cpuAndMemory(node.Status.Allocatable))
+
+		// the oldest kubelet bounds what the cluster supports
+		kubelet := node.Status.NodeInfo.KubeletVersion
+		if v, err := version.ParseGeneric(kubelet); err == nil && (oldest == nil || v.LessThan(oldest)) {
+			oldest = v
+			clu.Status.KubernetesVersion = kubelet
+		}
+
+		var pressure []string
+		for _, condition := range node.Status.Conditions {
+			for _, t := range pressureConditions {
+				if condition.Type == t && condition.Status == corev1.ConditionTrue {
+					pressure = append(pressure, string(t))
+				}
+			}
+		}
+		if len(pressure) > 0 {
+			clu.Status.Pressure = append(clu.Status.Pressure, platformv1alpha1.NodePressure{Node: node.Name, Conditions: pressure})
+		}
+	}
+	clu.Status.Allocatable = allocatable
+}
+
+// observeCapacity records the CPU and memory requested by the pods scheduled on the
+// nodes. Finished pods no longer hold their requests. Pods are read page by page.
+func observeCapacity(ctx context.Context, c client.Reader, clu *platformv1alpha1.Cluster, nodes []corev1.Node) error {
+	onNodes := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		onNodes[node.Name] = true
+	}
+	requested := corev1.ResourceList{}
+	var pods corev1.PodList
+	for {
+		if err := c.List(ctx, &pods, client.Limit(podListPageSize), client.Continue(pods.Continue)); err != nil {
+			return fmt.Errorf("listing pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			if !onNodes[pod.Spec.NodeName] || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			for _, container := range pod.Spec.Containers {
+				addResources(requested, cpuAndMemory(container.Resources.Requests))
+			}
+		}
+		if pods.Continue == "" {
+			break
+		}
+	}
+	clu.Status.Requested = requested
+	return nil
+}
+
+// observeComponents records the health of the control plane components and cluster
+// DNS found in kube-system: a component is healthy when all of its pods are ready.
+func observeComponents(ctx context.Context, c client.Reader, clu *platformv1alpha1.Cluster) error {
+	var controlPlane, dns corev1.PodList
+	if err := c.List(ctx, &controlPlane, client.InNamespace(metav1.NamespaceSystem), client.MatchingLabels{"tier": "control-plane"}); err != nil {
+		return fmt.Errorf("listing control plane pods: %w", err)
+	}
+	if err := c.List(ctx, &dns, client.InNamespace(metav1.NamespaceSystem), client.MatchingLabels{"k8s-app": "kube-dns"}); err != nil {
+		return fmt.Errorf("listing dns pods: %w", err)
+	}
+
+	byComponent := map[string][]corev1.Pod{}
+	for _, pod := range controlPlane.Items {
+		name := pod.Labels["component"]
+		if name == "" {
+			continue
+		}
+		byComponent[name] = append(byComponent[name], pod)
+	}
+	if len(dns.Items) > 0 {
+		byComponent[dnsComponent] = dns.Items
+	}
+
+	names := make([]string, 0, len(byComponent))
+	for name := range byComponent {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := make([]platformv1alpha1.ComponentHealth, 0, len(names))
+	for _, name := range names {
+		health := platformv1alpha1.ComponentHealth{Name: name, Healthy: true}
+		var notReady []string
+		for i := range byComponent[name] {
+			pod := &byComponent[name][i]
+			if !isPodReady(pod) {
+				notReady = append(notReady, pod.Name)
+			}
+		}
+		if len(notReady) > 0 {
+			health.Healthy = false
+			health.Message = "Pods not ready: " + strings.Join(notReady, ", ")
+		}
+		components = append(components, health)
+	}
+	clu.Status.Components = components
+	return nil
+}
+
+// clusterDegraded returns the reason and message of the Degraded condition of a
+// healthy cluster, an empty reason when it is not degraded.
+func clusterDegraded(clu *platformv1alpha1.Cluster, threshold int) (string, string) {
+	if clu.Status.Nodes > 0 && int(clu.Status.ReadyNodes)*100 < threshold*int(clu.Status.Nodes) {
+		return "NodesNotReady", fmt.Sprintf("Only %d of %d nodes are ready, under the %d%% threshold",
+			clu.Status.ReadyNodes, clu.Status.Nodes, threshold)
+	}
+	var unhealthy []string
+	for _, component := range clu.Status.Components {
+		if !component.Healthy {
+			unhealthy = append(unhealthy, component.Name)
+		}
+	}
+	if len(unhealthy) > 0 {
+		return "ComponentUnhealthy", "Unhealthy components: " + strings.Join(unhealthy, ", ")
+	}
+	return "", ""
+}
+
+// cpuAndMemory returns the CPU and memory of list, the resources reported in the status.
+func cpuAndMemory(list corev1.ResourceList) corev1.ResourceList {
+	out := corev1.ResourceList{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if q, ok := list[name]; ok {
+			out[name] = q
+		}
+	}
+	return out
+}
+
+// isPodReady reports whether the pod's Ready condition is True.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
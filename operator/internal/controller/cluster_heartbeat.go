@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// Defaults of the health check settings of ClusterReconciler.
+const (
+	DefaultHealthCheckInterval = time.Minute
+	DefaultHealthCheckJitter   = 0.1
+	DefaultHeartbeatTimeout    = 5 * time.Minute
+)
+
+// healthCheckRequeue is when a cluster is health-checked again: the interval stretched
+// by up to the jitter, so clusters added together are not all checked at once.
+func (r *ClusterReconciler) healthCheckRequeue() time.Duration {
+	interval := r.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	jitter := r.HealthCheckJitter
+	if jitter <= 0 {
+		jitter = DefaultHealthCheckJitter
+	}
+	return wait.Jitter(interval, jitter)
+}
+
+func (r *ClusterReconciler) heartbeatTimeout() time.Duration {
+	if r.HeartbeatTimeout <= 0 {
+		return DefaultHeartbeatTimeout
+	}
+	return r.HeartbeatTimeout
+}
+
+// reportUnreachable sets the conditions of a cluster its health check could not reach.
+// Within the heartbeat timeout of the last check that reached it, Ready is left as is
+// to ride out blips; once the timeout has passed, it turns Unknown. A cluster that was
+// never reached is not Ready.
+func (r *ClusterReconciler) reportUnreachable(clu *platformv1alpha1.Cluster, msg string) {
+	apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.Error,
+		Status:             metav1.ConditionTrue,
+		Reason:             "HealthCheckFailed",
+		Message:            msg,
+		ObservedGeneration: clu.GetGeneration(),
+	})
+	last := clu.Status.LastHeartbeatTime
+	switch {
+	case last == nil:
+		apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Ready,
+			Status:             metav1.ConditionFalse,
+			Reason:             "HealthCheckFailed",
+			Message:            "Health probe failed: " + msg,
+			ObservedGeneration: clu.GetGeneration(),
+		})
+	case time.Since(last.Time) >= r.heartbeatTimeout():
+		apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Ready,
+			Status:             metav1.ConditionUnknown,
+			Reason:             "HeartbeatMissed",
+			Message:            fmt.Sprintf("No heartbeat since %s: %s", last.UTC().Format(time.RFC3339), msg),
+			ObservedGeneration: clu.GetGeneration(),
+		})
+	}
+}
+
+// clusterUnreachable reports whether the cluster has missed its heartbeat, with the
+// message of its Ready condition.
+func clusterUnreachable(clu *platformv1alpha1.Cluster) (bool, string) {
+	ready := apimeta.FindStatusCondition(clu.Status.Conditions, platformv1alpha1.Ready)
+	if ready == nil || ready.Status != metav1.ConditionUnknown {
+		return false, ""
+	}
+	return true, ready.Message
+}
+
+// clusterReadinessChanged passes the updates of Clusters whose Ready condition changed
+// status, leaving out the heartbeats recorded by every health check.
+var clusterReadinessChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldClu, ok := e.ObjectOld.(*platformv1alpha1.Cluster)
+		if !ok {
+			return false
+		}
+		newClu, ok := e.ObjectNew.(*platformv1alpha1.Cluster)
+		if !ok {
+			return false
+		}
+		return readyStatus(oldClu) != readyStatus(newClu)
+	},
+}
+
+func readyStatus(clu *platformv1alpha1.Cluster) metav1.ConditionStatus {
+	if ready := apimeta.FindStatusCondition(clu.Status.Conditions, platformv1alpha1.Ready); ready != nil {
+		return ready.Status
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/clusterprovider"
+)
+
+// reconcileNodePools compares the node pools of the spec with the nodes of the target
+// cluster, grouped by their NodePoolLabel. The labels and taints of each pool are
+// applied to its nodes, and its size is reported in Status.NodePools. A pool that is
+// not at its desired size is scaled when the cluster has a provider that can.
+func (r *ClusterReconciler) reconcileNodePools(ctx context.Context, clu *platformv1alpha1.Cluster, tgtClient client.Client) error {
+	log := logf.FromContext(ctx)
+
+	var nodes corev1.NodeList
+	if err := tgtClient.List(ctx, &nodes); err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+	provider, provisioned := r.providers().Get(clu.Spec.Type)
+
+	statuses := make([]platformv1alpha1.NodePoolStatus, 0, len(clu.Spec.NodePools))
+	for _, pool := range clu.Spec.NodePools {
+		status := platformv1alpha1.NodePoolStatus{Name: pool.Name}
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			if node.Labels[platformv1alpha1.NodePoolLabel] != pool.Name {
+				continue
+			}
+			status.Nodes++
+			if isNodeReady(node) {
+				status.ReadyNodes++
+			}
+			if err := applyNodePool(ctx, tgtClient, node, pool); err != nil {
+				return fmt.Errorf("applying node pool %s to node %s: %w", pool.Name, node.Name, err)
+			}
+		}
+		status.Desired = desiredPoolSize(pool, status.Nodes)
+
+		if status.Nodes != status.Desired {
+			status.Message = fmt.Sprintf("Pool has %d of %d nodes", status.Nodes, status.Desired)
+			if provisioned {
+				err := provider.ScaleNodePool(ctx, clu, pool.Name, status.Desired)
+				switch {
+				case errors.Is(err, clusterprovider.ErrScalingNotSupported):
+					status.Message += ", the " + provider.Name() + " provider cannot scale it"
+				case err != nil:
+					log.Error(err, "Error scaling node pool", "pool", pool.Name, "size", status.Desired)
+					status.Message += ", scaling failed: " + err.Error()
+				default:
+					status.Message += fmt.Sprintf(", scaling to %d", status.Desired)
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	clu.Status.NodePools = statuses
+	return nil
+}
+
+// desiredPoolSize is the size a pool should have with the given number of nodes:
+// spec desired when set, otherwise the size left to the autoscaler within its bounds.
+func desiredPoolSize(pool platformv1alpha1.NodePool, nodes int32) int32 {
+	if pool.Desired != nil {
+		return *pool.Desired
+	}
+	desired := max(nodes, pool.MinSize)
+	if pool.MaxSize > 0 {
+		desired = min(desired, pool.MaxSize)
+	}
+	return desired
+}
+
+// applyNodePool adds the labels and taints of the pool to one of its nodes. Taints are
+// matched by key and effect; labels and taints not declared by the pool are left alone.
+func applyNodePool(ctx context.Context, c client.Client, node *corev1.Node, pool platformv1alpha1.NodePool) error {
+	patch := client.MergeFrom(node.DeepCopy())
+	changed := false
+	for k, v := range pool.Labels {
+		if value, ok := node.Labels[k]; !ok || value != v {
+			node.Labels[k] = v
+			changed = true
+		}
+	}
+	for _, taint := range pool.Taints {
+		found := false
+		for i := range node.Spec.Taints {
+			existing := &node.Spec.Taints[i]
+			if existing.Key != taint.Key || existing.Effect != taint.Effect {
+				continue
+			}
+			found = true
+			if existing.Value != taint.Value {
+				existing.Value = taint.Value
+				changed = true
+			}
+		}
+		if !found {
+			node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: taint.Key, Value: taint.Value, Effect: taint.Effect})
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return c.Patch(ctx, node, patch)
+}
+
+// isNodeReady reports whether the node's Ready condition is True.
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/clusterprovider"
+	"github.com/mofe64/vulkan/operator/internal/utils"
+)
+
+// provisioningRequeue is how often a cluster being provisioned or deleted by its
+// provider is looked at again.
+const provisioningRequeue = 15 * time.Second
+
+// defaultProviders backs ClusterReconciler.Providers when it is not set.
+var defaultProviders = clusterprovider.Default()
+
+// providers returns the cluster provider registry, the built-in one unless overridden.
+func (r *ClusterReconciler) providers() *clusterprovider.Registry {
+	if r.Providers != nil {
+		return r.Providers
+	}
+	return defaultProviders
+}
+
+// reconcileProvisioning drives the provisioning of a cluster by its provider, reporting
+// it through the Provisioning condition. Once the provider reports the cluster ready,
+// its kubeconfig is written to the kubeconfig Secret and true is returned: the cluster
+// is then health-checked like any other.
+//
+// The finalizer is added before anything is provisioned, so the cluster is torn down
+// when the CR is deleted.
+func (r *ClusterReconciler) reconcileProvisioning(ctx context.Context, clu *platformv1alpha1.Cluster, provider clusterprovider.ClusterProvider) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	if !utils.ContainsString(clu.Finalizers, platformv1alpha1.ClusterFinalizer) || clu.Spec.KubeconfigSecretName == "" {
+		status := clu.Status
+		if !utils.ContainsString(clu.Finalizers, platformv1alpha1.ClusterFinalizer) {
+			clu.Finalizers = append(clu.Finalizers, platformv1alpha1.ClusterFinalizer)
+		}
+		if clu.Spec.KubeconfigSecretName == "" {
+			clu.Spec.KubeconfigSecretName = clu.Name + "-kubeconfig"
+		}
+		if clu.Spec.KubeconfigSecretNamespace == "" {
+			clu.Spec.KubeconfigSecretNamespace = "default"
+		}
+		if err := r.Update(ctx, clu); err != nil {
+			return false, err
+		}
+		clu.Status = status
+	}
+
+	state, err := provider.Create(ctx, clu)
+	if err != nil {
+		return false, err
+	}
+	if !state.Ready {
+		apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Provisioning,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Provisioning",
+			Message:            state.Message,
+			ObservedGeneration: clu.GetGeneration(),
+		})
+		apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+			Type:               platformv1alpha1.Ready,
+			Status:             metav1.ConditionFalse,
+			Reason:             "Provisioning",
+			Message:            "Cluster is being provisioned by the " + provider.Name() + " provider",
+			ObservedGeneration: clu.GetGeneration(),
+		})
+		return false, nil
+	}
+
+	kubeconfig, err := provider.Kubeconfig(ctx, clu)
+	if err != nil {
+		return false, err
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return false, err
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      clu.Spec.KubeconfigSecretName,
+		Namespace: clu.Spec.KubeconfigSecretNamespace,
+	}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Data = map[string][]byte{"kubeconfig": kubeconfig}
+		return ctrl.SetControllerReference(clu, secret, r.Scheme)
+	})
+	if err != nil {
+		return false, err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("Kubeconfig of provisioned cluster stored", "secret", secret.Name, "operation", op)
+	}
+
+	clu.Status.Endpoint = cfg.Host
+	apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.Provisioning,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Provisioned",
+		Message:            state.Message,
+		ObservedGeneration: clu.GetGeneration(),
+	})
+	return true, nil
+}
+
+// deprovision tears down a cluster provisioned by its provider, reporting whether it is
+// gone. The kubeconfig Secret is owned by the Cluster and garbage collected with it.
+func (r *ClusterReconciler) deprovision(ctx context.Context, clu *platformv1alpha1.Cluster, provider clusterprovider.ClusterProvider) (bool, error) {
+	done, err := provider.Delete(ctx, clu)
+	if err != nil || done {
+		return done, err
+	}
+	apimeta.SetStatusCondition(&clu.Status.Conditions, metav1.Condition{
+		Type:               platformv1alpha1.Deleting,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Deprovisioning",
+		Message:            "Cluster is being deleted by the " + provider.Name() + " provider",
+		ObservedGeneration: clu.GetGeneration(),
+	})
+	return false, utils.UpdateClusterStatusWithRetry(ctx, r.Client, clu)
+}
@@ -16,14 +16,26 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
 	"github.com/mofe64/vulkan/operator/internal/model"
 	"github.com/mofe64/vulkan/operator/internal/utils"
 )
 
+// Container defaults of the LimitRange created in project namespaces, for containers
+// that do not declare their own requests and limits.
+const (
+	defaultContainerCPURequest    = "100m"
+	defaultContainerMemoryRequest = "128Mi"
+	defaultContainerCPULimit      = "500m"
+	defaultContainerMemoryLimit   = "512Mi"
+)
+
 // ProjectClusterBindingReconciler reconciles a ProjectClusterBinding object
 type ProjectClusterBindingReconciler struct {
 	client.Client
@@ -35,6 +47,7 @@ type ProjectClusterBindingReconciler struct {
 // +kubebuilder:rbac:groups=platform.platform.io,resources=projectclusterbindings,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=platform.platform.io,resources=projectclusterbindings/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=platform.platform.io,resources=projectclusterbindings/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=limitranges,verbs=get;list;watch;create
 // todo: deletion and finalizer logic
 func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
@@ -113,6 +126,23 @@ func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctr
 			return ctrl.Result{}, err
 		}
 	}
+	// a cluster that missed its heartbeat cannot be set up, the binding is looked at
+	// again once the cluster is back
+	if unreachable, msg := clusterUnreachable(&clu); unreachable {
+		apimeta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
+			Type:    platformv1alpha1.ClusterUnreachable,
+			Status:  metav1.ConditionTrue,
+			Reason:  "HeartbeatMissed",
+			Message: "Cluster " + clu.Name + " is unreachable: " + msg,
+		})
+		if err := r.Status().Update(ctx, &binding); err != nil {
+			log.Error(err, "Failed to update status", "binding", binding.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	apimeta.RemoveStatusCondition(&binding.Status.Conditions, platformv1alpha1.ClusterUnreachable)
+
 	var k8sClient client.Client
 	var err error
 	if clu.Spec.Type != "attached" {
@@ -160,9 +190,9 @@ func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctr
 	}
 
 	if err := utils.AddLabelsToNamespace(ctx, k8sClient, ns, map[string]string{
-		"vulkan.io/project":   proj.Name,
-		"vulkan.io/projectID": proj.Spec.ProjectID,
-		"vulkan.io/org":       proj.Spec.OrgRef,
+		platformv1alpha1.ProjectLabel: proj.Name,
+		"vulkan.io/projectID":         proj.Spec.ProjectID,
+		platformv1alpha1.OrgLabel:     proj.Spec.OrgRef,
 	}); err != nil {
 		apimeta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
 			Type:    platformv1alpha1.Error,
@@ -203,7 +233,7 @@ func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctr
 		},
 	}
 
-	if err := k8sClient.Create(ctx, quota); err != nil {
+	if err := k8sClient.Create(ctx, quota); client.IgnoreAlreadyExists(err) != nil {
 		apimeta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
 			Type:    platformv1alpha1.Error,
 			Status:  metav1.ConditionTrue,
@@ -224,6 +254,49 @@ func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{}, err
 	}
 
+	// create a limit range next to the quota, so containers that declare no requests or
+	// limits get defaults instead of being rejected by the quota
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("limits-%s", proj.Name),
+			Namespace: ns,
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type: corev1.LimitTypeContainer,
+				Default: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(defaultContainerCPULimit),
+					corev1.ResourceMemory: resource.MustParse(defaultContainerMemoryLimit),
+				},
+				DefaultRequest: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(defaultContainerCPURequest),
+					corev1.ResourceMemory: resource.MustParse(defaultContainerMemoryRequest),
+				},
+			}},
+		},
+	}
+
+	if err := k8sClient.Create(ctx, limitRange); client.IgnoreAlreadyExists(err) != nil {
+		apimeta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
+			Type:    platformv1alpha1.Error,
+			Status:  metav1.ConditionTrue,
+			Reason:  "LimitRangeCreationError",
+			Message: err.Error(),
+		})
+		apimeta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
+			Type:    platformv1alpha1.Ready,
+			Status:  metav1.ConditionFalse,
+			Reason:  "LimitRangeCreationError",
+			Message: err.Error(),
+		})
+		err = r.Status().Update(ctx, &binding)
+		if err != nil {
+			log.Error(err, "Failed to update status", "binding", binding.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, err
+	}
+
 	// create network policy
 	networkPolicy := &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -239,7 +312,7 @@ func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctr
 		},
 	}
 
-	if err := k8sClient.Create(ctx, networkPolicy); err != nil {
+	if err := k8sClient.Create(ctx, networkPolicy); client.IgnoreAlreadyExists(err) != nil {
 		apimeta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
 			Type:    platformv1alpha1.Error,
 			Status:  metav1.ConditionTrue,
@@ -345,10 +418,30 @@ func (r *ProjectClusterBindingReconciler) Reconcile(ctx context.Context, req ctr
 	return ctrl.Result{}, nil
 }
 
+// bindingsForCluster maps a Cluster to the bindings of projects to it.
+func (r *ProjectClusterBindingReconciler) bindingsForCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	var bindings platformv1alpha1.ProjectClusterBindingList
+	if err := r.List(ctx, &bindings); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list bindings of cluster", "cluster", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, binding := range bindings.Items {
+		if binding.Spec.ClusterRef == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&binding)})
+		}
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ProjectClusterBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&platformv1alpha1.ProjectClusterBinding{}).
+		// bindings follow their cluster going unreachable and coming back
+		Watches(&platformv1alpha1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(r.bindingsForCluster),
+			builder.WithPredicates(clusterReadinessChanged)).
 		Named("projectclusterbinding").
 		Complete(r)
 }
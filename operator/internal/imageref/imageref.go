@@ -0,0 +1,343 @@
+// Package imageref resolves image references by tag to immutable references by
+// digest through the Docker Registry HTTP API V2 (also served by OCI registries).
+package imageref
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	dockerHubDomain = "docker.io"
+	dockerHubAPI    = "registry-1.docker.io"
+)
+
+// manifestMediaTypes are accepted when fetching a manifest, index types first so a
+// multi-arch tag resolves to the digest of its index like `docker pull` does.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Reference is a parsed image reference.
+type Reference struct {
+	// Domain is the registry host (and port), docker.io for Docker Hub.
+	Domain string
+	// Repository is the path within the registry, e.g. library/nginx.
+	Repository string
+	// Tag is the tag, "latest" when neither a tag nor a digest was given.
+	Tag string
+	// Digest is set when the reference is already pinned.
+	Digest string
+
+	// name is the reference as written, without tag or digest.
+	name string
+}
+
+// Parse splits an image reference such as ghcr.io/org/app:v1, nginx or
+// localhost:5000/app@sha256:... into its parts.
+func Parse(ref string) (Reference, error) {
+	if ref == "" {
+		return Reference{}, errors.New("empty image reference")
+	}
+	var r Reference
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		r.Digest = name[i+1:]
+		name = name[:i]
+		if !strings.HasPrefix(r.Digest, "sha256:") {
+			return Reference{}, fmt.Errorf("unsupported digest in %q", ref)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		r.Tag = name[i+1:]
+		name = name[:i]
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	if name == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+	r.name = name
+
+	// the first component is a registry host only if it looks like one
+	r.Domain, r.Repository = dockerHubDomain, name
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			r.Domain, r.Repository = first, name[i+1:]
+		}
+	}
+	if r.Domain == dockerHubDomain && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	return r, nil
+}
+
+// Name returns the reference as written, without tag or digest.
+func (r Reference) Name() string { return r.name }
+
+// Pinned returns the reference by digest.
+func (r Reference) Pinned(digest string) string { return r.name + "@" + digest }
+
+// Credentials authenticate against a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Keychain maps registry domains to credentials, typically read from a
+// kubernetes.io/dockerconfigjson Secret (see KeychainFromDockerConfig).
+type Keychain map[string]Credentials
+
+// KeychainFromDockerConfig parses the contents of a .dockerconfigjson
+// (or legacy .dockercfg) Secret key.
+func KeychainFromDockerConfig(data []byte) (Keychain, error) {
+	var cfg struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse docker config: %w", err)
+	}
+	if cfg.Auths == nil {
+		// .dockercfg has the auths at the top level
+		if err := json.Unmarshal(data, &cfg.Auths); err != nil {
+			return nil, fmt.Errorf("parse docker config: %w", err)
+		}
+	}
+
+	kc := Keychain{}
+	for server, entry := range cfg.Auths {
+		creds := Credentials{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			raw, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("decode auth for %s: %w", server, err)
+			}
+			user, pass, _ := strings.Cut(string(raw), ":")
+			creds = Credentials{Username: user, Password: pass}
+		}
+		kc[normalizeServer(server)] = creds
+	}
+	return kc, nil
+}
+
+// normalizeServer maps the server keys found in docker configs
+// (https://index.docker.io/v1/, ghcr.io, https://ghcr.io) to registry domains.
+func normalizeServer(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		server = u.Host
+	}
+	server = strings.TrimSuffix(server, "/")
+	switch server {
+	case "index.docker.io", dockerHubAPI:
+		return dockerHubDomain
+	}
+	return server
+}
+
+// Resolver resolves tags to digests.
+type Resolver interface {
+	// Resolve returns ref pinned by digest. References that are already pinned are
+	// returned unchanged without contacting the registry.
+	Resolve(ctx context.Context, ref string, keychain Keychain) (string, error)
+}
+
+// HTTPResolver talks to registries over HTTP. Like the Docker daemon it uses plain
+// HTTP for loopback registries (localhost, 127.0.0.0/8, ::1) and HTTPS otherwise.
+type HTTPResolver struct {
+	Client *http.Client
+}
+
+// NewResolver returns an HTTPResolver using the given client, http.DefaultClient when nil.
+func NewResolver(c *http.Client) *HTTPResolver {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &HTTPResolver{Client: c}
+}
+
+func (h *HTTPResolver) Resolve(ctx context.Context, ref string, keychain Keychain) (string, error) {
+	r, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.Digest != "" {
+		return ref, nil
+	}
+
+	api := r.Domain
+	if api == dockerHubDomain {
+		api = dockerHubAPI
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme(r.Domain), api, r.Repository, r.Tag)
+	creds, hasCreds := keychain[r.Domain]
+
+	// an anonymous attempt first, registries answer with the auth challenge to satisfy
+	resp, err := h.manifest(ctx, http.MethodHead, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		authz, err := h.authorize(ctx, challenge, r.Repository, creds, hasCreds)
+		if err != nil {
+			return "", err
+		}
+		if resp, err = h.manifest(ctx, http.MethodHead, manifestURL, authz); err != nil {
+			return "", err
+		}
+		// some registries do not return the digest on HEAD, fall back to hashing the manifest
+		if resp.StatusCode == http.StatusOK && resp.Header.Get("Docker-Content-Digest") == "" {
+			_ = resp.Body.Close()
+			if resp, err = h.manifest(ctx, http.MethodGet, manifestURL, authz); err != nil {
+				return "", err
+			}
+		}
+	} else if resp.StatusCode == http.StatusOK && resp.Header.Get("Docker-Content-Digest") == "" {
+		_ = resp.Body.Close()
+		if resp, err = h.manifest(ctx, http.MethodGet, manifestURL, ""); err != nil {
+			return "", err
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("image %s not found", ref)
+	default:
+		return "", fmt.Errorf("resolve %s: registry returned %s", ref, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("read manifest of %s: %w", ref, err)
+		}
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("resolve %s: unsupported digest %q", ref, digest)
+	}
+	return r.Pinned(digest), nil
+}
+
+func (h *HTTPResolver) manifest(ctx context.Context, method, manifestURL, authz string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authz != "" {
+		req.Header.Set("Authorization", authz)
+	}
+	return h.Client.Do(req)
+}
+
+// authorize answers a WWW-Authenticate challenge with either basic credentials or a
+// bearer token fetched from the challenge realm, and returns the Authorization header.
+func (h *HTTPResolver) authorize(ctx context.Context, challenge, repository string, creds Credentials, hasCreds bool) (string, error) {
+	kind, params := parseChallenge(challenge)
+	switch strings.ToLower(kind) {
+	case "basic":
+		if !hasCreds {
+			return "", errors.New("registry requires credentials, set a pull secret")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid bearer realm in %q", challenge)
+	}
+	q := realm.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	q.Set("scope", "repository:"+repository+":pull")
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCreds {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token request returned %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", errors.New("registry returned an empty token")
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge splits `Bearer realm="...",service="..."` into its scheme and params.
+func parseChallenge(challenge string) (string, map[string]string) {
+	kind, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(key)] = strings.Trim(value, `"`)
+	}
+	return kind, params
+}
+
+// scheme returns http for loopback registries and https otherwise.
+func scheme(domain string) string {
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return "http"
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http"
+	}
+	return "https"
+}
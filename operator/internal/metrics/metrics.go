@@ -62,12 +62,11 @@ func init() {
 	metrics.Registry.MustRegister(ClustersPerOrg, ProjectsPerOrg, ApplicationsPerOrg, OrgQuotaUsage)
 }
 
-func IncClusters(org string)     { ClustersPerOrg.WithLabelValues(org).Inc() }
-func DecClusters(org string)     { ClustersPerOrg.WithLabelValues(org).Dec() }
-func IncProjects(org string)     { ProjectsPerOrg.WithLabelValues(org).Inc() }
-func DecProjects(org string)     { ProjectsPerOrg.WithLabelValues(org).Dec() }
-func IncApplications(org string) { ApplicationsPerOrg.WithLabelValues(org).Inc() }
-func DecApplications(org string) { ApplicationsPerOrg.WithLabelValues(org).Dec() }
+func SetClusters(org string, n int) { ClustersPerOrg.WithLabelValues(org).Set(float64(n)) }
+func IncProjects(org string)        { ProjectsPerOrg.WithLabelValues(org).Inc() }
+func DecProjects(org string)        { ProjectsPerOrg.WithLabelValues(org).Dec() }
+func IncApplications(org string)    { ApplicationsPerOrg.WithLabelValues(org).Inc() }
+func DecApplications(org string)    { ApplicationsPerOrg.WithLabelValues(org).Dec() }
 func UpdateQuotaUsage(org string, resourceType string, usage float64) {
 	OrgQuotaUsage.WithLabelValues(org, resourceType).Set(usage)
 }
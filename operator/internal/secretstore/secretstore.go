@@ -0,0 +1,55 @@
+// Package secretstore reads the platform-managed secrets of Applications, written
+// encrypted to Postgres by the platform API.
+package secretstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mofe64/vulkan/operator/pkg/secretbox"
+)
+
+// Store returns the decrypted secrets of an Application by name.
+type Store interface {
+	Values(ctx context.Context, namespace, app string) (map[string][]byte, error)
+}
+
+// SQL is a Store backed by the app_secrets table of the platform database.
+type SQL struct {
+	db  *sql.DB
+	key []byte
+}
+
+// NewSQL returns a Store reading db and opening values with key.
+func NewSQL(db *sql.DB, key []byte) *SQL {
+	return &SQL{db: db, key: key}
+}
+
+func (s *SQL) Values(ctx context.Context, namespace, app string) (map[string][]byte, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, value
+		FROM app_secrets
+		WHERE namespace = $1 AND app = $2
+	`, namespace, app)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secrets: %w", err)
+	}
+	defer rows.Close()
+
+	values := map[string][]byte{}
+	for rows.Next() {
+		var name string
+		var sealed []byte
+		if err := rows.Scan(&name, &sealed); err != nil {
+			return nil, err
+		}
+		value, err := secretbox.Open(s.key, sealed, secretbox.AdditionalData(namespace, app, name))
+		if err != nil {
+			// never include the value, the name is enough to find the broken row
+			return nil, fmt.Errorf("secret %s: %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, rows.Err()
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -17,10 +18,13 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/retry"
 	_ "modernc.org/sqlite"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // TargetClientFactory converts a Cluster CRD into a controller-runtime client
@@ -30,25 +34,124 @@ type TargetClientFactory interface {
 	ClientFor(ctx context.Context, clu *platformv1alpha1.Cluster) (client.Client, error)
 }
 
+// TargetClientEvicter is implemented by TargetClientFactories that keep the clients
+// they build. Evict drops the client of a cluster, once its CR is gone.
+type TargetClientEvicter interface {
+	Evict(cluster string)
+}
+
+// TargetCacheFactory is implemented by TargetClientFactories that can also hand out an
+// informer cache of a cluster, for controllers to watch resources in it. The cache is
+// started on first use and stopped when the client of the cluster is evicted.
+type TargetCacheFactory interface {
+	CacheFor(ctx context.Context, clu *platformv1alpha1.Cluster) (cache.Cache, error)
+}
+
+// targetClientFactory builds the client of a cluster once and hands it out until the
+// kubeconfig Secret changes or the cluster is recreated: clients hold a REST mapper
+// and TLS connections that are costly to rebuild on every reconcile.
 type targetClientFactory struct {
 	CP client.Client
+
+	mu sync.Mutex
+	// targets are the clients built so far, by cluster name.
+	targets map[string]*target
+}
+
+// target is the client of a cluster, and the kubeconfig it was built from.
+type target struct {
+	uid             types.UID
+	secret          types.NamespacedName
+	resourceVersion string
+	config          *rest.Config
+	client          client.Client
+
+	// cache is the informer cache of the cluster, once asked for, running until stop.
+	cache cache.Cache
+	stop  context.CancelFunc
 }
 
 func NewTargetClientFactory(cp client.Client) TargetClientFactory {
-	return &targetClientFactory{CP: cp}
+	return &targetClientFactory{CP: cp, targets: map[string]*target{}}
 }
 
-// ClientFor reads clu.Spec.KubeconfigSecret, builds rest.Config, returns a client.
+// ClientFor reads clu.Spec.KubeconfigSecret and returns the client built from it,
+// building a new one when the Secret or the cluster changed since the last call.
 func (f *targetClientFactory) ClientFor(ctx context.Context, clu *platformv1alpha1.Cluster) (client.Client, error) {
+	t, err := f.targetFor(ctx, clu)
+	if err != nil {
+		return nil, err
+	}
+	return t.client, nil
+}
+
+// CacheFor returns the informer cache of the cluster, starting it on first use.
+func (f *targetClientFactory) CacheFor(ctx context.Context, clu *platformv1alpha1.Cluster) (cache.Cache, error) {
+	t, err := f.targetFor(ctx, clu)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.targets[clu.Name] != t {
+		return nil, fmt.Errorf("client of cluster %s was evicted", clu.Name)
+	}
+	if t.cache != nil {
+		return t.cache, nil
+	}
+	c, err := cache.New(t.config, cache.Options{Scheme: f.CP.Scheme()})
+	if err != nil {
+		return nil, err
+	}
+	// the cache outlives the reconcile asking for it, until the client is evicted
+	cacheCtx, stop := context.WithCancel(context.Background())
+	go func() {
+		if err := c.Start(cacheCtx); err != nil {
+			logf.Log.Error(err, "Informer cache of cluster stopped", "cluster", clu.Name)
+		}
+	}()
+	t.cache, t.stop = c, stop
+	return c, nil
+}
+
+// Evict drops the client and stops the informer cache of the cluster.
+func (f *targetClientFactory) Evict(cluster string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.evictLocked(cluster)
+}
+
+func (f *targetClientFactory) evictLocked(cluster string) {
+	t, ok := f.targets[cluster]
+	if !ok {
+		return
+	}
+	if t.stop != nil {
+		t.stop()
+	}
+	delete(f.targets, cluster)
+}
+
+// targetFor returns the target of the cluster, built from its kubeconfig Secret. The
+// Secret is read through the control plane client, from the manager cache, so telling
+// whether it changed is cheap.
+func (f *targetClientFactory) targetFor(ctx context.Context, clu *platformv1alpha1.Cluster) (*target, error) {
 	// load cluster Secret that holds kubeconfig YAML
+	key := types.NamespacedName{Namespace: clu.Spec.KubeconfigSecretNamespace, Name: clu.Spec.KubeconfigSecretName}
 	var sec corev1.Secret
-	err := f.CP.Get(ctx,
-		client.ObjectKey{Namespace: clu.Spec.KubeconfigSecretNamespace, Name: clu.Spec.KubeconfigSecretName},
-		&sec)
-	if err != nil {
+	if err := f.CP.Get(ctx, key, &sec); err != nil {
 		return nil, err
 	}
 
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.targets[clu.Name]; ok {
+		if t.uid == clu.UID && t.secret == key && t.resourceVersion == sec.ResourceVersion {
+			return t, nil
+		}
+		f.evictLocked(clu.Name)
+	}
+
 	// build rest.Config from kubeconfig bytes
 	kubeYAML := sec.Data["kubeconfig"]
 	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeYAML)
@@ -58,7 +161,13 @@ func (f *targetClientFactory) ClientFor(ctx context.Context, clu *platformv1alph
 	cfg.QPS, cfg.Burst = 200, 400 // optional tuning
 
 	// ceate a typed client with the same scheme
-	return client.New(cfg, client.Options{Scheme: f.CP.Scheme()})
+	c, err := client.New(cfg, client.Options{Scheme: f.CP.Scheme()})
+	if err != nil {
+		return nil, err
+	}
+	t := &target{uid: clu.UID, secret: key, resourceVersion: sec.ResourceVersion, config: cfg, client: c}
+	f.targets[clu.Name] = t
+	return t, nil
 }
 
 func EnsureNamespace(ctx context.Context, c client.Client, name, display string) error {
@@ -188,6 +297,24 @@ func UpdateClusterStatusWithRetry(
 	})
 }
 
+// UpdateApplicationStatusWithRetry is the Application counterpart of
+// UpdateClusterStatusWithRetry: it re-gets the object, copies the desired
+// Status onto it and retries on 409 Conflict.
+func UpdateApplicationStatusWithRetry(
+	ctx context.Context,
+	c client.Client,
+	desired *platformv1alpha1.Application,
+) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var current platformv1alpha1.Application
+		if err := c.Get(ctx, client.ObjectKeyFromObject(desired), &current); err != nil {
+			return err
+		}
+		current.Status = desired.Status
+		return c.Status().Update(ctx, &current)
+	})
+}
+
 func ConnectDB(raw string) (*sql.DB, error) {
 	u, err := url.Parse(raw)
 	if err != nil {
@@ -0,0 +1,79 @@
+// Package secretbox encrypts the platform-managed secrets of Applications at rest. The
+// API seals values before storing them in Postgres and the operator opens them to sync
+// them into the cluster, so both must be configured with the same key.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of the AES-256 key secrets are sealed with.
+const KeySize = 32
+
+var (
+	ErrInvalidKey = errors.New("secrets key must be 32 bytes encoded in base64")
+	ErrCorrupt    = errors.New("sealed secret cannot be opened with this key")
+)
+
+// ParseKey decodes a base64 encoded key, as generated by `openssl rand -base64 32`.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// AdditionalData binds a sealed value to the secret it belongs to, so a value copied to
+// another row of the store cannot be opened.
+func AdditionalData(namespace, app, name string) []byte {
+	return []byte(namespace + "/" + app + "/" + name)
+}
+
+// Seal encrypts plaintext with AES-256-GCM, returning the random nonce followed by the
+// ciphertext.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts a value sealed by Seal with the same key and additional data.
+func Open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
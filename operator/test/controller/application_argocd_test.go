@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+var _ = Describe("Application Argo CD backend", func() {
+	const image = "ghcr.io/example/app@sha256:aaaa"
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	reconcileApp := func(r reconcile.Reconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getApp := func(c client.Client, app *platformv1alpha1.Application) *platformv1alpha1.Application {
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		return &got
+	}
+
+	argoApp := func(c client.Client, name string) *unstructured.Unstructured {
+		argo := &unstructured.Unstructured{}
+		argo.SetGroupVersionKind(schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application"})
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: "argocd", Name: name}, argo)).To(Succeed())
+		return argo
+	}
+
+	// reportStatus sets the sync and health Argo CD reports on one of its Applications.
+	reportStatus := func(c client.Client, name, sync, health string) {
+		argo := argoApp(c, name)
+		Expect(unstructured.SetNestedMap(argo.Object, map[string]any{
+			"sync":   map[string]any{"status": sync, "revision": "abc123"},
+			"health": map[string]any{"status": health},
+		}, "status")).To(Succeed())
+		Expect(c.Update(ctx, argo)).To(Succeed())
+	}
+
+	argoCD := func(app *platformv1alpha1.Application) {
+		app.Status.Image = image
+		app.Spec.Deploy.Backend = platformv1alpha1.DeployBackendArgoCD
+	}
+
+	It("syncs the GitOps path through an Argo CD Application and mirrors its health", func() {
+		app := makeApplication(ns)
+		argoCD(app)
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		name := ns + "-" + app.Name
+		argo := argoApp(c, name)
+		Expect(argo.GetLabels()).To(HaveKeyWithValue(platformv1alpha1.ApplicationLabel, app.Name))
+		Expect(argo.GetLabels()).To(HaveKeyWithValue(platformv1alpha1.ApplicationNamespaceLabel, ns))
+		Expect(argo.GetFinalizers()).To(ContainElement("resources-finalizer.argocd.argoproj.io"))
+		path, _, _ := unstructured.NestedString(argo.Object, "spec", "source", "path")
+		Expect(path).To(Equal("apps/" + app.Name))
+		repoURL, _, _ := unstructured.NestedString(argo.Object, "spec", "source", "repoURL")
+		Expect(repoURL).NotTo(BeEmpty())
+		server, _, _ := unstructured.NestedString(argo.Object, "spec", "destination", "server")
+		Expect(server).To(Equal("https://kubernetes.default.svc"))
+		namespace, _, _ := unstructured.NestedString(argo.Object, "spec", "destination", "namespace")
+		Expect(namespace).To(Equal(ns))
+		selfHeal, _, _ := unstructured.NestedBool(argo.Object, "spec", "syncPolicy", "automated", "selfHeal")
+		Expect(selfHeal).To(BeTrue())
+
+		// the operator does not render the workload itself
+		err := c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		got := getApp(c, app)
+		Expect(got.Finalizers).To(ContainElement(platformv1alpha1.ArgoCDFinalizer))
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthProgressing))
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready).Reason).To(Equal("ArgoCDPending"))
+
+		reportStatus(c, name, "Synced", "Healthy")
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthHealthy))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+		Expect(got.Status.ArgoCD).To(HaveLen(1))
+		Expect(got.Status.ArgoCD[0].Revision).To(Equal("abc123"))
+
+		reportStatus(c, name, "Synced", "Degraded")
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthError))
+		ready := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal("Degraded"))
+	})
+
+	It("refuses rollbacks and keeps following the GitOps repository", func() {
+		app := makeApplication(ns)
+		argoCD(app)
+		app.Annotations = map[string]string{platformv1alpha1.RollbackToAnnotation: "ghcr.io/example/app@sha256:0000"}
+		app.Status.Deployments = []platformv1alpha1.DeploymentRecord{{Image: "ghcr.io/example/app@sha256:0000"}}
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		Expect(got.Status.Pinned).To(BeNil())
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.RollbackFailed)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Reason).To(Equal("Unsupported"))
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Pinned)).To(BeNil())
+	})
+
+	It("creates one Argo CD Application per placed cluster", func() {
+		app := makeApplication(ns)
+		argoCD(app)
+		app.Spec.Placement = &platformv1alpha1.Placement{}
+		eu := &platformv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "eu-" + ns},
+			Spec:       platformv1alpha1.ClusterSpec{Type: "remote", Region: "eu-west-1"},
+		}
+		local := &platformv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "local-" + ns},
+			Spec:       platformv1alpha1.ClusterSpec{Type: "attached"},
+		}
+		var objs []client.Object
+		for _, clu := range []*platformv1alpha1.Cluster{eu, local} {
+			objs = append(objs, clu, &platformv1alpha1.ProjectClusterBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: app.Spec.ProjectRef + "-" + clu.Name},
+				Spec:       platformv1alpha1.ProjectClusterBindingSpec{ProjectRef: app.Spec.ProjectRef, ClusterRef: clu.Name},
+			})
+		}
+		c := newApplicationTestClient(append(objs, app)...)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		remote := argoApp(c, ns+"-"+app.Name+"-"+eu.Name)
+		destination, _, _ := unstructured.NestedString(remote.Object, "spec", "destination", "name")
+		Expect(destination).To(Equal(eu.Name))
+		attached := argoApp(c, ns+"-"+app.Name+"-"+local.Name)
+		server, _, _ := unstructured.NestedString(attached.Object, "spec", "destination", "server")
+		Expect(server).To(Equal("https://kubernetes.default.svc"))
+
+		got := getApp(c, app)
+		Expect(got.Finalizers).NotTo(ContainElement(platformv1alpha1.PlacementFinalizer))
+		Expect(got.Status.ArgoCD).To(HaveLen(2))
+		Expect(got.Status.ArgoCD[0].Cluster).To(Equal(eu.Name))
+
+		reportStatus(c, remote.GetName(), "Synced", "Healthy")
+		reportStatus(c, attached.GetName(), "OutOfSync", "Healthy")
+		reconcileApp(r, app)
+		ready := apimeta.FindStatusCondition(getApp(c, app).Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready.Reason).To(Equal("OutOfSync"))
+		Expect(ready.Message).To(ContainSubstring(local.Name))
+	})
+
+	It("deletes the Argo CD Application when switched back to the direct backend", func() {
+		app := makeApplication(ns)
+		argoCD(app)
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+		name := ns + "-" + app.Name
+		argoApp(c, name)
+
+		got := getApp(c, app)
+		got.Spec.Deploy.Backend = platformv1alpha1.DeployBackendDirect
+		Expect(c.Update(ctx, got)).To(Succeed())
+		reconcileApp(r, app)
+
+		// Argo CD deletes the objects it synced before releasing its Application
+		Expect(argoApp(c, name).GetDeletionTimestamp()).NotTo(BeNil())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})).To(Succeed())
+		got = getApp(c, app)
+		Expect(got.Finalizers).NotTo(ContainElement(platformv1alpha1.ArgoCDFinalizer))
+		Expect(got.Status.ArgoCD).To(BeEmpty())
+	})
+})
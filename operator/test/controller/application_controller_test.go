@@ -1,69 +1,943 @@
 package controller
 
-// import (
-// 	"context"
-
-// 	. "github.com/onsi/ginkgo/v2"
-// 	. "github.com/onsi/gomega"
-// 	"k8s.io/apimachinery/pkg/api/errors"
-// 	"k8s.io/apimachinery/pkg/types"
-// 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-
-// 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
-// 	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
-// 	"github.com/mofe64/vulkan/operator/internal/controller"
-// )
-
-// var _ = Describe("Application Controller", func() {
-// 	Context("When reconciling a resource", func() {
-// 		const resourceName = "test-resource"
-
-// 		ctx := context.Background()
-
-// 		typeNamespacedName := types.NamespacedName{
-// 			Name:      resourceName,
-// 			Namespace: "default", // TODO(user):Modify as needed
-// 		}
-// 		application := &platformv1alpha1.Application{}
-
-// 		BeforeEach(func() {
-// 			By("creating the custom resource for the Kind Application")
-// 			err := k8sClient.Get(ctx, typeNamespacedName, application)
-// 			if err != nil && errors.IsNotFound(err) {
-// 				resource := &platformv1alpha1.Application{
-// 					ObjectMeta: metav1.ObjectMeta{
-// 						Name:      resourceName,
-// 						Namespace: "default",
-// 					},
-// 					// TODO(user): Specify other spec details if needed.
-// 				}
-// 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
-// 			}
-// 		})
-
-// 		AfterEach(func() {
-// 			// TODO(user): Cleanup logic after each test, like removing the resource instance.
-// 			resource := &platformv1alpha1.Application{}
-// 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
-// 			Expect(err).NotTo(HaveOccurred())
-
-// 			By("Cleanup the specific resource instance Application")
-// 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
-// 		})
-// 		It("should successfully reconcile the resource", func() {
-// 			By("Reconciling the created resource")
-// 			controllerReconciler := &controller.ApplicationReconciler{
-// 				Client: k8sClient,
-// 				Scheme: k8sClient.Scheme(),
-// 			}
-
-// 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
-// 				NamespacedName: typeNamespacedName,
-// 			})
-// 			Expect(err).NotTo(HaveOccurred())
-// 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-// 			// Example: If you expect a certain status condition after reconciliation, verify it here.
-// 		})
-// 	})
-// })
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"knative.dev/pkg/apis"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/buildstrategy"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+)
+
+// -----------------------------------------------------------------------------
+// Helpers shared by the Application specs
+// -----------------------------------------------------------------------------
+
+// The envtest apiserver has no Tekton CRDs installed, so the Application specs run
+// against a fake client whose scheme knows about PipelineRuns. Unlike the apiserver the
+// fake client does not stamp creationTimestamp, so created objects get a strictly
+// increasing one to keep "newest run" ordering deterministic.
+func newApplicationTestClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(platformv1alpha1.AddToScheme(scheme)).To(Succeed())
+	Expect(tektonv1.AddToScheme(scheme)).To(Succeed())
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&platformv1alpha1.Application{}, &tektonv1.PipelineRun{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if ts := obj.GetCreationTimestamp(); ts.IsZero() {
+					created.Add(1)
+					obj.SetCreationTimestamp(metav1.NewTime(time.Now().Add(time.Duration(created.Load()) * time.Second)))
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+}
+
+// created counts objects created through newApplicationTestClient.
+var created atomic.Int64
+
+func buildTestApplicationReconciler(c client.Client) *controllerImpl.ApplicationReconciler {
+	return &controllerImpl.ApplicationReconciler{
+		Client: c,
+		Scheme: c.Scheme(),
+	}
+}
+
+// makeApplication scaffolds a dockerfile Application in the given namespace.
+func makeApplication(ns string) *platformv1alpha1.Application {
+	return &platformv1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-" + uuid.NewString()[:8],
+			Namespace: ns,
+			UID:       types.UID(uuid.NewString()),
+		},
+		Spec: platformv1alpha1.ApplicationSpec{
+			RepoURL: "https://github.com/example/app.git",
+			Build: platformv1alpha1.BuildConfig{
+				Strategy: "dockerfile",
+				Ref:      "main",
+			},
+			Env: []platformv1alpha1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "debug"},
+			},
+			Autoscaling: platformv1alpha1.HPAPolicy{Min: 2, Max: 5},
+			ProjectRef:  uuid.NewString(),
+			OrgRef:      uuid.NewString(),
+		},
+	}
+}
+
+// makePipelineRun scaffolds a finished (or running, when succeeded is Unknown) build
+// PipelineRun controlled by the Application.
+func makePipelineRun(c client.Client, app *platformv1alpha1.Application, succeeded corev1.ConditionStatus, reason string, results map[string]string) *tektonv1.PipelineRun {
+	run := &tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              app.Name + "-build-" + uuid.NewString()[:5],
+			Namespace:         app.Namespace,
+			CreationTimestamp: metav1.Now(),
+			Labels:            map[string]string{platformv1alpha1.ApplicationLabel: app.Name},
+		},
+		Spec: tektonv1.PipelineRunSpec{
+			Params: []tektonv1.Param{
+				{Name: "image-name", Value: *tektonv1.NewStructuredValues("ghcr.io/example/" + app.Name)},
+				{Name: "image-tag", Value: *tektonv1.NewStructuredValues(app.Spec.Build.Ref)},
+			},
+		},
+	}
+	Expect(ctrl.SetControllerReference(app, run, c.Scheme())).To(Succeed())
+
+	now := metav1.Now()
+	run.Status.StartTime = &now
+	run.Status.SetCondition(&apis.Condition{
+		Type:    apis.ConditionSucceeded,
+		Status:  succeeded,
+		Reason:  reason,
+		Message: "pipeline " + reason,
+	})
+	if succeeded != corev1.ConditionUnknown {
+		run.Status.CompletionTime = &now
+	}
+	for name, value := range results {
+		run.Status.Results = append(run.Status.Results, tektonv1.PipelineRunResult{
+			Name:  name,
+			Value: *tektonv1.NewStructuredValues(value),
+		})
+	}
+	return run
+}
+
+// listBuildRuns returns the PipelineRuns created for the Application.
+func listBuildRuns(ctx context.Context, c client.Client, app *platformv1alpha1.Application) []tektonv1.PipelineRun {
+	var runs tektonv1.PipelineRunList
+	Expect(c.List(ctx, &runs,
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels{platformv1alpha1.ApplicationLabel: app.Name},
+	)).To(Succeed())
+	return runs.Items
+}
+
+// runParam returns the string value of a PipelineRun param, or "" when it is not set.
+func runParam(run tektonv1.PipelineRun, name string) string {
+	for _, p := range run.Spec.Params {
+		if p.Name == name {
+			return p.Value.StringVal
+		}
+	}
+	return ""
+}
+
+// finishPipelineRun marks a PipelineRun as completed with the given Succeeded status.
+func finishPipelineRun(ctx context.Context, c client.Client, run *tektonv1.PipelineRun, succeeded corev1.ConditionStatus) {
+	now := metav1.Now()
+	run.Status.StartTime = &now
+	run.Status.CompletionTime = &now
+	run.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: succeeded})
+	Expect(c.Status().Update(ctx, run)).To(Succeed())
+}
+
+var _ = Describe("Application controller", func() {
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	It("does not render a workload before the first build produced an image", func() {
+		app := makeApplication(ns)
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var deploy appsv1.Deployment
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		var runs tektonv1.PipelineRunList
+		Expect(c.List(ctx, &runs, client.InNamespace(ns))).To(Succeed())
+		Expect(runs.Items).NotTo(BeEmpty())
+	})
+
+	It("renders a Deployment, Service and HPA owned by the Application", func() {
+		app := makeApplication(ns)
+		app.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers).To(HaveLen(1))
+		container := deploy.Spec.Template.Spec.Containers[0]
+		Expect(container.Image).To(Equal(app.Status.Image))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		Expect(*deploy.Spec.Replicas).To(BeNumerically("==", 2))
+		Expect(deploy.Spec.Selector.MatchLabels).To(HaveKeyWithValue(platformv1alpha1.ApplicationLabel, app.Name))
+		Expect(metav1.IsControlledBy(&deploy, app)).To(BeTrue())
+
+		var svc corev1.Service
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &svc)).To(Succeed())
+		Expect(svc.Spec.Selector).To(HaveKeyWithValue(platformv1alpha1.ApplicationLabel, app.Name))
+		Expect(metav1.IsControlledBy(&svc, app)).To(BeTrue())
+
+		var hpa autoscalingv2.HorizontalPodAutoscaler
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &hpa)).To(Succeed())
+		Expect(*hpa.Spec.MinReplicas).To(BeNumerically("==", 2))
+		Expect(hpa.Spec.MaxReplicas).To(BeNumerically("==", 5))
+		Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(app.Name))
+	})
+
+	It("rolls the Deployment when a new build lands", func() {
+		app := makeApplication(ns)
+		app.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		got.Status.Image = "ghcr.io/example/app@sha256:bbbb"
+		Expect(c.Status().Update(ctx, &got)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/example/app@sha256:bbbb"))
+	})
+
+	It("removes the HPA when autoscaling is disabled", func() {
+		app := makeApplication(ns)
+		app.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		// the HPA scaled the Deployment up
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		scaled := int32(5)
+		deploy.Spec.Replicas = &scaled
+		Expect(c.Update(ctx, &deploy)).To(Succeed())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		got.Spec.Autoscaling = platformv1alpha1.HPAPolicy{}
+		Expect(c.Update(ctx, &got)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var hpa autoscalingv2.HorizontalPodAutoscaler
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &hpa)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		// nothing scales the Deployment anymore, it runs the minimum
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(*deploy.Spec.Replicas).To(Equal(int32(1)))
+	})
+
+	It("records the built digest, commit and history from a successful PipelineRun", func() {
+		app := makeApplication(ns)
+		c := newApplicationTestClient(app)
+		run := makePipelineRun(c, app, corev1.ConditionTrue, "Succeeded", map[string]string{
+			"image-digest": "sha256:1234",
+			"commit":       "0123456789abcdef",
+		})
+		Expect(c.Create(ctx, run)).To(Succeed())
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(got.Status.Image).To(Equal("ghcr.io/example/" + app.Name + "@sha256:1234"))
+		Expect(got.Status.Revision).To(Equal("0123456789abcdef"))
+		Expect(got.Status.Builds).To(HaveLen(1))
+		Expect(got.Status.Builds[0].PipelineRun).To(Equal(run.Name))
+		Expect(got.Status.Builds[0].Outcome).To(Equal(platformv1alpha1.BuildOutcomeSucceeded))
+
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.BuildFailed)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Status).To(Equal(metav1.ConditionFalse))
+
+		// the image is rolled out in the same reconcile
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(got.Status.Image))
+		ready := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("sets BuildFailed=True when the latest PipelineRun failed", func() {
+		app := makeApplication(ns)
+		c := newApplicationTestClient(app)
+		run := makePipelineRun(c, app, corev1.ConditionFalse, "Failed", nil)
+		Expect(c.Create(ctx, run)).To(Succeed())
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.BuildFailed)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Status).To(Equal(metav1.ConditionTrue))
+		Expect(failed.Message).To(ContainSubstring(run.Name))
+		Expect(got.Status.Image).To(BeEmpty())
+		Expect(got.Status.Builds[0].Outcome).To(Equal(platformv1alpha1.BuildOutcomeFailed))
+
+		ready := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Reason).To(Equal("AwaitingBuild"))
+	})
+
+	It("sets Building=True while a PipelineRun is running", func() {
+		app := makeApplication(ns)
+		c := newApplicationTestClient(app)
+		run := makePipelineRun(c, app, corev1.ConditionUnknown, "Running", nil)
+		Expect(c.Create(ctx, run)).To(Succeed())
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		building := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Building)
+		Expect(building).NotTo(BeNil())
+		Expect(building.Status).To(Equal(metav1.ConditionTrue))
+		Expect(got.Status.Builds[0].Outcome).To(Equal(platformv1alpha1.BuildOutcomeRunning))
+	})
+
+	Context("build triggering", func() {
+		var (
+			app *platformv1alpha1.Application
+			c   client.Client
+			r   *controllerImpl.ApplicationReconciler
+			req reconcile.Request
+		)
+
+		BeforeEach(func() {
+			app = makeApplication(ns)
+			c = newApplicationTestClient(app)
+			r = buildTestApplicationReconciler(c)
+			req = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Requeue).To(BeFalse())
+		})
+
+		It("labels and fingerprints the PipelineRun it creates", func() {
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].Labels).To(HaveKeyWithValue(platformv1alpha1.ManagedByLabel, platformv1alpha1.ManagedByValue))
+			Expect(runs[0].Annotations).To(HaveKey(platformv1alpha1.BuildFingerprintAnnotation))
+			Expect(metav1.IsControlledBy(&runs[0], app)).To(BeTrue())
+		})
+
+		It("does not start another build while one is running or the spec is unchanged", func() {
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+		})
+
+		It("does not retry a failed build of an unchanged spec", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionFalse)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+		})
+
+		It("starts a new build when the build configuration changes", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Spec.Build.Ref = "release"
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(2))
+			Expect(runs[0].Annotations[platformv1alpha1.BuildFingerprintAnnotation]).
+				NotTo(Equal(runs[1].Annotations[platformv1alpha1.BuildFingerprintAnnotation]))
+		})
+
+		It("does not rebuild when only runtime fields change", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Spec.Env = append(got.Spec.Env, platformv1alpha1.EnvVar{Name: "FEATURE", Value: "on"})
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+		})
+
+		It("rebuilds once per rebuild request", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{platformv1alpha1.RebuildRequestedAtAnnotation: "2025-01-01T00:00:00Z"}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(2))
+
+			for _, run := range listBuildRuns(ctx, c, app) {
+				if run.Status.CompletionTime == nil {
+					Expect(run.Annotations).To(HaveKeyWithValue(platformv1alpha1.RebuildRequestedAtAnnotation, "2025-01-01T00:00:00Z"))
+					finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+				}
+			}
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(2))
+		})
+
+		It("builds the exact commit pushed to the build ref", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			const sha = "3f786850e387550fdab836ed7e6dc881de23001b"
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{
+				platformv1alpha1.SourceRefAnnotation:      "main",
+				platformv1alpha1.SourceRevisionAnnotation: sha,
+			}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(2))
+			for _, run := range runs {
+				if run.Status.CompletionTime == nil {
+					Expect(runParam(run, "branch")).To(Equal(sha))
+					Expect(runParam(run, "image-tag")).To(Equal(sha))
+					Expect(run.Annotations).To(HaveKeyWithValue(platformv1alpha1.SourceRevisionAnnotation, sha))
+				}
+			}
+		})
+
+		It("records the pusher of a commit rather than the latest requester", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{
+				platformv1alpha1.SourceRefAnnotation:      "main",
+				platformv1alpha1.SourceRevisionAnnotation: "3f786850e387550fdab836ed7e6dc881de23001b",
+				platformv1alpha1.SourcePushedByAnnotation: "github:octocat",
+				platformv1alpha1.RequestedByAnnotation:    "jane@example.com",
+			}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			Expect(got.Status.Builds).To(ContainElement(And(
+				HaveField("Trigger", platformv1alpha1.BuildTriggerPush),
+				HaveField("TriggeredBy", "github:octocat"),
+			)))
+		})
+
+		It("labels Applications with their org and project", func() {
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			Expect(got.Labels).To(HaveKeyWithValue(platformv1alpha1.OrgLabel, app.Spec.OrgRef))
+			Expect(got.Labels).To(HaveKeyWithValue(platformv1alpha1.ProjectLabel, app.Spec.ProjectRef))
+		})
+
+		It("labels Applications with a webhook with their repository", func() {
+			Expect(app.Labels).NotTo(HaveKey(platformv1alpha1.RepoLabel))
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Spec.Build.Webhook = &platformv1alpha1.WebhookConfig{SecretRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "hook"},
+				Key:                  "secret",
+			}}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			Expect(got.Labels).To(HaveKeyWithValue(platformv1alpha1.RepoLabel,
+				platformv1alpha1.RepoLabelValue("git@github.com:example/app")))
+
+			got.Spec.Build.Webhook = nil
+			Expect(c.Update(ctx, &got)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			Expect(got.Labels).NotTo(HaveKey(platformv1alpha1.RepoLabel))
+		})
+
+		It("records who requested a rebuild in the build history", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{
+				platformv1alpha1.RebuildRequestedAtAnnotation: "2025-01-01T00:00:00Z",
+				platformv1alpha1.RequestedByAnnotation:        "jane@example.com",
+			}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			Expect(got.Status.Builds).To(HaveLen(2))
+			Expect(got.Status.Builds[0].Trigger).To(Equal(platformv1alpha1.BuildTriggerRebuild))
+			Expect(got.Status.Builds[0].TriggeredBy).To(Equal("jane@example.com"))
+			Expect(got.Status.Builds[1].Trigger).To(Equal(platformv1alpha1.BuildTriggerConfigChange))
+			Expect(got.Status.Builds[1].TriggeredBy).To(BeEmpty())
+		})
+
+		It("retries a failed build once per retry request", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionFalse)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{platformv1alpha1.RetryRequestedAtAnnotation: "2025-01-01T00:00:00Z"}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(2))
+
+			for _, run := range listBuildRuns(ctx, c, app) {
+				if run.Status.CompletionTime == nil {
+					Expect(run.Annotations).To(HaveKeyWithValue(platformv1alpha1.BuildTriggerAnnotation, platformv1alpha1.BuildTriggerRetry))
+					finishPipelineRun(ctx, c, &run, corev1.ConditionFalse)
+				}
+			}
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(2))
+		})
+
+		It("does not retry a build that succeeded", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{platformv1alpha1.RetryRequestedAtAnnotation: "2025-01-01T00:00:00Z"}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+		})
+
+		It("cancels the running build on request", func() {
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{
+				platformv1alpha1.CancelRequestedAtAnnotation: "2025-01-01T00:00:00Z",
+				platformv1alpha1.RequestedByAnnotation:       "jane@example.com",
+			}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].Spec.Status).To(Equal(tektonv1.PipelineRunSpecStatus(tektonv1.PipelineRunSpecStatusCancelled)))
+			Expect(runs[0].Annotations).To(HaveKeyWithValue(platformv1alpha1.BuildCancelledByAnnotation, "jane@example.com"))
+		})
+
+		It("does not cancel builds started after the cancel request", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{
+				platformv1alpha1.CancelRequestedAtAnnotation:  "2025-01-01T00:00:00Z",
+				platformv1alpha1.RebuildRequestedAtAnnotation: "2025-01-01T00:00:01Z",
+			}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(2))
+			for _, run := range runs {
+				Expect(run.Spec.Status).To(BeEmpty())
+			}
+		})
+
+		It("ignores commits pushed to another ref", func() {
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			got.Annotations = map[string]string{
+				platformv1alpha1.SourceRefAnnotation:      "feature",
+				platformv1alpha1.SourceRevisionAnnotation: "3f786850e387550fdab836ed7e6dc881de23001b",
+			}
+			Expect(c.Update(ctx, &got)).To(Succeed())
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+		})
+	})
+
+	Context("build settings", func() {
+		It("uses the operator defaults when neither the Org nor the Project override them", func() {
+			app := makeApplication(ns)
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+			r.BuildDefaults = platformv1alpha1.BuildSettings{ImageRegistry: "registry.example.com/team"}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(1))
+			Expect(runParam(runs[0], "image-name")).To(Equal("registry.example.com/team/" + app.Name))
+			Expect(runParam(runs[0], "gitops-repo-url")).To(Equal(controllerImpl.DefaultBuildSettings.GitOpsRepoURL))
+			Expect(runs[0].Spec.TaskRunTemplate.ServiceAccountName).To(Equal(controllerImpl.DefaultBuildSettings.ServiceAccountName))
+		})
+
+		It("layers Org and then Project overrides on top of the operator defaults", func() {
+			app := makeApplication(ns)
+			org := &platformv1alpha1.Org{
+				ObjectMeta: metav1.ObjectMeta{Name: app.Spec.OrgRef},
+				Spec: platformv1alpha1.OrgSpec{
+					BuildSettings: &platformv1alpha1.BuildSettings{
+						ImageRegistry:      "registry.acme.io/org",
+						GitOpsRepoURL:      "https://git.acme.io/gitops.git",
+						RegistrySecretName: "org-registry",
+					},
+				},
+			}
+			proj := &platformv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: app.Spec.ProjectRef},
+				Spec: platformv1alpha1.ProjectSpec{
+					BuildSettings: &platformv1alpha1.BuildSettings{
+						ImageRegistry:      "registry.acme.io/proj",
+						ServiceAccountName: "proj-builder",
+					},
+				},
+			}
+			c := newApplicationTestClient(app, org, proj)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(1))
+			run := runs[0]
+			Expect(runParam(run, "image-name")).To(Equal("registry.acme.io/proj/" + app.Name))
+			Expect(runParam(run, "gitops-repo-url")).To(Equal("https://git.acme.io/gitops.git"))
+			Expect(run.Spec.TaskRunTemplate.ServiceAccountName).To(Equal("proj-builder"))
+
+			secrets := map[string]string{}
+			for _, ws := range run.Spec.Workspaces {
+				if ws.Secret != nil {
+					secrets[ws.Name] = ws.Secret.SecretName
+				}
+			}
+			Expect(secrets).To(HaveKeyWithValue("docker-config", "org-registry"))
+			Expect(secrets).To(HaveKeyWithValue("git-credentials", controllerImpl.DefaultBuildSettings.GitCredentialsSecretName))
+		})
+
+		It("rebuilds when the effective registry changes", func() {
+			app := makeApplication(ns)
+			proj := &platformv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: app.Spec.ProjectRef}}
+			c := newApplicationTestClient(app, proj)
+			r := buildTestApplicationReconciler(c)
+			req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			run := listBuildRuns(ctx, c, app)[0]
+			finishPipelineRun(ctx, c, &run, corev1.ConditionTrue)
+
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(proj), proj)).To(Succeed())
+			proj.Spec.BuildSettings = &platformv1alpha1.BuildSettings{ImageRegistry: "registry.acme.io/moved"}
+			Expect(c.Update(ctx, proj)).To(Succeed())
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(2))
+		})
+	})
+
+	Context("build configuration", func() {
+		It("passes context dir, build args, target and build env to the dockerfile pipeline", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.ContextDir = "services/api"
+			app.Spec.Build.Target = "runtime"
+			app.Spec.Build.BuildArgs = map[string]string{"GO_VERSION": "1.24", "CGO_ENABLED": "0"}
+			app.Spec.Build.Env = []platformv1alpha1.BuildEnvVar{
+				{Name: "GOFLAGS", Value: "-trimpath"},
+				{Name: "NPM_TOKEN", SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "build-secrets"},
+					Key:                  "npm",
+				}},
+			}
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(1))
+			run := runs[0]
+			Expect(runParam(run, "context-dir")).To(Equal("services/api"))
+			Expect(runParam(run, "target")).To(Equal("runtime"))
+			Expect(runParam(run, "build-args")).To(Equal("CGO_ENABLED=0\nGOFLAGS=-trimpath\nGO_VERSION=1.24"))
+			Expect(runParam(run, "secret-env-names")).To(Equal("NPM_TOKEN"))
+
+			// the Secret value is injected into the build task only, never written to params
+			Expect(run.Spec.TaskRunSpecs).To(HaveLen(1))
+			Expect(run.Spec.TaskRunSpecs[0].PipelineTaskName).To(Equal("kaniko-build-and-push"))
+			env := run.Spec.TaskRunSpecs[0].PodTemplate.Env
+			Expect(env).To(HaveLen(1))
+			Expect(env[0].Name).To(Equal("NPM_TOKEN"))
+			Expect(env[0].ValueFrom.SecretKeyRef.Name).To(Equal("build-secrets"))
+			Expect(env[0].ValueFrom.SecretKeyRef.Key).To(Equal("npm"))
+		})
+
+		It("passes builder image, context dir and build env to the buildpack pipeline", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "buildpack"
+			app.Spec.Build.BuilderImage = "paketobuildpacks/builder-jammy-base"
+			app.Spec.Build.ContextDir = "web"
+			app.Spec.Build.Env = []platformv1alpha1.BuildEnvVar{{Name: "BP_NODE_VERSION", Value: "20"}}
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := listBuildRuns(ctx, c, app)[0]
+			Expect(run.Spec.PipelineRef.Name).To(Equal("app-build-buildpack"))
+			Expect(runParam(run, "builder-image")).To(Equal("paketobuildpacks/builder-jammy-base"))
+			Expect(runParam(run, "context-dir")).To(Equal("web"))
+			Expect(runParam(run, "env-vars")).To(Equal("BP_NODE_VERSION=20"))
+			Expect(run.Spec.TaskRunSpecs).To(BeEmpty())
+		})
+
+		It("defaults the context dir and builder image", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "buildpack"
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := listBuildRuns(ctx, c, app)[0]
+			Expect(runParam(run, "builder-image")).To(Equal("paketobuildpacks/builder:base"))
+			Expect(runParam(run, "context-dir")).To(Equal("."))
+		})
+	})
+
+	Context("build strategies", func() {
+		It("sets a terminal InvalidSpec condition for an unknown strategy", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "nixpacks"
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(reconcile.Result{}))
+			Expect(listBuildRuns(ctx, c, app)).To(BeEmpty())
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+			invalid := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)
+			Expect(invalid).NotTo(BeNil())
+			Expect(invalid.Status).To(Equal(metav1.ConditionTrue))
+			Expect(invalid.Message).To(ContainSubstring("nixpacks"))
+
+			// fixing the spec clears the condition and starts a build
+			got.Spec.Build.Strategy = "dockerfile"
+			Expect(c.Update(ctx, &got)).To(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(HaveLen(1))
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+			Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)).To(BeNil())
+		})
+
+		It("rejects the pipeline strategy without a pipeline name", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "pipeline"
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(BeEmpty())
+
+			var got platformv1alpha1.Application
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+			Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.InvalidSpec)).To(BeTrue())
+		})
+
+		It("runs a user-supplied pipeline", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "pipeline"
+			app.Spec.Build.Pipeline = "team-build"
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			runs := listBuildRuns(ctx, c, app)
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].Spec.PipelineRef.Name).To(Equal("team-build"))
+			Expect(runs[0].Annotations).To(HaveKeyWithValue(platformv1alpha1.BuildStrategyAnnotation, "pipeline"))
+			Expect(runs[0].Spec.Workspaces).To(HaveLen(3))
+		})
+
+		It("builds Go services with ko", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "ko"
+			app.Spec.Build.ContextDir = "cmd/server"
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := listBuildRuns(ctx, c, app)[0]
+			Expect(run.Spec.PipelineRef.Name).To(Equal("app-build-ko"))
+			Expect(runParam(run, "context-dir")).To(Equal("cmd/server"))
+			Expect(runParam(run, "base-image")).NotTo(BeEmpty())
+		})
+
+		It("deploys a prebuilt image without creating a PipelineRun", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "prebuilt"
+			app.Spec.Image = &platformv1alpha1.ImageSource{Reference: "registry.example.com/app@sha256:cccc"}
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listBuildRuns(ctx, c, app)).To(BeEmpty())
+
+			var deploy appsv1.Deployment
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+			Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.example.com/app@sha256:cccc"))
+		})
+
+		It("resolves strategies from a custom registry", func() {
+			app := makeApplication(ns)
+			app.Spec.Build.Strategy = "in-house"
+			c := newApplicationTestClient(app)
+			r := buildTestApplicationReconciler(c)
+			r.Strategies = buildstrategy.NewRegistry(inHouseStrategy{})
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+			Expect(err).NotTo(HaveOccurred())
+
+			run := listBuildRuns(ctx, c, app)[0]
+			Expect(run.Spec.PipelineRef.Name).To(Equal("in-house-build"))
+			Expect(runParam(run, "flavour")).To(Equal("vanilla"))
+			Expect(run.Spec.Workspaces).To(HaveLen(1))
+		})
+	})
+})
+
+// inHouseStrategy is a minimal third-party BuildStrategy.
+type inHouseStrategy struct{}
+
+func (inHouseStrategy) Name() string                                     { return "in-house" }
+func (inHouseStrategy) Validate(*platformv1alpha1.Application) error     { return nil }
+func (inHouseStrategy) PipelineRef(*platformv1alpha1.Application) string { return "in-house-build" }
+func (inHouseStrategy) Params(*platformv1alpha1.Application) []tektonv1.Param {
+	return []tektonv1.Param{buildstrategy.StringParam("flavour", "vanilla")}
+}
+func (inHouseStrategy) Workspaces() []string { return []string{buildstrategy.SourceWorkspace} }
+func (inHouseStrategy) BuildTask() string    { return "build" }
+func (inHouseStrategy) ParseResults(run *tektonv1.PipelineRun) (string, string) {
+	return buildstrategy.ParseStandardResults(run)
+}
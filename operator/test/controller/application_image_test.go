@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// fakeRegistry is a local stand-in for an OCI registry: it serves manifest digests for
+// the repository:tag pairs in tags, guarded by bearer token auth or, when basicAuth is
+// set, by HTTP basic auth with those credentials.
+type fakeRegistry struct {
+	server    *httptest.Server
+	tags      map[string]string
+	basicAuth string
+	manifests atomic.Int32
+}
+
+const fakeRegistryToken = "s3cr3t-token"
+
+func newFakeRegistry(tags map[string]string, basicAuth string) *fakeRegistry {
+	reg := &fakeRegistry{tags: tags, basicAuth: basicAuth}
+	reg.server = httptest.NewServer(http.HandlerFunc(reg.serve))
+	return reg
+}
+
+// host is the registry domain as it appears in image references.
+func (reg *fakeRegistry) host() string {
+	return strings.TrimPrefix(reg.server.URL, "http://")
+}
+
+func (reg *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		Expect(req.URL.Query().Get("scope")).To(HavePrefix("repository:"))
+		_, _ = w.Write([]byte(`{"token":"` + fakeRegistryToken + `"}`))
+		return
+	}
+
+	// /v2/<repository>/manifests/<tag>
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	repo, tag, ok := strings.Cut(path, "/manifests/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	reg.manifests.Add(1)
+
+	authz := req.Header.Get("Authorization")
+	switch {
+	case reg.basicAuth != "":
+		if authz != "Basic "+base64.StdEncoding.EncodeToString([]byte(reg.basicAuth)) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case authz != "Bearer "+fakeRegistryToken:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+reg.server.URL+`/token",service="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	digest, found := reg.tags[repo+":"+tag]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+}
+
+// makePrebuiltApplication scaffolds an Application that only deploys an image.
+func makePrebuiltApplication(ns, ref string) *platformv1alpha1.Application {
+	return &platformv1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-" + uuid.NewString()[:8],
+			Namespace: ns,
+			UID:       types.UID(uuid.NewString()),
+		},
+		Spec: platformv1alpha1.ApplicationSpec{
+			Image:      &platformv1alpha1.ImageSource{Reference: ref},
+			ProjectRef: uuid.NewString(),
+			OrgRef:     uuid.NewString(),
+		},
+	}
+}
+
+var _ = Describe("Application prebuilt images", func() {
+	const digest = "sha256:0d5e7f3c1a2b4c6d8e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d"
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	It("resolves the tag to a digest and deploys it without building", func() {
+		reg := newFakeRegistry(map[string]string{"team/api:v1.2.0": digest}, "")
+		DeferCleanup(reg.server.Close)
+
+		app := makePrebuiltApplication(ns, reg.host()+"/team/api:v1.2.0")
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listBuildRuns(ctx, c, app)).To(BeEmpty())
+
+		pinned := reg.host() + "/team/api@" + digest
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+		Expect(got.Status.Image).To(Equal(pinned))
+		Expect(got.Status.SourceImage).To(Equal(app.Spec.Image.Reference))
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)).To(BeNil())
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, req.NamespacedName, &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(pinned))
+
+		// an unchanged reference is not resolved again
+		calls := reg.manifests.Load()
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reg.manifests.Load()).To(Equal(calls))
+	})
+
+	It("authenticates with the pull secret and adds it to the pods", func() {
+		reg := newFakeRegistry(map[string]string{"private/worker:latest": digest}, "robot:hunter2")
+		DeferCleanup(reg.server.Close)
+
+		app := makePrebuiltApplication(ns, reg.host()+"/private/worker")
+		app.Spec.Image.PullSecret = "registry-creds"
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: ns},
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"` + reg.host() + `":{"auth":"` +
+					base64.StdEncoding.EncodeToString([]byte("robot:hunter2")) + `"}}}`),
+			},
+		}
+		c := newApplicationTestClient(app, secret)
+		r := buildTestApplicationReconciler(c)
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+		Expect(got.Status.Image).To(Equal(reg.host() + "/private/worker@" + digest))
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, req.NamespacedName, &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry-creds"}))
+	})
+
+	It("reports a tag that cannot be resolved and retries", func() {
+		reg := newFakeRegistry(map[string]string{}, "")
+		DeferCleanup(reg.server.Close)
+
+		app := makePrebuiltApplication(ns, reg.host()+"/team/api:missing")
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).To(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+		Expect(got.Status.Image).To(BeEmpty())
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.BuildFailed)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Status).To(Equal(metav1.ConditionTrue))
+		Expect(failed.Reason).To(Equal("ImageResolutionFailed"))
+	})
+
+	It("deploys a digest-pinned reference as is", func() {
+		app := makePrebuiltApplication(ns, "registry.invalid/team/api@"+digest)
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.invalid/team/api@" + digest))
+	})
+
+	It("keeps deploying the prebuilt image after switching from builds to spec.image", func() {
+		app := makeApplication(ns)
+		c := newApplicationTestClient(app)
+		run := makePipelineRun(c, app, corev1.ConditionTrue, "Succeeded", map[string]string{
+			"image-digest": "sha256:1234",
+			"commit":       "0123456789abcdef",
+		})
+		Expect(c.Create(ctx, run)).To(Succeed())
+		r := buildTestApplicationReconciler(c)
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+		got.Spec.Build.Strategy = ""
+		got.Spec.Image = &platformv1alpha1.ImageSource{Reference: "registry.invalid/team/api@" + digest}
+		Expect(c.Update(ctx, &got)).To(Succeed())
+
+		// the successful build stays in the history but is not rolled out again
+		for range 2 {
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(c.Get(ctx, req.NamespacedName, &got)).To(Succeed())
+			Expect(got.Status.Image).To(Equal("registry.invalid/team/api@" + digest))
+			Expect(got.Status.Revision).To(BeEmpty())
+			Expect(got.Status.Builds).To(ContainElement(HaveField("PipelineRun", run.Name)))
+
+			var deploy appsv1.Deployment
+			Expect(c.Get(ctx, req.NamespacedName, &deploy)).To(Succeed())
+			Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.invalid/team/api@" + digest))
+		}
+	})
+
+	It("requires a repository for strategies that build from source", func() {
+		app := makeApplication(ns)
+		app.Spec.RepoURL = ""
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.InvalidSpec)).To(BeTrue())
+	})
+})
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// clusterClients is a TargetClientFactory handing out one fake client per cluster name.
+// Clusters without a client cannot be reached.
+type clusterClients map[string]client.Client
+
+func (f clusterClients) ClientFor(_ context.Context, clu *platformv1alpha1.Cluster) (client.Client, error) {
+	if c, ok := f[clu.Name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("dial tcp: cluster %s unreachable", clu.Name)
+}
+
+var _ = Describe("Application placement", func() {
+	const image = "ghcr.io/example/app@sha256:aaaa"
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	reconcileApp := func(r reconcile.Reconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getApp := func(c client.Client, app *platformv1alpha1.Application) *platformv1alpha1.Application {
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		return &got
+	}
+
+	// remoteCluster is a remote Cluster CR in the region, and the binding of the project to it.
+	remoteCluster := func(name, region, project string) (*platformv1alpha1.Cluster, *platformv1alpha1.ProjectClusterBinding) {
+		clu := &platformv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"tier": "prod"}},
+			Spec:       platformv1alpha1.ClusterSpec{Type: "remote", Region: region},
+		}
+		binding := &platformv1alpha1.ProjectClusterBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: project + "-" + name},
+			Spec:       platformv1alpha1.ProjectClusterBindingSpec{ProjectRef: project, ClusterRef: name},
+		}
+		return clu, binding
+	}
+
+	It("deploys to the selected bound clusters and reports each of them", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Placement = &platformv1alpha1.Placement{Regions: []string{"eu-west-1", "us-east-1"}}
+		eu, euBinding := remoteCluster("eu-"+ns, "eu-west-1", app.Spec.ProjectRef)
+		us, usBinding := remoteCluster("us-"+ns, "us-east-1", app.Spec.ProjectRef)
+		ap, apBinding := remoteCluster("ap-"+ns, "ap-south-1", app.Spec.ProjectRef)
+		euClient, usClient, apClient := newApplicationTestClient(), newApplicationTestClient(), newApplicationTestClient()
+		c := newApplicationTestClient(app, eu, euBinding, us, usBinding, ap, apBinding)
+		r := buildTestApplicationReconciler(c)
+		r.TargetFactory = clusterClients{eu.Name: euClient, us.Name: usClient, ap.Name: apClient}
+		reconcileApp(r, app)
+
+		for _, remote := range []client.Client{euClient, usClient} {
+			var deploy appsv1.Deployment
+			Expect(remote.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+			Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(image))
+			Expect(deploy.OwnerReferences).To(BeEmpty())
+			Expect(remote.Get(ctx, client.ObjectKeyFromObject(app), &corev1.Service{})).To(Succeed())
+		}
+		err := apClient.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		// nothing runs next to the CR any more
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		got := getApp(c, app)
+		Expect(got.Finalizers).To(ContainElement(platformv1alpha1.PlacementFinalizer))
+		Expect(got.Status.Clusters).To(HaveLen(2))
+		Expect(got.Status.Clusters[0].Cluster).To(Equal(eu.Name))
+		Expect(got.Status.Clusters[0].Region).To(Equal("eu-west-1"))
+		Expect(got.Status.Clusters[0].Health).To(Equal(platformv1alpha1.HealthProgressing))
+		Expect(got.Status.Clusters[1].Cluster).To(Equal(us.Name))
+
+		// once the pods are available in both clusters the Application is ready
+		for _, remote := range []client.Client{euClient, usClient} {
+			var deploy appsv1.Deployment
+			Expect(remote.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+			deploy.Status.ObservedGeneration = deploy.Generation
+			deploy.Status.UpdatedReplicas = *deploy.Spec.Replicas
+			deploy.Status.AvailableReplicas = *deploy.Spec.Replicas
+			Expect(remote.Status().Update(ctx, &deploy)).To(Succeed())
+		}
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthHealthy))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+	})
+
+	It("reports an unreachable cluster without holding back the others", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Placement = &platformv1alpha1.Placement{
+			ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}},
+		}
+		eu, euBinding := remoteCluster("eu-"+ns, "eu-west-1", app.Spec.ProjectRef)
+		us, usBinding := remoteCluster("us-"+ns, "us-east-1", app.Spec.ProjectRef)
+		euClient := newApplicationTestClient()
+		c := newApplicationTestClient(app, eu, euBinding, us, usBinding)
+		r := buildTestApplicationReconciler(c)
+		r.TargetFactory = clusterClients{eu.Name: euClient}
+		reconcileApp(r, app)
+
+		Expect(euClient.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})).To(Succeed())
+		got := getApp(c, app)
+		Expect(got.Status.Clusters).To(HaveLen(2))
+		Expect(got.Status.Clusters[1].Health).To(Equal(platformv1alpha1.HealthError))
+		Expect(got.Status.Clusters[1].Reason).To(Equal("ClusterError"))
+		Expect(got.Status.Clusters[1].Message).To(ContainSubstring("unreachable"))
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthError))
+		ready := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Message).To(ContainSubstring(us.Name))
+	})
+
+	It("sets PlacementFailed when no bound cluster matches", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Placement = &platformv1alpha1.Placement{Clusters: []string{"missing"}}
+		eu, euBinding := remoteCluster("eu-"+ns, "eu-west-1", app.Spec.ProjectRef)
+		c := newApplicationTestClient(app, eu, euBinding)
+		r := buildTestApplicationReconciler(c)
+		r.TargetFactory = clusterClients{}
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.PlacementFailed)).To(BeTrue())
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthError))
+	})
+
+	It("runs the Job of a job Application in one cluster only", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Type = platformv1alpha1.ApplicationTypeJob
+		app.Spec.Placement = &platformv1alpha1.Placement{}
+		eu, euBinding := remoteCluster("eu-"+ns, "eu-west-1", app.Spec.ProjectRef)
+		us, usBinding := remoteCluster("us-"+ns, "us-east-1", app.Spec.ProjectRef)
+		euClient, usClient := newApplicationTestClient(), newApplicationTestClient()
+		c := newApplicationTestClient(app, eu, euBinding, us, usBinding)
+		r := buildTestApplicationReconciler(c)
+		r.TargetFactory = clusterClients{eu.Name: euClient, us.Name: usClient}
+		reconcileApp(r, app)
+		reconcileApp(r, app)
+
+		var euJobs, usJobs batchv1.JobList
+		Expect(euClient.List(ctx, &euJobs, client.InNamespace(ns))).To(Succeed())
+		Expect(usClient.List(ctx, &usJobs, client.InNamespace(ns))).To(Succeed())
+		Expect(euJobs.Items).To(HaveLen(1))
+		Expect(usJobs.Items).To(BeEmpty())
+		Expect(getApp(c, app).Status.Job.Cluster).To(Equal(eu.Name))
+	})
+
+	It("removes the workload from clusters it is no longer placed in", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Placement = &platformv1alpha1.Placement{}
+		eu, euBinding := remoteCluster("eu-"+ns, "eu-west-1", app.Spec.ProjectRef)
+		us, usBinding := remoteCluster("us-"+ns, "us-east-1", app.Spec.ProjectRef)
+		euClient, usClient := newApplicationTestClient(), newApplicationTestClient()
+		c := newApplicationTestClient(app, eu, euBinding, us, usBinding)
+		r := buildTestApplicationReconciler(c)
+		r.TargetFactory = clusterClients{eu.Name: euClient, us.Name: usClient}
+		reconcileApp(r, app)
+		Expect(usClient.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})).To(Succeed())
+
+		got := getApp(c, app)
+		got.Spec.Placement.Clusters = []string{eu.Name}
+		Expect(c.Update(ctx, got)).To(Succeed())
+		reconcileApp(r, app)
+		err := usClient.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = usClient.Get(ctx, client.ObjectKeyFromObject(app), &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(getApp(c, app).Status.Clusters).To(HaveLen(1))
+
+		// deleting the Application deletes its workload from the remaining cluster
+		Expect(c.Delete(ctx, getApp(c, app))).To(Succeed())
+		reconcileApp(r, app)
+		err = euClient.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &platformv1alpha1.Application{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
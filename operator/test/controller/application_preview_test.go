@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// makePreview scaffolds a preview of parent for a pull request, created at the given time.
+func makePreview(parent *platformv1alpha1.Application, pullRequest int32, created time.Time) *platformv1alpha1.Application {
+	preview := &platformv1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              parent.Name + "-pr-" + uuid.NewString()[:4],
+			Namespace:         parent.Namespace,
+			UID:               types.UID(uuid.NewString()),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: *parent.Spec.DeepCopy(),
+	}
+	preview.Spec.Build.Ref = "feature/login"
+	preview.Spec.Previews = nil
+	preview.Spec.Preview = &platformv1alpha1.PreviewSpec{Parent: parent.Name, PullRequest: pullRequest}
+	return preview
+}
+
+var _ = Describe("Application previews", func() {
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	It("ties a preview to its parent and exposes it on a hostname under the preview domain", func() {
+		parent := makeApplication(ns)
+		parent.Spec.Previews = &platformv1alpha1.PreviewConfig{Domain: "preview.example.com"}
+		preview := makePreview(parent, 42, time.Now())
+		preview.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		c := newApplicationTestClient(parent, preview)
+		r := buildTestApplicationReconciler(c)
+
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(preview)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeNumerically(">", 71*time.Hour))
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(preview), &got)).To(Succeed())
+		Expect(metav1.IsControlledBy(&got, parent)).To(BeTrue())
+		Expect(got.Status.ExpiresAt).NotTo(BeNil())
+		Expect(got.Status.URL).To(Equal("http://" + preview.Name + ".preview.example.com"))
+
+		var ingress networkingv1.Ingress
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(preview), &ingress)).To(Succeed())
+		Expect(ingress.Spec.Rules).To(HaveLen(1))
+		Expect(ingress.Spec.Rules[0].Host).To(Equal(preview.Name + ".preview.example.com"))
+		Expect(*ingress.Spec.IngressClassName).To(Equal("nginx"))
+		Expect(metav1.IsControlledBy(&ingress, &got)).To(BeTrue())
+	})
+
+	It("rolls a preview out without running the pre-deploy hook of its parent", func() {
+		parent := makeApplication(ns)
+		parent.Spec.PreDeploy = &platformv1alpha1.PreDeployHook{Command: []string{"bin/migrate"}}
+		preview := makePreview(parent, 7, time.Now())
+		preview.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		c := newApplicationTestClient(parent, preview)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(preview)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var jobs batchv1.JobList
+		Expect(c.List(ctx, &jobs, client.InNamespace(ns))).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(preview), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(preview.Status.Image))
+	})
+
+	It("deletes a preview once its TTL has passed", func() {
+		parent := makeApplication(ns)
+		parent.Spec.Previews = &platformv1alpha1.PreviewConfig{TTL: &metav1.Duration{Duration: time.Hour}}
+		preview := makePreview(parent, 7, time.Now().Add(-2*time.Hour))
+		c := newApplicationTestClient(parent, preview)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(preview)})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = c.Get(ctx, client.ObjectKeyFromObject(preview), &platformv1alpha1.Application{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(listBuildRuns(ctx, c, preview)).To(BeEmpty())
+	})
+
+	It("deletes a preview whose parent is gone", func() {
+		parent := makeApplication(ns)
+		preview := makePreview(parent, 7, time.Now())
+		c := newApplicationTestClient(preview)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(preview)})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = c.Get(ctx, client.ObjectKeyFromObject(preview), &platformv1alpha1.Application{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("does not expose Applications without a hostname", func() {
+		app := makeApplication(ns)
+		app.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &networkingv1.Ingress{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
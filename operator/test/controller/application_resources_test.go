@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+var _ = Describe("Application resources", func() {
+	const image = "ghcr.io/example/app@sha256:aaaa"
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+
+	reconcileApp := func(r reconcile.Reconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// makeQuota is a ResourceQuota of the namespace with the given hard limits and usage.
+	makeQuota := func(hard, used corev1.ResourceList) *corev1.ResourceQuota {
+		return &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "quota-proj", Namespace: ns},
+			Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+			Status:     corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+		}
+	}
+
+	It("renders the port, resources and probes of the application container", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Port = 3000
+		app.Spec.Resources = platformv1alpha1.ResourceRequirements{
+			Requests: platformv1alpha1.ComputeResources{CPU: quantity("250m"), Memory: quantity("256Mi")},
+			Limits:   platformv1alpha1.ComputeResources{Memory: quantity("512Mi")},
+		}
+		probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("http")},
+		}}
+		app.Spec.LivenessProbe = probe
+		app.Spec.ReadinessProbe = probe
+		app.Spec.StartupProbe = probe
+		c := newApplicationTestClient(app)
+		reconcileApp(buildTestApplicationReconciler(c), app)
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		container := deploy.Spec.Template.Spec.Containers[0]
+		Expect(container.Ports[0].ContainerPort).To(BeNumerically("==", 3000))
+		Expect(container.Resources.Requests.Cpu().String()).To(Equal("250m"))
+		Expect(container.Resources.Requests.Memory().String()).To(Equal("256Mi"))
+		Expect(container.Resources.Limits).NotTo(HaveKey(corev1.ResourceCPU))
+		Expect(container.Resources.Limits.Memory().String()).To(Equal("512Mi"))
+		Expect(container.LivenessProbe).To(Equal(probe))
+		Expect(container.ReadinessProbe).To(Equal(probe))
+		Expect(container.StartupProbe).To(Equal(probe))
+	})
+
+	It("rejects an Application whose minimum replicas exceed the remaining quota", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Autoscaling.Min = 3
+		app.Spec.Resources.Requests.CPU = quantity("500m")
+		quota := makeQuota(
+			corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+			corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		)
+		c := newApplicationTestClient(app, quota)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		cond := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Reason).To(Equal("QuotaExceeded"))
+		Expect(cond.Message).To(ContainSubstring("cpu: 1500m needed, 1 left in ResourceQuota quota-proj"))
+		err := c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		// two replicas fit
+		got.Spec.Autoscaling.Min = 2
+		Expect(c.Update(ctx, &got)).To(Succeed())
+		reconcileApp(r, app)
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)).To(BeNil())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})).To(Succeed())
+	})
+
+	It("counts LimitRange defaults and the Application's own pods", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Autoscaling.Min = 2
+		limits := &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: "limits-proj", Namespace: ns},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				DefaultRequest: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			}}},
+		}
+		// 512Mi of the 1Gi used are the Application's own two pods
+		quota := makeQuota(
+			corev1.ResourceList{corev1.ResourceRequestsMemory: resource.MustParse("1Gi")},
+			corev1.ResourceList{corev1.ResourceRequestsMemory: resource.MustParse("1Gi")},
+		)
+		pod := func(name string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns,
+					Labels:    map[string]string{platformv1alpha1.ApplicationLabel: app.Name},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "app",
+					Image: image,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+					},
+				}}},
+			}
+		}
+		c := newApplicationTestClient(app, limits, quota, pod(app.Name+"-a"), pod(app.Name+"-b"))
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)).To(BeNil())
+
+		got.Spec.Autoscaling.Min = 3
+		Expect(c.Update(ctx, &got)).To(Succeed())
+		reconcileApp(r, app)
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		cond := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.InvalidSpec)
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Message).To(ContainSubstring("requests.memory: 768Mi needed, 512Mi left"))
+	})
+})
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+// makeRunHistory scaffolds finished PipelineRuns for the Application, oldest first, one
+// minute apart, with the given outcomes.
+func makeRunHistory(app *platformv1alpha1.Application, outcomes ...corev1.ConditionStatus) []*tektonv1.PipelineRun {
+	c := newApplicationTestClient()
+	base := time.Now().Add(-time.Hour)
+	runs := make([]*tektonv1.PipelineRun, 0, len(outcomes))
+	for i, outcome := range outcomes {
+		reason := "Succeeded"
+		if outcome == corev1.ConditionFalse {
+			reason = "Failed"
+		}
+		run := makePipelineRun(c, app, outcome, reason, nil)
+		run.CreationTimestamp = metav1.NewTime(base.Add(time.Duration(i) * time.Minute))
+		run.UID = types.UID(uuid.NewString())
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func int32Ref(v int32) *int32 { return &v }
+
+// makeRunClaim scaffolds the workspace PVC Tekton creates for a PipelineRun.
+func makeRunClaim(run *tektonv1.PipelineRun) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc-" + uuid.NewString()[:8],
+			Namespace: run.Namespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: tektonv1.SchemeGroupVersion.String(),
+				Kind:       "PipelineRun",
+				Name:       run.Name,
+				UID:        run.UID,
+			}},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+}
+
+var _ = Describe("Application build retention", func() {
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	// exists reports whether the object is still present.
+	exists := func(c client.Client, obj client.Object) bool {
+		err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	It("keeps the last succeeded and failed runs allowed by the Application and deletes the rest with their PVCs", func() {
+		app := makeApplication(ns)
+		app.Spec.Build.Retention = &platformv1alpha1.BuildRetention{
+			SucceededRunsHistoryLimit: int32Ref(2),
+			FailedRunsHistoryLimit:    int32Ref(1),
+		}
+		runs := makeRunHistory(app,
+			corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionTrue,
+			corev1.ConditionFalse, corev1.ConditionTrue, corev1.ConditionTrue)
+		claim := makeRunClaim(runs[0])
+		keptClaim := makeRunClaim(runs[5])
+
+		objs := []client.Object{app, claim, keptClaim}
+		for _, run := range runs {
+			objs = append(objs, run)
+		}
+		c := newApplicationTestClient(objs...)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		kept := map[int]bool{5: true, 4: true, 3: true}
+		for i, run := range runs {
+			Expect(exists(c, run)).To(Equal(kept[i]), "run %d", i)
+		}
+		Expect(exists(c, claim)).To(BeFalse())
+		Expect(exists(c, keptClaim)).To(BeTrue())
+	})
+
+	It("falls back to the operator limits and always keeps the latest run", func() {
+		app := makeApplication(ns)
+		runs := makeRunHistory(app, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse)
+
+		objs := []client.Object{app}
+		for _, run := range runs {
+			objs = append(objs, run)
+		}
+		c := newApplicationTestClient(objs...)
+		r := buildTestApplicationReconciler(c)
+		r.BuildRetention = platformv1alpha1.BuildRetention{FailedRunsHistoryLimit: int32Ref(0)}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(exists(c, runs[0])).To(BeTrue())
+		Expect(exists(c, runs[1])).To(BeFalse())
+		Expect(exists(c, runs[2])).To(BeTrue())
+	})
+})
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+)
+
+var _ = Describe("Application rollback", func() {
+	const (
+		firstImage  = "ghcr.io/example/app@sha256:aaaa"
+		secondImage = "ghcr.io/example/app@sha256:bbbb"
+		firstSHA    = "1111111111111111111111111111111111111111"
+	)
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	// deployTwice reconciles the Application with a first and then a second build landed,
+	// so both images are in its deployment history.
+	deployTwice := func(c client.Client, r *controllerImpl.ApplicationReconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		got.Status.Image = secondImage
+		got.Status.Revision = "2222222222222222222222222222222222222222"
+		Expect(c.Status().Update(ctx, &got)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// rollBack sets (or, with an empty target, removes) the rollback annotation and reconciles.
+	rollBack := func(c client.Client, r *controllerImpl.ApplicationReconciler, app *platformv1alpha1.Application, target string) *platformv1alpha1.Application {
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		if got.Annotations == nil {
+			got.Annotations = map[string]string{}
+		}
+		if target == "" {
+			delete(got.Annotations, platformv1alpha1.RollbackToAnnotation)
+		} else {
+			got.Annotations[platformv1alpha1.RollbackToAnnotation] = target
+		}
+		Expect(c.Update(ctx, &got)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		return &got
+	}
+
+	deployedImage := func(c client.Client, app *platformv1alpha1.Application) string {
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		return deploy.Spec.Template.Spec.Containers[0].Image
+	}
+
+	It("records every image rolled out in the deployment history", func() {
+		app := makeApplication(ns)
+		app.Status.Image = firstImage
+		app.Status.Revision = firstSHA
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		deployTwice(c, r, app)
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(got.Status.Deployments).To(HaveLen(2))
+		Expect(got.Status.Deployments[0].Image).To(Equal(secondImage))
+		Expect(got.Status.Deployments[1].Image).To(Equal(firstImage))
+		Expect(got.Status.Deployments[1].Revision).To(Equal(firstSHA))
+	})
+
+	It("redeploys an earlier revision without building and stays pinned to it", func() {
+		app := makeApplication(ns)
+		app.Status.Image = firstImage
+		app.Status.Revision = firstSHA
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		deployTwice(c, r, app)
+		runsBefore := len(listBuildRuns(ctx, c, app))
+
+		got := rollBack(c, r, app, firstSHA[:7])
+
+		Expect(deployedImage(c, app)).To(Equal(firstImage))
+		Expect(got.Status.Pinned).NotTo(BeNil())
+		Expect(got.Status.Pinned.Image).To(Equal(firstImage))
+		Expect(got.Status.Pinned.Rollback).To(BeTrue())
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Pinned)).To(BeTrue())
+		Expect(got.Status.Deployments[0].Image).To(Equal(firstImage))
+		Expect(got.Status.Deployments[0].Rollback).To(BeTrue())
+		Expect(listBuildRuns(ctx, c, app)).To(HaveLen(runsBefore))
+
+		// a build landing while pinned is recorded but not rolled out
+		got.Status.Image = "ghcr.io/example/app@sha256:cccc"
+		Expect(c.Status().Update(ctx, got)).To(Succeed())
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deployedImage(c, app)).To(Equal(firstImage))
+	})
+
+	It("rolls back to a successful build by name", func() {
+		app := makeApplication(ns)
+		app.Status.Image = secondImage
+		app.Status.Builds = []platformv1alpha1.BuildRecord{
+			{PipelineRun: app.Name + "-build-2", Outcome: platformv1alpha1.BuildOutcomeSucceeded, Image: secondImage},
+			{PipelineRun: app.Name + "-build-1", Outcome: platformv1alpha1.BuildOutcomeSucceeded, Image: firstImage},
+		}
+		app.Annotations = map[string]string{platformv1alpha1.RollbackToAnnotation: app.Name + "-build-1"}
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(deployedImage(c, app)).To(Equal(firstImage))
+	})
+
+	It("deploys the latest image again once the pin is cleared", func() {
+		app := makeApplication(ns)
+		app.Status.Image = firstImage
+		app.Status.Revision = firstSHA
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		deployTwice(c, r, app)
+		rollBack(c, r, app, firstImage)
+
+		got := rollBack(c, r, app, "")
+
+		Expect(deployedImage(c, app)).To(Equal(secondImage))
+		Expect(got.Status.Pinned).To(BeNil())
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Pinned)).To(BeNil())
+	})
+
+	It("sets RollbackFailed and keeps the current deployment for an unknown target", func() {
+		app := makeApplication(ns)
+		app.Status.Image = firstImage
+		app.Status.Revision = firstSHA
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		deployTwice(c, r, app)
+
+		got := rollBack(c, r, app, "ghcr.io/example/app@sha256:ffff")
+
+		Expect(deployedImage(c, app)).To(Equal(secondImage))
+		Expect(got.Status.Pinned).To(BeNil())
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.RollbackFailed)).To(BeTrue())
+	})
+})
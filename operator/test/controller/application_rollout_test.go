@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+)
+
+var _ = Describe("Application progressive rollout", func() {
+	const (
+		stableImage = "ghcr.io/example/app@sha256:aaaa"
+		newImage    = "ghcr.io/example/app@sha256:bbbb"
+	)
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	reconcileApp := func(r *controllerImpl.ApplicationReconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getApp := func(c client.Client, app *platformv1alpha1.Application) *platformv1alpha1.Application {
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		return &got
+	}
+
+	getDeployment := func(c client.Client, name string) *appsv1.Deployment {
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &deploy)).To(Succeed())
+		return &deploy
+	}
+
+	gone := func(c client.Client, obj client.Object, name string) bool {
+		err := c.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, obj)
+		return errors.IsNotFound(err)
+	}
+
+	// markReady reports every replica of the Deployment as updated and available.
+	markReady := func(c client.Client, name string) {
+		deploy := getDeployment(c, name)
+		deploy.Status.ObservedGeneration = deploy.Generation
+		deploy.Status.Replicas = *deploy.Spec.Replicas
+		deploy.Status.UpdatedReplicas = *deploy.Spec.Replicas
+		deploy.Status.AvailableReplicas = *deploy.Spec.Replicas
+		Expect(c.Status().Update(ctx, deploy)).To(Succeed())
+	}
+
+	// startRollout deploys stableImage, enables the rollout strategy and lands newImage.
+	startRollout := func(c client.Client, r *controllerImpl.ApplicationReconciler, app *platformv1alpha1.Application, rollout *platformv1alpha1.RolloutStrategy) {
+		reconcileApp(r, app)
+		markReady(c, app.Name)
+
+		got := getApp(c, app)
+		got.Spec.Rollout = rollout
+		Expect(c.Update(ctx, got)).To(Succeed())
+		got.Status.Image = newImage
+		Expect(c.Status().Update(ctx, got)).To(Succeed())
+		reconcileApp(r, app)
+	}
+
+	promote := func(c client.Client, app *platformv1alpha1.Application) {
+		got := getApp(c, app)
+		got.Annotations = map[string]string{platformv1alpha1.PromoteRequestedAtAnnotation: time.Now().Format(time.RFC3339Nano)}
+		Expect(c.Update(ctx, got)).To(Succeed())
+	}
+
+	It("shifts traffic to a canary in steps and promotes it after the last one", func() {
+		app := makeApplication(ns)
+		app.Status.Image = stableImage
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		startRollout(c, r, app, &platformv1alpha1.RolloutStrategy{
+			Canary: &platformv1alpha1.CanaryStrategy{Steps: []platformv1alpha1.CanaryStep{
+				{Weight: 25},
+				{Weight: 50, Pause: &metav1.Duration{Duration: time.Millisecond}},
+			}},
+		})
+
+		Expect(getDeployment(c, app.Name).Spec.Template.Spec.Containers[0].Image).To(Equal(stableImage))
+		canary := getDeployment(c, app.Name+"-canary")
+		Expect(canary.Spec.Template.Spec.Containers[0].Image).To(Equal(newImage))
+		Expect(canary.Spec.Template.Labels).To(HaveKeyWithValue(platformv1alpha1.RolloutTrackLabel, "canary"))
+		Expect(*canary.Spec.Replicas).To(BeNumerically("==", 1))
+		var svc corev1.Service
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &svc)).To(Succeed())
+		Expect(svc.Spec.Selector).NotTo(HaveKey(platformv1alpha1.RolloutTrackLabel))
+
+		got := getApp(c, app)
+		Expect(got.Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhaseProgressing))
+		Expect(got.Status.Rollout.Weight).To(BeNumerically("==", 25))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.RollingOut)).To(BeTrue())
+
+		// the first step has no pause, it waits for a promotion once the canary is ready
+		markReady(c, app.Name+"-canary")
+		reconcileApp(r, app)
+		Expect(getApp(c, app).Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhasePaused))
+
+		promote(c, app)
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(got.Status.Rollout.Step).To(BeNumerically("==", 1))
+		Expect(got.Status.Rollout.Weight).To(BeNumerically("==", 50))
+		Expect(*getDeployment(c, app.Name+"-canary").Spec.Replicas).To(BeNumerically("==", 2))
+
+		// the pause of the last step elapses, the new image goes to the stable Deployment
+		markReady(c, app.Name+"-canary")
+		reconcileApp(r, app)
+		Expect(getApp(c, app).Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhasePromoted))
+		Expect(getDeployment(c, app.Name).Spec.Template.Spec.Containers[0].Image).To(Equal(newImage))
+
+		markReady(c, app.Name)
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(got.Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhaseSucceeded))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.RollingOut)).To(BeFalse())
+		Expect(got.Status.Deployments[0].Image).To(Equal(newImage))
+		Expect(gone(c, &appsv1.Deployment{}, app.Name+"-canary")).To(BeTrue())
+	})
+
+	It("keeps a blue-green preview out of the Service until promoted", func() {
+		app := makeApplication(ns)
+		app.Status.Image = stableImage
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		startRollout(c, r, app, &platformv1alpha1.RolloutStrategy{BlueGreen: &platformv1alpha1.BlueGreenStrategy{}})
+
+		preview := getDeployment(c, app.Name+"-preview")
+		Expect(preview.Spec.Template.Spec.Containers[0].Image).To(Equal(newImage))
+		Expect(*preview.Spec.Replicas).To(BeNumerically("==", 2))
+		var svc, previewSvc corev1.Service
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &svc)).To(Succeed())
+		Expect(svc.Spec.Selector).To(HaveKeyWithValue(platformv1alpha1.RolloutTrackLabel, "stable"))
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: app.Name + "-preview"}, &previewSvc)).To(Succeed())
+		Expect(previewSvc.Spec.Selector).To(HaveKeyWithValue(platformv1alpha1.RolloutTrackLabel, "preview"))
+
+		markReady(c, app.Name+"-preview")
+		reconcileApp(r, app)
+		Expect(getApp(c, app).Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhasePaused))
+		Expect(getDeployment(c, app.Name).Spec.Template.Spec.Containers[0].Image).To(Equal(stableImage))
+
+		promote(c, app)
+		reconcileApp(r, app)
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &svc)).To(Succeed())
+		Expect(svc.Spec.Selector).To(HaveKeyWithValue(platformv1alpha1.RolloutTrackLabel, "preview"))
+		Expect(getDeployment(c, app.Name).Spec.Template.Spec.Containers[0].Image).To(Equal(newImage))
+
+		markReady(c, app.Name)
+		reconcileApp(r, app)
+		Expect(getApp(c, app).Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhaseSucceeded))
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &svc)).To(Succeed())
+		Expect(svc.Spec.Selector).NotTo(HaveKey(platformv1alpha1.RolloutTrackLabel))
+		Expect(gone(c, &appsv1.Deployment{}, app.Name+"-preview")).To(BeTrue())
+		Expect(gone(c, &corev1.Service{}, app.Name+"-preview")).To(BeTrue())
+	})
+
+	It("aborts when the new pods keep restarting", func() {
+		app := makeApplication(ns)
+		app.Status.Image = stableImage
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		startRollout(c, r, app, &platformv1alpha1.RolloutStrategy{
+			Canary: &platformv1alpha1.CanaryStrategy{Steps: []platformv1alpha1.CanaryStep{{Weight: 10}}},
+		})
+		Expect(c.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      app.Name + "-canary-abcde",
+				Namespace: ns,
+				Labels:    map[string]string{platformv1alpha1.ApplicationLabel: app.Name, platformv1alpha1.RolloutTrackLabel: "canary"},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: newImage}}},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "app",
+				RestartCount: 4,
+			}}},
+		})).To(Succeed())
+
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		Expect(got.Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhaseAborted))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.RolloutAborted)).To(BeTrue())
+		Expect(gone(c, &appsv1.Deployment{}, app.Name+"-canary")).To(BeTrue())
+		Expect(getDeployment(c, app.Name).Spec.Template.Spec.Containers[0].Image).To(Equal(stableImage))
+
+		// the aborted image is not tried again
+		reconcileApp(r, app)
+		Expect(gone(c, &appsv1.Deployment{}, app.Name+"-canary")).To(BeTrue())
+	})
+
+	It("aborts when the new pods are not ready within the readiness timeout", func() {
+		app := makeApplication(ns)
+		app.Status.Image = stableImage
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		startRollout(c, r, app, &platformv1alpha1.RolloutStrategy{
+			BlueGreen:        &platformv1alpha1.BlueGreenStrategy{},
+			ReadinessTimeout: &metav1.Duration{Duration: time.Nanosecond},
+		})
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		Expect(got.Status.Rollout.Phase).To(Equal(platformv1alpha1.RolloutPhaseAborted))
+		Expect(got.Status.Rollout.Message).To(ContainSubstring("not ready"))
+		Expect(gone(c, &appsv1.Deployment{}, app.Name+"-preview")).To(BeTrue())
+	})
+})
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+var _ = Describe("Application routes", func() {
+	const image = "ghcr.io/example/app@sha256:aaaa"
+
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	reconcileApp := func(r reconcile.Reconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getApp := func(c client.Client, app *platformv1alpha1.Application) *platformv1alpha1.Application {
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		return &got
+	}
+
+	// deployedApp scaffolds an Application with an image, created at the given time.
+	deployedApp := func(created time.Time, routes ...platformv1alpha1.Route) *platformv1alpha1.Application {
+		app := makeApplication("proj-" + uuid.NewString()[:8])
+		app.CreationTimestamp = metav1.NewTime(created)
+		app.Status.Image = image
+		app.Spec.Routes = routes
+		return app
+	}
+
+	It("serves the default hostname and the routes of an Application", func() {
+		app := deployedApp(time.Now(),
+			platformv1alpha1.Route{Host: "Shop.Example.com"},
+			platformv1alpha1.Route{Host: "shop.example.com", Path: "/metrics", Port: 9090},
+		)
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		r.AppsDomain = "apps.example.com"
+		reconcileApp(r, app)
+
+		defaultHost := app.Name + "-" + app.Namespace + ".apps.example.com"
+		var ingress networkingv1.Ingress
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &ingress)).To(Succeed())
+		Expect(ingress.Spec.Rules).To(HaveLen(2))
+		Expect(ingress.Spec.Rules[0].Host).To(Equal(defaultHost))
+		Expect(ingress.Spec.Rules[1].Host).To(Equal("shop.example.com"))
+		paths := ingress.Spec.Rules[1].HTTP.Paths
+		Expect(paths).To(HaveLen(2))
+		Expect(paths[0].Backend.Service.Port.Name).To(Equal("http"))
+		Expect(paths[1].Path).To(Equal("/metrics"))
+		Expect(paths[1].Backend.Service.Port.Number).To(BeNumerically("==", 9090))
+		Expect(ingress.Spec.TLS).To(BeEmpty())
+
+		var svc corev1.Service
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &svc)).To(Succeed())
+		Expect(svc.Spec.Ports).To(HaveLen(2))
+		Expect(svc.Spec.Ports[1].Port).To(BeNumerically("==", 9090))
+
+		got := getApp(c, app)
+		Expect(got.Status.URL).To(Equal("http://" + defaultHost))
+		Expect(got.Status.Routes).To(ContainElement(platformv1alpha1.RouteStatus{
+			Host: "shop.example.com", Path: "/metrics", URL: "http://shop.example.com/metrics",
+		}))
+	})
+
+	It("requests a certificate for every hostname when a ClusterIssuer is configured", func() {
+		app := deployedApp(time.Now(), platformv1alpha1.Route{Host: "shop.example.com"})
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		r.AppsDomain = "apps.example.com"
+		r.ClusterIssuer = "letsencrypt-prod"
+		reconcileApp(r, app)
+
+		defaultHost := app.Name + "-" + app.Namespace + ".apps.example.com"
+		cert := &unstructured.Unstructured{}
+		cert.SetGroupVersionKind(schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"})
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: app.Name + "-tls"}, cert)).To(Succeed())
+		dnsNames, _, _ := unstructured.NestedStringSlice(cert.Object, "spec", "dnsNames")
+		Expect(dnsNames).To(Equal([]string{defaultHost, "shop.example.com"}))
+		issuer, _, _ := unstructured.NestedString(cert.Object, "spec", "issuerRef", "name")
+		Expect(issuer).To(Equal("letsencrypt-prod"))
+
+		var ingress networkingv1.Ingress
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &ingress)).To(Succeed())
+		Expect(ingress.Spec.TLS).To(Equal([]networkingv1.IngressTLS{{
+			Hosts:      []string{defaultHost, "shop.example.com"},
+			SecretName: app.Name + "-tls",
+		}}))
+		Expect(getApp(c, app).Status.URL).To(Equal("https://" + defaultHost))
+	})
+
+	It("reports a route already served by an older Application of another project", func() {
+		older := deployedApp(time.Now().Add(-time.Hour), platformv1alpha1.Route{Host: "shop.example.com"})
+		app := deployedApp(time.Now(),
+			platformv1alpha1.Route{Host: "shop.example.com"},
+			platformv1alpha1.Route{Host: "shop.example.com", Path: "/api"},
+		)
+		c := newApplicationTestClient(older, app)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		cond := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.RouteConflict)
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Message).To(ContainSubstring(older.Namespace + "/" + older.Name))
+		Expect(got.Status.Routes[0].ConflictsWith).To(Equal(older.Namespace + "/" + older.Name))
+		Expect(got.Status.URL).To(Equal("http://shop.example.com/api"))
+		var ingress networkingv1.Ingress
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &ingress)).To(Succeed())
+		Expect(ingress.Spec.Rules).To(HaveLen(1))
+		Expect(ingress.Spec.Rules[0].HTTP.Paths).To(HaveLen(1))
+		Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Path).To(Equal("/api"))
+
+		// the older Application keeps its route
+		reconcileApp(r, older)
+		Expect(apimeta.FindStatusCondition(getApp(c, older).Status.Conditions, platformv1alpha1.RouteConflict)).To(BeNil())
+
+		// once released, the route is served by the newer Application
+		released := getApp(c, older)
+		released.Spec.Routes = nil
+		Expect(c.Update(ctx, released)).To(Succeed())
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.RouteConflict)).To(BeNil())
+		Expect(got.Status.URL).To(Equal("http://shop.example.com"))
+	})
+
+	It("does not let a route take the default hostname of another Application", func() {
+		other := deployedApp(time.Now())
+		app := deployedApp(time.Now().Add(-time.Hour),
+			platformv1alpha1.Route{Host: other.Name + "-" + other.Namespace + ".apps.example.com", Path: "/admin"},
+		)
+		c := newApplicationTestClient(other, app)
+		r := buildTestApplicationReconciler(c)
+		r.AppsDomain = "apps.example.com"
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.RouteConflict)).To(BeTrue())
+		Expect(got.Status.Routes).To(HaveLen(2))
+		Expect(got.Status.Routes[1].ConflictsWith).To(Equal(other.Namespace + "/" + other.Name))
+	})
+})
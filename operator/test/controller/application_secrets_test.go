@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/secretstore"
+	"github.com/mofe64/vulkan/operator/internal/utils"
+	"github.com/mofe64/vulkan/operator/pkg/secretbox"
+)
+
+var _ = Describe("Application secrets", func() {
+	const image = "ghcr.io/example/app@sha256:aaaa"
+
+	var (
+		ctx context.Context
+		ns  string
+		db  *sql.DB
+		key []byte
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+		key = make([]byte, secretbox.KeySize)
+		copy(key, "0123456789abcdef0123456789abcdef")
+
+		var err error
+		db, err = utils.ConnectDB(filepath.Join(GinkgoT().TempDir(), "secrets.db"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(db.Close)
+		_, err = db.ExecContext(ctx, `
+			CREATE TABLE app_secrets (
+				namespace  TEXT NOT NULL,
+				app        TEXT NOT NULL,
+				name       TEXT NOT NULL,
+				value      BLOB NOT NULL,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (namespace, app, name)
+			)
+		`)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	storeSecret := func(app, name, value string) {
+		sealed, err := secretbox.Seal(key, []byte(value), secretbox.AdditionalData(ns, app, name))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.ExecContext(ctx, `INSERT INTO app_secrets (namespace, app, name, value) VALUES ($1, $2, $3, $4)`,
+			ns, app, name, sealed)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	reconcileApp := func(r reconcile.Reconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getDeployment := func(c client.Client, name string) *appsv1.Deployment {
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &deploy)).To(Succeed())
+		return &deploy
+	}
+
+	It("renders env references to Secrets and ConfigMaps and envFrom sources", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Spec.Env = append(app.Spec.Env,
+			platformv1alpha1.EnvVar{Name: "DB_PASSWORD", ValueFrom: &platformv1alpha1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "db"},
+					Key:                  "password",
+				},
+			}},
+			platformv1alpha1.EnvVar{Name: "FEATURES", ValueFrom: &platformv1alpha1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "flags"},
+					Key:                  "features",
+				},
+			}},
+		)
+		app.Spec.EnvFrom = []platformv1alpha1.EnvFromSource{{
+			Prefix:    "STRIPE_",
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "stripe"}},
+		}}
+		c := newApplicationTestClient(app)
+		reconcileApp(buildTestApplicationReconciler(c), app)
+
+		container := getDeployment(c, app.Name).Spec.Template.Spec.Containers[0]
+		Expect(container.Env).To(HaveLen(3))
+		Expect(container.Env[0]).To(Equal(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		Expect(container.Env[1].ValueFrom.SecretKeyRef.Name).To(Equal("db"))
+		Expect(container.Env[2].ValueFrom.ConfigMapKeyRef.Key).To(Equal("features"))
+		Expect(container.EnvFrom).To(Equal([]corev1.EnvFromSource{{
+			Prefix:    "STRIPE_",
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "stripe"}},
+		}}))
+	})
+
+	It("syncs managed secrets into an owned Secret and restarts pods when they change", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Annotations = map[string]string{platformv1alpha1.SecretsVersionAnnotation: "1"}
+		storeSecret(app.Name, "API_TOKEN", "s3cr3t")
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		r.Secrets = secretstore.NewSQL(db, key)
+
+		reconcileApp(r, app)
+
+		var secret corev1.Secret
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: app.Name + "-env"}, &secret)).To(Succeed())
+		Expect(secret.Data).To(Equal(map[string][]byte{"API_TOKEN": []byte("s3cr3t")}))
+		Expect(secret.OwnerReferences).To(HaveLen(1))
+		deploy := getDeployment(c, app.Name)
+		Expect(deploy.Spec.Template.Spec.Containers[0].EnvFrom).To(ContainElement(corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: app.Name + "-env"}},
+		}))
+		Expect(deploy.Spec.Template.Annotations).To(HaveKeyWithValue(platformv1alpha1.SecretsVersionAnnotation, "1"))
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(fmt.Sprintf("%+v", got.Status)).NotTo(ContainSubstring("s3cr3t"))
+
+		storeSecret(app.Name, "SIGNING_KEY", "k3y")
+		got.Annotations[platformv1alpha1.SecretsVersionAnnotation] = "2"
+		Expect(c.Update(ctx, &got)).To(Succeed())
+		reconcileApp(r, app)
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(&secret), &secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("SIGNING_KEY", []byte("k3y")))
+		Expect(getDeployment(c, app.Name).Spec.Template.Annotations).To(HaveKeyWithValue(platformv1alpha1.SecretsVersionAnnotation, "2"))
+	})
+
+	It("gives previews the managed secrets of their parent", func() {
+		parent := makeApplication(ns)
+		parent.Spec.Previews = &platformv1alpha1.PreviewConfig{}
+		preview := makePreview(parent, 1, time.Now())
+		preview.Status.Image = image
+		preview.Annotations = map[string]string{platformv1alpha1.SecretsVersionAnnotation: "1"}
+		storeSecret(parent.Name, "API_TOKEN", "s3cr3t")
+		c := newApplicationTestClient(parent, preview)
+		r := buildTestApplicationReconciler(c)
+		r.Secrets = secretstore.NewSQL(db, key)
+
+		reconcileApp(r, preview)
+
+		var secret corev1.Secret
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: preview.Name + "-env"}, &secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("API_TOKEN", []byte("s3cr3t")))
+	})
+
+	It("removes the managed Secret once the Application has no managed secrets", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Annotations = map[string]string{platformv1alpha1.SecretsVersionAnnotation: "1"}
+		storeSecret(app.Name, "API_TOKEN", "s3cr3t")
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		r.Secrets = secretstore.NewSQL(db, key)
+		reconcileApp(r, app)
+
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		delete(got.Annotations, platformv1alpha1.SecretsVersionAnnotation)
+		Expect(c.Update(ctx, &got)).To(Succeed())
+		reconcileApp(r, app)
+
+		err := c.Get(ctx, client.ObjectKey{Namespace: ns, Name: app.Name + "-env"}, &corev1.Secret{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		deploy := getDeployment(c, app.Name)
+		Expect(deploy.Spec.Template.Spec.Containers[0].EnvFrom).To(BeEmpty())
+		Expect(deploy.Spec.Template.Annotations).NotTo(HaveKey(platformv1alpha1.SecretsVersionAnnotation))
+	})
+
+	It("keeps the managed Secret and flags the Application while no secret store is configured", func() {
+		app := makeApplication(ns)
+		app.Status.Image = image
+		app.Annotations = map[string]string{platformv1alpha1.SecretsVersionAnnotation: "1"}
+		storeSecret(app.Name, "API_TOKEN", "s3cr3t")
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		r.Secrets = secretstore.NewSQL(db, key)
+		reconcileApp(r, app)
+
+		// the operator restarted without a database
+		r.Secrets = nil
+		reconcileApp(r, app)
+
+		var secret corev1.Secret
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ns, Name: app.Name + "-env"}, &secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("API_TOKEN", []byte("s3cr3t")))
+		deploy := getDeployment(c, app.Name)
+		Expect(deploy.Spec.Template.Spec.Containers[0].EnvFrom).To(ContainElement(corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: app.Name + "-env"}},
+		}))
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		unavailable := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.SecretsUnavailable)
+		Expect(unavailable).NotTo(BeNil())
+		Expect(unavailable.Status).To(Equal(metav1.ConditionTrue))
+
+		r.Secrets = secretstore.NewSQL(db, key)
+		reconcileApp(r, app)
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.SecretsUnavailable)).To(BeNil())
+	})
+
+	It("fails to sync a secret sealed for another Application", func() {
+		other := makeApplication(ns)
+		sealed, err := secretbox.Seal(key, []byte("s3cr3t"), secretbox.AdditionalData(ns, other.Name, "API_TOKEN"))
+		Expect(err).NotTo(HaveOccurred())
+		app := makeApplication(ns)
+		_, err = db.ExecContext(ctx, `INSERT INTO app_secrets (namespace, app, name, value) VALUES ($1, $2, $3, $4)`,
+			ns, app.Name, "API_TOKEN", sealed)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = secretstore.NewSQL(db, key).Values(ctx, ns, app.Name)
+		Expect(err).To(MatchError(secretbox.ErrCorrupt))
+		Expect(err.Error()).NotTo(ContainSubstring("s3cr3t"))
+	})
+})
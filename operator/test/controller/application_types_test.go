@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+)
+
+var _ = Describe("Application types", func() {
+	const (
+		imageV1 = "ghcr.io/example/app@sha256:aaaa"
+		imageV2 = "ghcr.io/example/app@sha256:bbbb"
+	)
+
+	var (
+		ctx context.Context
+		ns  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ns = "proj-" + uuid.NewString()[:8]
+	})
+
+	reconcileApp := func(r reconcile.Reconciler, app *platformv1alpha1.Application) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getApp := func(c client.Client, app *platformv1alpha1.Application) *platformv1alpha1.Application {
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		return &got
+	}
+
+	setImage := func(c client.Client, app *platformv1alpha1.Application, image string) {
+		got := getApp(c, app)
+		got.Status.Image = image
+		Expect(c.Status().Update(ctx, got)).To(Succeed())
+	}
+
+	// jobs lists the Jobs of the namespace.
+	jobs := func(c client.Client) []batchv1.Job {
+		var list batchv1.JobList
+		Expect(c.List(ctx, &list, client.InNamespace(ns))).To(Succeed())
+		return list.Items
+	}
+
+	// finishJob marks a Job as complete, or as failed.
+	finishJob := func(c client.Client, job *batchv1.Job, succeeded bool) {
+		condition := batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()}
+		if !succeeded {
+			condition = batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit", LastTransitionTime: metav1.Now()}
+		}
+		now := metav1.Now()
+		job.Status.Conditions = append(job.Status.Conditions, condition)
+		if succeeded {
+			job.Status.CompletionTime = &now
+		}
+		Expect(c.Status().Update(ctx, job)).To(Succeed())
+	}
+
+	It("runs a worker as a Deployment without a Service or routes", func() {
+		app := makeApplication(ns)
+		app.Status.Image = imageV1
+		app.Spec.Type = platformv1alpha1.ApplicationTypeWorker
+		app.Spec.Command = []string{"/bin/worker"}
+		app.Spec.Args = []string{"--queue", "emails"}
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		r.AppsDomain = "apps.example.com"
+		reconcileApp(r, app)
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		container := deploy.Spec.Template.Spec.Containers[0]
+		Expect(container.Command).To(Equal([]string{"/bin/worker"}))
+		Expect(container.Args).To(Equal([]string{"--queue", "emails"}))
+		err := c.Get(ctx, client.ObjectKeyFromObject(app), &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &networkingv1.Ingress{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(getApp(c, app).Status.URL).To(BeEmpty())
+	})
+
+	It("replaces the Deployment of a web Application turned into a cron Application by a CronJob", func() {
+		app := makeApplication(ns)
+		app.Status.Image = imageV1
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})).To(Succeed())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &corev1.Service{})).To(Succeed())
+
+		got := getApp(c, app)
+		got.Spec.Type = platformv1alpha1.ApplicationTypeCron
+		got.Spec.Schedule = "*/15 * * * *"
+		Expect(c.Update(ctx, got)).To(Succeed())
+		reconcileApp(r, app)
+
+		var cron batchv1.CronJob
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &cron)).To(Succeed())
+		Expect(cron.Spec.Schedule).To(Equal("*/15 * * * *"))
+		Expect(cron.Spec.ConcurrencyPolicy).To(Equal(batchv1.ForbidConcurrent))
+		podSpec := cron.Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(podSpec.Containers[0].Image).To(Equal(imageV1))
+		Expect(podSpec.Containers[0].Ports).To(BeEmpty())
+		Expect(cron.Spec.JobTemplate.Spec.Template.Labels).NotTo(HaveKey(platformv1alpha1.ApplicationLabel))
+
+		err := c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = c.Get(ctx, client.ObjectKeyFromObject(app), &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		got = getApp(c, app)
+		ready := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Status).To(Equal(metav1.ConditionTrue))
+		Expect(ready.Reason).To(Equal("Scheduled"))
+	})
+
+	It("runs a job Application once per image", func() {
+		app := makeApplication(ns)
+		app.Status.Image = imageV1
+		app.Spec.Type = platformv1alpha1.ApplicationTypeJob
+		backoff := int32(2)
+		app.Spec.Job.BackoffLimit = &backoff
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+		reconcileApp(r, app)
+
+		items := jobs(c)
+		Expect(items).To(HaveLen(1))
+		first := items[0]
+		Expect(*first.Spec.BackoffLimit).To(BeNumerically("==", 2))
+		Expect(first.Spec.TTLSecondsAfterFinished).NotTo(BeNil())
+		Expect(first.Spec.Template.Spec.Containers[0].Image).To(Equal(imageV1))
+		got := getApp(c, app)
+		Expect(got.Status.Job.Phase).To(Equal(platformv1alpha1.JobPhaseRunning))
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready).Reason).To(Equal("JobRunning"))
+
+		finishJob(c, &first, true)
+		reconcileApp(r, app)
+		got = getApp(c, app)
+		Expect(got.Status.Job.Phase).To(Equal(platformv1alpha1.JobPhaseSucceeded))
+		Expect(got.Status.Health).To(Equal(platformv1alpha1.HealthHealthy))
+		Expect(got.Status.Deployments).To(HaveLen(1))
+
+		// a finished Job is not run again, even once it has been deleted
+		Expect(c.Delete(ctx, &first)).To(Succeed())
+		reconcileApp(r, app)
+		Expect(jobs(c)).To(BeEmpty())
+
+		setImage(c, app, imageV2)
+		reconcileApp(r, app)
+		items = jobs(c)
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Name).NotTo(Equal(first.Name))
+		Expect(items[0].Spec.Template.Spec.Containers[0].Image).To(Equal(imageV2))
+	})
+
+	It("gates the rollout of a new image on the pre-deploy hook", func() {
+		app := makeApplication(ns)
+		app.Status.Image = imageV1
+		app.Spec.PreDeploy = &platformv1alpha1.PreDeployHook{Command: []string{"bin/migrate"}}
+		c := newApplicationTestClient(app)
+		r := buildTestApplicationReconciler(c)
+
+		// the first deployment waits for the hook as well
+		reconcileApp(r, app)
+		err := c.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		items := jobs(c)
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Spec.Template.Spec.Containers[0].Command).To(Equal([]string{"bin/migrate"}))
+		finishJob(c, &items[0], true)
+		reconcileApp(r, app)
+
+		var deploy appsv1.Deployment
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(imageV1))
+
+		// a failed hook keeps the previous image running
+		setImage(c, app, imageV2)
+		reconcileApp(r, app)
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(imageV1))
+		items = jobs(c)
+		Expect(items).To(HaveLen(2))
+		hook := items[0]
+		if hook.Spec.Template.Spec.Containers[0].Image != imageV2 {
+			hook = items[1]
+		}
+		Expect(hook.Spec.Template.Spec.Containers[0].Image).To(Equal(imageV2))
+		finishJob(c, &hook, false)
+		reconcileApp(r, app)
+
+		got := getApp(c, app)
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.JobFailed)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Message).To(ContainSubstring("backoff limit"))
+		Expect(got.Status.Job.Image).To(Equal(imageV2))
+		Expect(got.Status.Deployments[0].Image).To(Equal(imageV1))
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &deploy)).To(Succeed())
+		Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal(imageV1))
+	})
+})
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+)
+
+var _ = Describe("Cluster health", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	reconcileCluster := func(r reconcile.Reconciler, clu *platformv1alpha1.Cluster) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getCluster := func(c client.Client, clu *platformv1alpha1.Cluster) *platformv1alpha1.Cluster {
+		var got platformv1alpha1.Cluster
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clu), &got)).To(Succeed())
+		return &got
+	}
+
+	// sizedNode is a ready node running the kubelet version with the allocatable resources.
+	sizedNode := func(name, kubelet, cpu, memory string) *corev1.Node {
+		node := readyNode(name, "")
+		node.Status.NodeInfo.KubeletVersion = kubelet
+		node.Status.Allocatable = corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}
+		return node
+	}
+
+	// systemPod is a kube-system pod on node-1 with the labels, ready or not.
+	systemPod := func(name string, labels map[string]string, ready bool) *corev1.Pod {
+		status := corev1.ConditionTrue
+		if !ready {
+			status = corev1.ConditionFalse
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem, Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{Name: "main", Image: name}}},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+			},
+		}
+	}
+
+	newReconciler := func(clu *platformv1alpha1.Cluster, target client.Client, org *platformv1alpha1.Org) (*controllerImpl.ClusterReconciler, client.Client) {
+		secret := &corev1.Secret{}
+		secret.Name, secret.Namespace = "kubeconfig", clu.Spec.KubeconfigSecretNamespace
+		c := newClusterTestClient(org, clu, secret)
+		return &controllerImpl.ClusterReconciler{
+			Client:        c,
+			Scheme:        c.Scheme(),
+			TargetFactory: clusterClients{clu.Name: target},
+		}, c
+	}
+
+	It("reports the version, capacity, pressure and components of the cluster", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+
+		pressured := sizedNode("node-2", "v1.29.4", "2", "4Gi")
+		pressured.Status.Conditions = append(pressured.Status.Conditions,
+			corev1.NodeCondition{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+			corev1.NodeCondition{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse})
+		app := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: corev1.PodSpec{NodeName: "node-2", Containers: []corev1.Container{{
+				Name:  "main",
+				Image: "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				}},
+			}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		finished := app.DeepCopy()
+		finished.Name, finished.Status.Phase = "finished", corev1.PodSucceeded
+		target := newClusterTestClient(
+			sizedNode("node-1", "v1.30.2", "4", "8Gi"), pressured, app, finished,
+			systemPod("kube-apiserver-node-1", map[string]string{"tier": "control-plane", "component": "kube-apiserver"}, true),
+			systemPod("coredns-1", map[string]string{"k8s-app": "kube-dns"}, true),
+		)
+		r, c := newReconciler(clu, target, org)
+		reconcileCluster(r, clu)
+
+		status := getCluster(c, clu).Status
+		Expect(status.KubernetesVersion).To(Equal("v1.29.4"))
+		Expect(status.Nodes).To(BeNumerically("==", 2))
+		Expect(status.ReadyNodes).To(BeNumerically("==", 2))
+		Expect(status.Allocatable.Cpu().Equal(resource.MustParse("6"))).To(BeTrue())
+		Expect(status.Allocatable.Memory().Equal(resource.MustParse("12Gi"))).To(BeTrue())
+		Expect(status.Requested.Cpu().Equal(resource.MustParse("500m"))).To(BeTrue())
+		Expect(status.Requested.Memory().Equal(resource.MustParse("1Gi"))).To(BeTrue())
+		Expect(status.Pressure).To(Equal([]platformv1alpha1.NodePressure{{Node: "node-2", Conditions: []string{"DiskPressure"}}}))
+		Expect(status.Components).To(Equal([]platformv1alpha1.ComponentHealth{
+			{Name: "dns", Healthy: true},
+			{Name: "kube-apiserver", Healthy: true},
+		}))
+		Expect(apimeta.IsStatusConditionTrue(status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+		Expect(apimeta.IsStatusConditionFalse(status.Conditions, platformv1alpha1.Degraded)).To(BeTrue())
+	})
+
+	It("marks the cluster Degraded instead of failed when too few nodes are ready", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		notReady := readyNode("node-2", "")
+		notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+		target := newClusterTestClient(readyNode("node-1", ""), notReady)
+		r, c := newReconciler(clu, target, org)
+		reconcileCluster(r, clu)
+
+		status := getCluster(c, clu).Status
+		Expect(status.ReadyNodes).To(BeNumerically("==", 1))
+		Expect(apimeta.IsStatusConditionTrue(status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+		Expect(apimeta.IsStatusConditionFalse(status.Conditions, platformv1alpha1.Error)).To(BeTrue())
+		degraded := apimeta.FindStatusCondition(status.Conditions, platformv1alpha1.Degraded)
+		Expect(degraded).NotTo(BeNil())
+		Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+		Expect(degraded.Reason).To(Equal("NodesNotReady"))
+
+		// a lower threshold tolerates the missing node
+		r.ReadyNodeThreshold = 50
+		reconcileCluster(r, clu)
+		status = getCluster(c, clu).Status
+		Expect(apimeta.IsStatusConditionFalse(status.Conditions, platformv1alpha1.Degraded)).To(BeTrue())
+	})
+
+	It("reads the pods of attached clusters through the API reader", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "attached")
+		secret := &corev1.Secret{}
+		secret.Name, secret.Namespace = "kubeconfig", clu.Spec.KubeconfigSecretNamespace
+		c := newClusterTestClient(org, clu, secret, sizedNode("node-1", "v1.30.2", "4", "8Gi"))
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{
+				Name:      "main",
+				Image:     "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")}},
+			}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		r := &controllerImpl.ClusterReconciler{Client: c, Scheme: c.Scheme(), APIReader: newClusterTestClient(pod)}
+		reconcileCluster(r, clu)
+
+		status := getCluster(c, clu).Status
+		Expect(status.Requested.Cpu().Equal(resource.MustParse("250m"))).To(BeTrue())
+		Expect(apimeta.IsStatusConditionTrue(status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+	})
+
+	It("marks the cluster Degraded when a control plane component is not ready", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		target := newClusterTestClient(readyNode("node-1", ""),
+			systemPod("etcd-node-1", map[string]string{"tier": "control-plane", "component": "etcd"}, false))
+		r, c := newReconciler(clu, target, org)
+		reconcileCluster(r, clu)
+
+		status := getCluster(c, clu).Status
+		Expect(status.Components).To(HaveLen(1))
+		Expect(status.Components[0].Healthy).To(BeFalse())
+		Expect(status.Components[0].Message).To(ContainSubstring("etcd-node-1"))
+		Expect(apimeta.FindStatusCondition(status.Conditions, platformv1alpha1.Degraded).Reason).To(Equal("ComponentUnhealthy"))
+	})
+})
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+	"github.com/mofe64/vulkan/operator/internal/metrics"
+)
+
+var _ = Describe("Cluster heartbeat", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	getCluster := func(c client.Client, clu *platformv1alpha1.Cluster) *platformv1alpha1.Cluster {
+		var got platformv1alpha1.Cluster
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clu), &got)).To(Succeed())
+		return &got
+	}
+
+	// reachedCluster is a remote cluster last reached at heartbeat while Ready.
+	reachedCluster := func(org *platformv1alpha1.Org, heartbeat time.Time) *platformv1alpha1.Cluster {
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		clu.Finalizers = []string{platformv1alpha1.ClusterFinalizer}
+		clu.Status.LastHeartbeatTime = &metav1.Time{Time: heartbeat}
+		clu.Status.Conditions = []metav1.Condition{{
+			Type:               platformv1alpha1.Ready,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Reconciled",
+			LastTransitionTime: metav1.NewTime(heartbeat),
+		}}
+		return clu
+	}
+
+	// unreachableCluster is a cluster that missed its heartbeat.
+	unreachableCluster := func(name string) *platformv1alpha1.Cluster {
+		return &platformv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       platformv1alpha1.ClusterSpec{Type: "remote"},
+			Status: platformv1alpha1.ClusterStatus{Conditions: []metav1.Condition{{
+				Type:               platformv1alpha1.Ready,
+				Status:             metav1.ConditionUnknown,
+				Reason:             "HeartbeatMissed",
+				Message:            "No heartbeat since yesterday",
+				LastTransitionTime: metav1.Now(),
+			}}},
+		}
+	}
+
+	newReconciler := func(clu *platformv1alpha1.Cluster, org *platformv1alpha1.Org, targets clusterClients) (*controllerImpl.ClusterReconciler, client.Client) {
+		secret := &corev1.Secret{}
+		secret.Name, secret.Namespace = "kubeconfig", clu.Spec.KubeconfigSecretNamespace
+		c := newClusterTestClient(org, clu, secret)
+		return &controllerImpl.ClusterReconciler{
+			Client:              c,
+			Scheme:              c.Scheme(),
+			TargetFactory:       targets,
+			HealthCheckInterval: time.Minute,
+			HealthCheckJitter:   0.5,
+			HeartbeatTimeout:    5 * time.Minute,
+		}, c
+	}
+
+	It("records a heartbeat and checks healthy clusters again after the jittered interval", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		r, c := newReconciler(clu, org, clusterClients{clu.Name: newClusterTestClient(readyNode("node-1", ""))})
+
+		before := time.Now().Add(-time.Second)
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeNumerically(">=", time.Minute))
+		Expect(res.RequeueAfter).To(BeNumerically("<=", 90*time.Second))
+
+		got := getCluster(c, clu)
+		Expect(got.Status.LastHeartbeatTime).NotTo(BeNil())
+		Expect(got.Status.LastHeartbeatTime.Time).To(BeTemporally(">=", before))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+	})
+
+	It("counts each cluster of the org once across health checks", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		r, _ := newReconciler(clu, org, clusterClients{clu.Name: newClusterTestClient(readyNode("node-1", ""))})
+
+		for range 3 {
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(testutil.ToFloat64(metrics.ClustersPerOrg.WithLabelValues(org.Spec.OrgID))).To(Equal(1.0))
+	})
+
+	It("is not reconciled again by the status writes of its own health checks", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		clu.Generation = 1
+		r, c := newReconciler(clu, org, clusterClients{clu.Name: newClusterTestClient(readyNode("node-1", ""))})
+		r.HealthCheckInterval = time.Hour
+
+		// every reconcile starts by reading the cluster
+		var reconciles atomic.Int32
+		r.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+			Get: func(ctx context.Context, cl client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if _, ok := obj.(*platformv1alpha1.Cluster); ok && key.Name == clu.Name {
+					reconciles.Add(1)
+				}
+				return cl.Get(ctx, key, obj, opts...)
+			},
+		})
+
+		informers := &informertest.FakeInformers{Scheme: c.Scheme()}
+		skipNameValidation := true
+		mgr, err := ctrl.NewManager(&rest.Config{Host: "http://127.0.0.1:1"}, ctrl.Options{
+			Scheme:     c.Scheme(),
+			Metrics:    metricsserver.Options{BindAddress: "0"},
+			Controller: config.Controller{SkipNameValidation: &skipNameValidation},
+			MapperProvider: func(*rest.Config, *http.Client) (apimeta.RESTMapper, error) {
+				return apimeta.NewDefaultRESTMapper(nil), nil
+			},
+			NewCache:  func(*rest.Config, cache.Options) (cache.Cache, error) { return informers, nil },
+			NewClient: func(*rest.Config, client.Options) (client.Client, error) { return r.Client, nil },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.SetupWithManager(mgr)).To(Succeed())
+		informer, err := informers.FakeInformerFor(ctx, &platformv1alpha1.Cluster{})
+		Expect(err).NotTo(HaveOccurred())
+
+		mgrCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(mgrCtx)).To(Succeed())
+		}()
+
+		// the handlers are registered once the controller starts
+		Eventually(func() int32 {
+			informer.Add(clu)
+			return reconciles.Load()
+		}).ShouldNot(BeZero())
+		Eventually(func() bool {
+			n := reconciles.Load()
+			time.Sleep(100 * time.Millisecond)
+			return n == reconciles.Load()
+		}).Should(BeTrue())
+		settled := reconciles.Load()
+
+		checked := clu.DeepCopy()
+		checked.Status = getCluster(c, clu).Status
+		Expect(checked.Status.LastHeartbeatTime).NotTo(BeNil())
+		informer.Update(clu, checked)
+		Consistently(reconciles.Load, 500*time.Millisecond).Should(Equal(settled))
+
+		changed := checked.DeepCopy()
+		changed.Spec.DisplayName = "renamed"
+		changed.Generation++
+		informer.Update(checked, changed)
+		Eventually(reconciles.Load).Should(BeNumerically(">", settled))
+	})
+
+	It("keeps Ready through a missed check within the heartbeat timeout", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := reachedCluster(org, time.Now().Add(-time.Minute))
+		r, c := newReconciler(clu, org, clusterClients{})
+
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).NotTo(BeZero())
+
+		got := getCluster(c, clu)
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Error)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Status).To(Equal(metav1.ConditionTrue))
+		Expect(failed.Message).To(ContainSubstring("unreachable"))
+	})
+
+	It("turns Ready Unknown once the heartbeat timeout has passed", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := reachedCluster(org, time.Now().Add(-10*time.Minute))
+		r, c := newReconciler(clu, org, clusterClients{})
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+
+		ready := apimeta.FindStatusCondition(getCluster(c, clu).Status.Conditions, platformv1alpha1.Ready)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(ready.Reason).To(Equal("HeartbeatMissed"))
+		Expect(ready.Message).To(ContainSubstring("No heartbeat since"))
+	})
+
+	It("flags the bindings of an unreachable cluster without touching it", func() {
+		clu := unreachableCluster("gone-" + uuid.NewString()[:8])
+		proj := &platformv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "proj-" + uuid.NewString()[:8]}}
+		binding := &platformv1alpha1.ProjectClusterBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: proj.Name + "-" + clu.Name},
+			Spec:       platformv1alpha1.ProjectClusterBindingSpec{ProjectRef: proj.Name, ClusterRef: clu.Name},
+		}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(platformv1alpha1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(clu, proj, binding).
+			WithStatusSubresource(&platformv1alpha1.ProjectClusterBinding{}).
+			Build()
+		// no TargetFactory: reaching for the cluster would panic
+		r := &controllerImpl.ProjectClusterBindingReconciler{Client: c, Scheme: scheme}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(binding)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var got platformv1alpha1.ProjectClusterBinding
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(binding), &got)).To(Succeed())
+		flagged := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.ClusterUnreachable)
+		Expect(flagged).NotTo(BeNil())
+		Expect(flagged.Status).To(Equal(metav1.ConditionTrue))
+		Expect(flagged.Message).To(ContainSubstring(clu.Name))
+	})
+
+	It("flags Applications placed on an unreachable cluster and skips it", func() {
+		ns := "proj-" + uuid.NewString()[:8]
+		app := makeApplication(ns)
+		app.Status.Image = "ghcr.io/example/app@sha256:aaaa"
+		app.Spec.Placement = &platformv1alpha1.Placement{}
+		clu := unreachableCluster("gone-" + ns)
+		binding := &platformv1alpha1.ProjectClusterBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: app.Spec.ProjectRef + "-" + clu.Name},
+			Spec:       platformv1alpha1.ProjectClusterBindingSpec{ProjectRef: app.Spec.ProjectRef, ClusterRef: clu.Name},
+		}
+		remote := newApplicationTestClient()
+		c := newApplicationTestClient(app, clu, binding)
+		r := buildTestApplicationReconciler(c)
+		r.TargetFactory = clusterClients{clu.Name: remote}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = remote.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		var got platformv1alpha1.Application
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(app), &got)).To(Succeed())
+		Expect(got.Status.Clusters).To(HaveLen(1))
+		Expect(got.Status.Clusters[0].Reason).To(Equal("ClusterUnreachable"))
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.ClusterUnreachable)).To(BeTrue())
+		Expect(apimeta.IsStatusConditionFalse(got.Status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+	})
+})
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/clusterprovider"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+)
+
+var _ = Describe("Cluster node pools", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	reconcileCluster := func(r reconcile.Reconciler, clu *platformv1alpha1.Cluster) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getCluster := func(c client.Client, clu *platformv1alpha1.Cluster) *platformv1alpha1.Cluster {
+		var got platformv1alpha1.Cluster
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clu), &got)).To(Succeed())
+		return &got
+	}
+
+	gpuTaint := corev1.Taint{Key: "nvidia.com/gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+
+	It("applies the labels and taints of each pool to its nodes and reports its size", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "kubeconfig", org.Spec.OrgID, "remote")
+		clu.Spec.NodePools = []platformv1alpha1.NodePool{
+			{Name: "web", MinSize: 1, MaxSize: 3},
+			{Name: "gpu", MinSize: 0, MaxSize: 4, Labels: map[string]string{"accelerator": "a100"}, Taints: []corev1.Taint{gpuTaint}},
+		}
+		secret := &corev1.Secret{}
+		secret.Name, secret.Namespace = "kubeconfig", clu.Spec.KubeconfigSecretNamespace
+		target := newClusterTestClient(readyNode("web-1", "web"), readyNode("web-2", "web"), readyNode("gpu-1", "gpu"), readyNode("other", ""))
+		c := newClusterTestClient(org, clu, secret)
+		r := &controllerImpl.ClusterReconciler{
+			Client:        c,
+			Scheme:        c.Scheme(),
+			TargetFactory: clusterClients{clu.Name: target},
+		}
+		reconcileCluster(r, clu)
+
+		var gpu corev1.Node
+		Expect(target.Get(ctx, client.ObjectKey{Name: "gpu-1"}, &gpu)).To(Succeed())
+		Expect(gpu.Labels).To(HaveKeyWithValue("accelerator", "a100"))
+		Expect(gpu.Spec.Taints).To(ConsistOf(gpuTaint))
+		var other corev1.Node
+		Expect(target.Get(ctx, client.ObjectKey{Name: "other"}, &other)).To(Succeed())
+		Expect(other.Spec.Taints).To(BeEmpty())
+
+		pools := getCluster(c, clu).Status.NodePools
+		Expect(pools).To(HaveLen(2))
+		Expect(pools[0]).To(Equal(platformv1alpha1.NodePoolStatus{Name: "web", Desired: 2, Nodes: 2, ReadyNodes: 2}))
+		Expect(pools[1].Name).To(Equal("gpu"))
+		Expect(pools[1].Nodes).To(BeNumerically("==", 1))
+		Expect(pools[1].Desired).To(BeNumerically("==", 1))
+	})
+
+	It("scales provisioned pools to their desired size", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "", org.Spec.OrgID, "fake")
+		desired := int32(3)
+		clu.Spec.NodePools = []platformv1alpha1.NodePool{{Name: "web", MinSize: 1, MaxSize: 5, Desired: &desired}}
+		provider := newFakeProvider()
+		c := newClusterTestClient(org, clu)
+		r := &controllerImpl.ClusterReconciler{
+			Client:        c,
+			Scheme:        c.Scheme(),
+			TargetFactory: clusterClients{clu.Name: newClusterTestClient(readyNode("web-1", "web"))},
+			Providers:     clusterprovider.NewRegistry(provider),
+		}
+		reconcileCluster(r, clu)
+		reconcileCluster(r, clu)
+
+		Expect(provider.scaled).To(HaveKeyWithValue(clu.Name+"/web", int32(3)))
+		pools := getCluster(c, clu).Status.NodePools
+		Expect(pools).To(HaveLen(1))
+		Expect(pools[0].Nodes).To(BeNumerically("==", 1))
+		Expect(pools[0].Desired).To(BeNumerically("==", 3))
+		Expect(pools[0].Message).To(ContainSubstring("scaling to 3"))
+	})
+})
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	"github.com/mofe64/vulkan/operator/internal/clusterprovider"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+)
+
+const fakeKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: https://fake.example.com:6443
+contexts:
+- name: fake
+  context: {cluster: fake, user: admin}
+current-context: fake
+users:
+- name: admin
+  user: {token: secret}
+`
+
+// fakeProvider is an in-process ClusterProvider: a cluster is ready on the Create call
+// after the one that started it, and deleted on the Delete call after the first.
+type fakeProvider struct {
+	created map[string]int
+	deletes map[string]int
+	scaled  map[string]int32
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{created: map[string]int{}, deletes: map[string]int{}, scaled: map[string]int32{}}
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Create(_ context.Context, clu *platformv1alpha1.Cluster) (clusterprovider.Status, error) {
+	p.created[clu.Name]++
+	if p.created[clu.Name] < 2 {
+		return clusterprovider.Status{Message: "creating " + clu.Name}, nil
+	}
+	return clusterprovider.Status{Ready: true, Message: clu.Name + " is running"}, nil
+}
+
+func (p *fakeProvider) ScaleNodePool(_ context.Context, clu *platformv1alpha1.Cluster, pool string, size int32) error {
+	p.scaled[clu.Name+"/"+pool] = size
+	return nil
+}
+
+func (p *fakeProvider) Kubeconfig(context.Context, *platformv1alpha1.Cluster) ([]byte, error) {
+	return []byte(fakeKubeconfig), nil
+}
+
+func (p *fakeProvider) Delete(_ context.Context, clu *platformv1alpha1.Cluster) (bool, error) {
+	p.deletes[clu.Name]++
+	return p.deletes[clu.Name] > 1, nil
+}
+
+func newClusterTestClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(platformv1alpha1.AddToScheme(scheme)).To(Succeed())
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&platformv1alpha1.Cluster{}).
+		Build()
+}
+
+// readyNode is a node of the given pool reporting Ready.
+func readyNode(name, pool string) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{{
+			Type:   corev1.NodeReady,
+			Status: corev1.ConditionTrue,
+		}}},
+	}
+	if pool != "" {
+		node.Labels = map[string]string{platformv1alpha1.NodePoolLabel: pool}
+	}
+	return node
+}
+
+var _ = Describe("Cluster provisioning", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	reconcileCluster := func(r reconcile.Reconciler, clu *platformv1alpha1.Cluster) reconcile.Result {
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+		return res
+	}
+
+	getCluster := func(c client.Client, clu *platformv1alpha1.Cluster) *platformv1alpha1.Cluster {
+		var got platformv1alpha1.Cluster
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(clu), &got)).To(Succeed())
+		return &got
+	}
+
+	It("provisions a cluster through the provider of its type and tears it down on deletion", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "", org.Spec.OrgID, "fake")
+		provider := newFakeProvider()
+		c := newClusterTestClient(org, clu)
+		r := &controllerImpl.ClusterReconciler{
+			Client:        c,
+			Scheme:        c.Scheme(),
+			TargetFactory: clusterClients{clu.Name: newClusterTestClient(readyNode("node-1", ""))},
+			Providers:     clusterprovider.NewRegistry(provider),
+		}
+
+		res := reconcileCluster(r, clu)
+		Expect(res.RequeueAfter).NotTo(BeZero())
+		got := getCluster(c, clu)
+		Expect(got.Finalizers).To(ContainElement(platformv1alpha1.ClusterFinalizer))
+		Expect(got.Spec.KubeconfigSecretName).To(Equal(clu.Name + "-kubeconfig"))
+		provisioning := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Provisioning)
+		Expect(provisioning).NotTo(BeNil())
+		Expect(provisioning.Status).To(Equal(metav1.ConditionTrue))
+		Expect(apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Ready).Reason).To(Equal("Provisioning"))
+
+		reconcileCluster(r, clu)
+		got = getCluster(c, clu)
+		Expect(apimeta.IsStatusConditionFalse(got.Status.Conditions, platformv1alpha1.Provisioning)).To(BeTrue())
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Ready)).To(BeTrue())
+		Expect(got.Status.Endpoint).To(Equal("https://fake.example.com:6443"))
+		var secret corev1.Secret
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: got.Spec.KubeconfigSecretName}, &secret)).To(Succeed())
+		Expect(string(secret.Data["kubeconfig"])).To(Equal(fakeKubeconfig))
+
+		// the CR is only released once the provider has deleted the cluster
+		Expect(c.Delete(ctx, got)).To(Succeed())
+		reconcileCluster(r, clu)
+		got = getCluster(c, clu)
+		Expect(apimeta.IsStatusConditionTrue(got.Status.Conditions, platformv1alpha1.Deleting)).To(BeTrue())
+		reconcileCluster(r, clu)
+		Expect(errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(clu), &platformv1alpha1.Cluster{}))).To(BeTrue())
+		Expect(provider.deletes[clu.Name]).To(Equal(2))
+	})
+
+	It("reports a provider error through the Error condition", func() {
+		org := makeOrg("", uuid.NewString())
+		clu := makeCluster("", "", org.Spec.OrgID, clusterprovider.LocalType)
+		c := newClusterTestClient(org, clu)
+		r := &controllerImpl.ClusterReconciler{
+			Client:    c,
+			Scheme:    c.Scheme(),
+			Providers: clusterprovider.NewRegistry(&clusterprovider.Local{Binary: "/nonexistent/kind"}),
+		}
+		reconcileCluster(r, clu)
+
+		got := getCluster(c, clu)
+		failed := apimeta.FindStatusCondition(got.Status.Conditions, platformv1alpha1.Error)
+		Expect(failed).NotTo(BeNil())
+		Expect(failed.Reason).To(Equal("ProvisioningFailed"))
+		Expect(failed.Message).To(ContainSubstring("kind get clusters"))
+	})
+})
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel/model"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+	"sigs.k8s.io/yaml"
+)
+
+// crdSchemas loads the structural schema of every version of the generated CRDs, by CRD name.
+func crdSchemas() map[string]*schema.Structural {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "config", "crd", "bases", "*.yaml"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(paths).NotTo(BeEmpty())
+
+	schemas := map[string]*schema.Structural{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		var crd apiextensionsv1.CustomResourceDefinition
+		Expect(yaml.Unmarshal(data, &crd)).To(Succeed())
+		for _, version := range crd.Spec.Versions {
+			var props apiextensions.JSONSchemaProps
+			Expect(apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(
+				version.Schema.OpenAPIV3Schema, &props, nil)).To(Succeed())
+			structural, err := schema.NewStructural(&props)
+			Expect(err).NotTo(HaveOccurred(), "structural schema of %s %s", crd.Name, version.Name)
+			schemas[crd.Name+"/"+version.Name] = structural
+		}
+	}
+	return schemas
+}
+
+// compileRules compiles the x-kubernetes-validations of the schema and the ones nested
+// in it the way the apiserver does, returning the rules that fail by path.
+func compileRules(s *schema.Structural, path string, root bool, failed map[string]string) {
+	if len(s.XValidations) > 0 {
+		results, err := cel.Compile(s, model.SchemaDeclType(s, root), celconfig.PerCallLimit,
+			environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), true), cel.NewExpressionsEnvLoader())
+		Expect(err).NotTo(HaveOccurred(), path)
+		for i, result := range results {
+			if result.Error != nil {
+				failed[path+": "+s.XValidations[i].Rule] = result.Error.Error()
+			}
+		}
+	}
+	for name, property := range s.Properties {
+		compileRules(&property, path+"."+name, false, failed)
+	}
+	if s.Items != nil {
+		compileRules(s.Items, path+"[]", false, failed)
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Structural != nil {
+		compileRules(s.AdditionalProperties.Structural, path+"{}", false, failed)
+	}
+}
+
+// validateApplication runs the CEL rules of the Application CRD against an Application
+// with the given spec.
+func validateApplication(spec map[string]any) field.ErrorList {
+	structural := crdSchemas()["applications.platform.platform.io/v1alpha1"]
+	Expect(structural).NotTo(BeNil())
+	validator := cel.NewValidator(structural, true, celconfig.PerCallLimit)
+	obj := map[string]any{
+		"apiVersion": "platform.platform.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]any{"name": "app", "namespace": "default"},
+		"spec":       spec,
+	}
+	errs, _ := validator.Validate(context.Background(), field.NewPath("root"), structural, obj, nil, celconfig.RuntimeCELCostBudget)
+	return errs
+}
+
+var _ = Describe("CRD validation rules", func() {
+	It("compile with the CEL environment of the apiserver", func() {
+		for name, structural := range crdSchemas() {
+			failed := map[string]string{}
+			compileRules(structural, name, true, failed)
+			Expect(failed).To(BeEmpty(), "CEL rules of %s", name)
+		}
+	})
+
+	It("require a schedule for cron Applications", func() {
+		base := func() map[string]any {
+			return map[string]any{"projectRef": "proj", "repoURL": "https://github.com/example/app", "type": "cron"}
+		}
+
+		withoutSchedule := validateApplication(base())
+		Expect(withoutSchedule.ToAggregate()).To(MatchError(ContainSubstring("cron Applications require a schedule")))
+
+		empty := base()
+		empty["schedule"] = ""
+		Expect(validateApplication(empty).ToAggregate()).To(MatchError(ContainSubstring("cron Applications require a schedule")))
+
+		scheduled := base()
+		scheduled["schedule"] = "*/5 * * * *"
+		Expect(validateApplication(scheduled)).To(BeEmpty())
+	})
+
+	It("reject prebuilt images with the argocd backend", func() {
+		spec := map[string]any{
+			"projectRef": "proj",
+			"image":      map[string]any{"reference": "ghcr.io/example/app:v1"},
+			"deploy":     map[string]any{"backend": "argocd"},
+		}
+		Expect(validateApplication(spec).ToAggregate()).To(MatchError(ContainSubstring("the argocd backend only deploys web Applications built from source")))
+
+		spec["deploy"] = map[string]any{"backend": "direct"}
+		Expect(validateApplication(spec)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/mofe64/vulkan/operator/api/v1alpha1"
+	controllerImpl "github.com/mofe64/vulkan/operator/internal/controller"
+	"github.com/mofe64/vulkan/operator/internal/utils"
+)
+
+var _ = Describe("Target client factory", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	// kubeconfigCluster is a remote cluster and the Secret holding its kubeconfig.
+	kubeconfigCluster := func() (*platformv1alpha1.Cluster, *corev1.Secret) {
+		name := "remote-" + uuid.NewString()[:8]
+		clu := &platformv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(uuid.NewString())},
+			Spec: platformv1alpha1.ClusterSpec{
+				Type:                      "remote",
+				KubeconfigSecretName:      name + "-kubeconfig",
+				KubeconfigSecretNamespace: "default",
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-kubeconfig", Namespace: "default"},
+			Data:       map[string][]byte{"kubeconfig": []byte(fakeKubeconfig)},
+		}
+		return clu, secret
+	}
+
+	It("hands out the same client until the kubeconfig Secret changes", func() {
+		clu, secret := kubeconfigCluster()
+		cp := newClusterTestClient(clu, secret)
+		factory := utils.NewTargetClientFactory(cp)
+
+		first, err := factory.ClientFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		again, err := factory.ClientFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again).To(BeIdenticalTo(first))
+
+		secret.Data["kubeconfig"] = []byte(fakeKubeconfig + "\n")
+		Expect(cp.Update(ctx, secret)).To(Succeed())
+		rotated, err := factory.ClientFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rotated).NotTo(BeIdenticalTo(first))
+
+		// a cluster recreated under the same name gets a client of its own
+		recreated := clu.DeepCopy()
+		recreated.UID = types.UID(uuid.NewString())
+		fresh, err := factory.ClientFor(ctx, recreated)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fresh).NotTo(BeIdenticalTo(rotated))
+	})
+
+	It("evicts the client of a deleted cluster", func() {
+		clu, secret := kubeconfigCluster()
+		cp := newClusterTestClient(clu, secret)
+		factory := utils.NewTargetClientFactory(cp)
+		first, err := factory.ClientFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cp.Delete(ctx, clu)).To(Succeed())
+		r := &controllerImpl.ClusterReconciler{Client: cp, Scheme: cp.Scheme(), TargetFactory: factory}
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clu)})
+		Expect(err).NotTo(HaveOccurred())
+
+		next, err := factory.ClientFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).NotTo(BeIdenticalTo(first))
+	})
+
+	It("shares the informer cache of a cluster until its client is evicted", func() {
+		clu, secret := kubeconfigCluster()
+		factory := utils.NewTargetClientFactory(newClusterTestClient(clu, secret))
+		caches := factory.(utils.TargetCacheFactory)
+
+		first, err := caches.CacheFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		again, err := caches.CacheFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again).To(BeIdenticalTo(first))
+
+		factory.(utils.TargetClientEvicter).Evict(clu.Name)
+		next, err := caches.CacheFor(ctx, clu)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).NotTo(BeIdenticalTo(first))
+		factory.(utils.TargetClientEvicter).Evict(clu.Name)
+	})
+})